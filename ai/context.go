@@ -0,0 +1,411 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gochat/models"
+)
+
+const (
+	// maxReplyReserve is the number of tokens kept free for the reply when the settings do not limit it.
+	maxReplyReserve = 1024
+	// summaryHeading introduces the summary of the earlier conversation in the system prompt.
+	summaryHeading = "Summary of the earlier conversation:\n"
+)
+
+// summaryPrompt asks the model to summarise a part of a conversation. The transcript is appended.
+const summaryPrompt = "Summarise the conversation below so the summary can replace it as context for continuing the " +
+	"conversation. Keep facts, decisions, names, numbers, code identifiers and open questions; leave out pleasantries. " +
+	"Reply with the summary only.\n\n"
+
+// errEmptySummary is returned when the provider answers a summary request with no text.
+var errEmptySummary = errors.New("the AI provider returned an empty summary")
+
+/*
+Summarizer is implemented by providers that summarise conversations themselves rather than by answering a summary
+prompt through Complete.
+
+Summarize returns the previous summary, if any, extended by the given messages, in at most limit tokens.
+*/
+type Summarizer interface {
+	Summarize(ctx context.Context, previous string, messages []models.Message, limit int) (string, error)
+}
+
+/*
+Summary is a rolling summary of the start of a conversation.
+
+- Fields:
+	* `Text` (string) The summary.
+	* `ThroughID` (uint) The ID of the last message the summary covers. Zero if there is no summary.
+*/
+type Summary struct {
+	Text      string
+	ThroughID uint
+}
+
+/*
+ContextUsage describes how much of the context window a request takes.
+
+- Fields:
+	* `Tokens` (int) The estimated number of tokens of the system prompt, the summary and the messages sent.
+	* `Budget` (int) The number of tokens available for them, which is the window less the room kept for the reply.
+	* `Window` (int) The context size of the model.
+	* `Summarized` (int) The number of messages replaced by the summary.
+	* `Dropped` (int) The number of messages left out without being summarised.
+*/
+type ContextUsage struct {
+	Tokens     int
+	Budget     int
+	Window     int
+	Summarized int
+	Dropped    int
+}
+
+/*
+Percent returns the share of the context window in use.
+
+- Returns:
+	(int) The share in percent, at most 100.
+*/
+func (usage ContextUsage) Percent() int {
+	if usage.Window <= 0 {
+		return 0
+	}
+	return min(100, usage.Tokens*100/usage.Window)
+}
+
+/*
+ContextPlan is what FitContext decided to send to the provider.
+
+- Fields:
+	* `History` ([]models.Message) The messages to send, in chronological order.
+	* `Settings` (models.GenerationSettings) The generation settings, with the summary added to the system prompt.
+	* `Summary` (Summary) The summary that is sent.
+	* `Updated` (bool) True if the summary was written by this call and should be saved.
+	* `Usage` (ContextUsage) The context window usage of the request.
+*/
+type ContextPlan struct {
+	History  []models.Message
+	Settings models.GenerationSettings
+	Summary  Summary
+	Updated  bool
+	Usage    ContextUsage
+}
+
+/*
+FitContext fits a conversation into the context window of the provider.
+
+The messages after the summary are sent unchanged as long as they fit, leaving room for the reply. Otherwise the
+newest messages are kept within three quarters of the room and the older ones are folded into the summary, which is
+sent as part of the system prompt. If the summary cannot be written the older messages are left out instead; the plan
+is usable in that case too and the error only reports why nothing was summarised.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `provider` (Provider) The AI provider.
+	* `history` ([]models.Message) The conversation in chronological order, with the message to answer last.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `summary` (Summary) The latest summary of the conversation. It is ignored unless it covers a message of the
+	  history or the history starts right after it.
+
+- Returns:
+	* `ContextPlan` The messages and settings to send.
+	* `error` An error if the older messages had to be dropped because summarising them failed.
+*/
+func FitContext(ctx context.Context, provider Provider, history []models.Message, settings models.GenerationSettings, summary Summary) (ContextPlan, error) {
+	summary, start := summaryStart(history, summary)
+	budget := promptBudget(provider, settings)
+
+	plan := newContextPlan(provider, history[start:], settings, summary)
+	plan.Usage.Summarized = start
+	if plan.Usage.Tokens <= budget {
+		return plan, nil
+	}
+
+	keep := keepFrom(provider, history, start, budget*3/4-EstimateTokens(provider, settings.SystemPrompt))
+	if keep == start {
+		return plan, nil
+	}
+
+	text, err := summarize(ctx, provider, settings, summary.Text, history[start:keep], budget)
+	if err != nil {
+		plan = newContextPlan(provider, history[keep:], settings, summary)
+		plan.Usage.Summarized = start
+		plan.Usage.Dropped = keep - start
+		return plan, err
+	}
+
+	plan = newContextPlan(provider, history[keep:], settings, Summary{Text: text, ThroughID: history[keep-1].ID})
+	plan.Updated = true
+	plan.Usage.Summarized = keep
+	return plan, nil
+}
+
+/*
+MeasureContext estimates how much of the context window the next request of a conversation takes, without trimming
+or summarising anything.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `history` ([]models.Message) The conversation in chronological order, or the part of it after the summary.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `summary` (Summary) The latest summary of the conversation.
+
+- Returns:
+	(ContextUsage) The usage. Tokens exceed the budget when the next reply will summarise older messages. Summarized
+	only counts the messages of the history the summary covers.
+*/
+func MeasureContext(provider Provider, history []models.Message, settings models.GenerationSettings, summary Summary) ContextUsage {
+	summary, start := summaryStart(history, summary)
+	usage := newContextPlan(provider, history[start:], settings, summary).Usage
+	usage.Summarized = start
+	return usage
+}
+
+/*
+summaryStart finds the first message of a history that a summary does not cover.
+
+The history may either contain the messages the summary covers or start right after them.
+
+- Args:
+	* `history` ([]models.Message) The conversation in chronological order.
+	* `summary` (Summary) The summary.
+
+- Returns:
+	* `Summary` The summary, or an empty one if it does not belong to the history.
+	* `int` The index of the first message after the summary.
+*/
+func summaryStart(history []models.Message, summary Summary) (Summary, int) {
+	if summary.ThroughID == 0 || summary.Text == "" || len(history) == 0 {
+		return Summary{}, 0
+	}
+	if parent := history[0].ParentID; parent != nil && *parent == summary.ThroughID {
+		return summary, 0
+	}
+	// The message to answer is never summarised, so it always remains to be sent.
+	for index, message := range history[:max(len(history)-1, 0)] {
+		if message.ID == summary.ThroughID {
+			return summary, index + 1
+		}
+	}
+	return Summary{}, 0
+}
+
+/*
+promptBudget returns the number of tokens available for the prompt, after keeping room for the reply.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+
+- Returns:
+	(int) The prompt budget in tokens.
+*/
+func promptBudget(provider Provider, settings models.GenerationSettings) int {
+	window := ContextWindow(provider, settings)
+	reserve := min(maxReplyReserve, window/4)
+	if settings.MaxTokens != nil && *settings.MaxTokens > 0 {
+		reserve = min(*settings.MaxTokens, window/2)
+	}
+	return window - reserve
+}
+
+/*
+newContextPlan builds the plan that sends the given messages with a summary.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `history` ([]models.Message) The messages to send.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `summary` (Summary) The summary to send.
+
+- Returns:
+	(ContextPlan) The plan, with Tokens, Budget and Window of its usage set.
+*/
+func newContextPlan(provider Provider, history []models.Message, settings models.GenerationSettings, summary Summary) ContextPlan {
+	if summary.Text != "" {
+		settings.SystemPrompt = strings.TrimSpace(settings.SystemPrompt + "\n\n" + summaryHeading + summary.Text)
+	}
+
+	tokens := EstimateMessages(provider, history)
+	if settings.SystemPrompt != "" {
+		tokens += EstimateTokens(provider, settings.SystemPrompt) + messageOverheadTokens
+	}
+	return ContextPlan{
+		History:  history,
+		Settings: settings,
+		Summary:  summary,
+		Usage: ContextUsage{
+			Tokens: tokens,
+			Budget: promptBudget(provider, settings),
+			Window: ContextWindow(provider, settings),
+		},
+	}
+}
+
+/*
+keepFrom finds the oldest message from which the rest of a history fits into a number of tokens.
+
+The last message is always kept, and the kept messages start with a user message where possible.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `history` ([]models.Message) The conversation in chronological order.
+	* `start` (int) The index of the oldest message that may be kept.
+	* `limit` (int) The number of tokens the kept messages may take.
+
+- Returns:
+	(int) The index of the oldest kept message.
+*/
+func keepFrom(provider Provider, history []models.Message, start, limit int) int {
+	keep := len(history) - 1
+	used := EstimateMessages(provider, history[keep:])
+	for keep > start {
+		cost := EstimateMessages(provider, history[keep-1:keep])
+		if used+cost > limit {
+			break
+		}
+		used += cost
+		keep--
+	}
+	// Conversations sent to a model should open with a user message, so a leading reply is summarised as well.
+	if keep > start && keep < len(history)-1 && history[keep].MessageType == models.AIMessageType {
+		keep++
+	}
+	return keep
+}
+
+/*
+summarize folds messages into a rolling summary.
+
+The messages are sent to the provider in chunks that fit into half the prompt budget, each together with the summary
+so far. A provider that implements Summarizer summarises them itself instead.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `provider` (Provider) The AI provider.
+	* `settings` (models.GenerationSettings) The generation settings of the chat; only the model is used.
+	* `previous` (string) The summary of the messages before the given ones, if any.
+	* `messages` ([]models.Message) The messages to add to the summary.
+	* `budget` (int) The prompt budget in tokens.
+
+- Returns:
+	(string) The new summary, or an error if the provider failed.
+*/
+func summarize(ctx context.Context, provider Provider, settings models.GenerationSettings, previous string, messages []models.Message, budget int) (string, error) {
+	if summarizer, ok := provider.(Summarizer); ok {
+		return summarizer.Summarize(ctx, previous, messages, max(budget/4, 1))
+	}
+
+	summary := previous
+	chunkLimit := max(budget/2, 1)
+	for len(messages) > 0 {
+		var transcript strings.Builder
+		used, count := 0, 0
+		for ; count < len(messages); count++ {
+			line := transcriptLine(messages[count])
+			cost := EstimateTokens(provider, line)
+			if count > 0 && used+cost > chunkLimit {
+				break
+			}
+			if cost > chunkLimit {
+				line, cost = truncateTokens(provider, line, chunkLimit)+"\n\n", chunkLimit
+			}
+			transcript.WriteString(line)
+			used += cost
+		}
+		messages = messages[count:]
+
+		prompt := summaryPrompt
+		if summary != "" {
+			prompt += summaryHeading + summary + "\n\nNew messages:\n"
+		}
+		prompt += transcript.String()
+
+		text, err := provider.Complete(ctx, []models.Message{{Message: prompt, MessageType: models.UserMessageType}}, models.GenerationSettings{Model: settings.Model})
+		if err != nil {
+			return "", err
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			return "", errEmptySummary
+		}
+		summary = truncateTokens(provider, text, max(budget/4, 1))
+	}
+	return summary, nil
+}
+
+/*
+heuristicSummary summarises messages by the first words of each of them.
+
+- Args:
+	* `provider` (Provider) The AI provider, used to estimate tokens.
+	* `previous` (string) The summary of the messages before the given ones, if any.
+	* `messages` ([]models.Message) The messages to add to the summary.
+	* `limit` (int) The number of tokens the summary may take.
+
+- Returns:
+	(string) The summary.
+*/
+func heuristicSummary(provider Provider, previous string, messages []models.Message, limit int) string {
+	lines := []string{}
+	if previous != "" {
+		lines = append(lines, previous)
+	}
+	for _, message := range messages {
+		if title := HeuristicTitle(message.Message); title != "" {
+			lines = append(lines, "- "+speaker(message.MessageType)+": "+title)
+		}
+	}
+	return truncateTokens(provider, strings.Join(lines, "\n"), max(limit, 1))
+}
+
+/*
+transcriptLine formats a message for a transcript sent to the provider.
+
+- Args:
+	* `message` (models.Message) The message.
+
+- Returns:
+	(string) The speaker and the text of the message, followed by a blank line.
+*/
+func transcriptLine(message models.Message) string {
+	return speaker(message.MessageType) + ": " + message.Message + "\n\n"
+}
+
+/*
+speaker names the author of a message in a transcript.
+
+- Args:
+	* `messageType` (models.MessageType) The stored message type.
+
+- Returns:
+	(string) "Assistant" for AI messages and "User" for everything else.
+*/
+func speaker(messageType models.MessageType) string {
+	if messageType == models.AIMessageType {
+		return "Assistant"
+	}
+	return "User"
+}
+
+/*
+truncateTokens shortens a text to about a number of tokens.
+
+- Args:
+	* `provider` (Provider) The AI provider, used to estimate tokens.
+	* `text` (string) The text.
+	* `limit` (int) The number of tokens the text may take.
+
+- Returns:
+	(string) The text, cut and marked with an ellipsis if it was too long.
+*/
+func truncateTokens(provider Provider, text string, limit int) string {
+	tokens := EstimateTokens(provider, text)
+	if tokens <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:len(runes)*limit/tokens])) + "…"
+}
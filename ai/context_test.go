@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gochat/models"
+)
+
+// promptProvider answers every prompt with a fixed text and records the prompts it was sent.
+type promptProvider struct {
+	MockProvider
+	prompts []string
+}
+
+func (provider *promptProvider) Complete(ctx context.Context, history []models.Message, settings models.GenerationSettings) (string, error) {
+	provider.prompts = append(provider.prompts, history[len(history)-1].Message)
+	return "the model's summary", nil
+}
+
+func TestSummarize(t *testing.T) {
+	messages := []models.Message{
+		{Message: "How do I read a file in Go?", MessageType: models.UserMessageType},
+		{Message: "Use os.ReadFile.", MessageType: models.AIMessageType},
+	}
+
+	summary, err := summarize(context.Background(), NewMockProvider(), models.GenerationSettings{}, "", messages, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary, "User: ") || !strings.Contains(summary, "Assistant: ") {
+		t.Errorf("mock summary = %q, want a line per message", summary)
+	}
+
+	// Wrapped so only the Provider methods are visible, the provider is not a Summarizer and gets the summary prompt.
+	provider := &promptProvider{}
+	summary, err = summarize(context.Background(), struct{ Provider }{provider}, models.GenerationSettings{}, "earlier", messages, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "the model's summary" || len(provider.prompts) != 1 {
+		t.Fatalf("summary = %q after %d prompts, want the model's summary after one", summary, len(provider.prompts))
+	}
+	if prompt := provider.prompts[0]; !strings.Contains(prompt, "earlier") || !strings.Contains(prompt, "os.ReadFile") {
+		t.Errorf("summary prompt %q lacks the previous summary or the messages", prompt)
+	}
+}
@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultTimeout is used when Options.Timeout is not set.
+const defaultTimeout = 2 * time.Minute
+
+/*
+newHTTPClient creates the HTTP client shared by the HTTP based providers.
+
+- Args:
+	* `timeout` (time.Duration) The request timeout. Zero selects the default.
+
+- Returns:
+	(*http.Client) The HTTP client.
+*/
+func newHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+/*
+postJSON sends a JSON request body and returns the response.
+
+The caller is responsible for closing the response body. Responses with a non-2xx status are turned into an error that
+includes the start of the response body.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `client` (*http.Client) The HTTP client.
+	* `url` (string) The endpoint URL.
+	* `apiKey` (string) An optional bearer token.
+	* `payload` (any) The value to encode as the request body.
+
+- Returns:
+	(*http.Response) The response, or an error if the request failed.
+*/
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("%s returned %s: %s", url, response.Status, bytes.TrimSpace(detail))
+	}
+
+	return response, nil
+}
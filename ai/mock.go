@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gochat/models"
+)
+
+// mockChunkDelay is the pause between chunks when the mock provider streams its reply.
+const mockChunkDelay = 25 * time.Millisecond
+
+// mockReply is the canned reply returned by the mock provider.
+const mockReply = `Example go code:
+` + "```go\n" + `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello, world!")
+}
+` + "```\n\n" + `This is hardcoded in the backend for now. ` + "`inline-code`" + ` this is an example of inline code.`
+
+// MockProvider returns a canned reply and never contacts a model. It is intended for development and demos.
+// A configured context window only changes how much of a conversation is considered to fit.
+type MockProvider struct {
+	contextWindow int
+}
+
+/*
+NewMockProvider creates a MockProvider.
+
+- Returns:
+	(*MockProvider) The mock provider.
+*/
+func NewMockProvider() *MockProvider {
+	return &MockProvider{}
+}
+
+/*
+Complete returns a hardcoded AI response message.
+
+The reply contains a fenced code block and inline code so the frontend formatting can be exercised without a model.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `history` ([]models.Message) The chat history. It is ignored.
+	* `settings` (models.GenerationSettings) The generation settings. They are ignored.
+
+- Returns:
+	(string) The hardcoded AI response message, or the context error if the request was cancelled.
+*/
+func (provider *MockProvider) Complete(ctx context.Context, history []models.Message, settings models.GenerationSettings) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return mockReply, nil
+}
+
+/*
+Stream emits the hardcoded AI response word by word with a short delay, imitating a model generating tokens.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `history` ([]models.Message) The chat history. It is ignored.
+	* `settings` (models.GenerationSettings) The generation settings. They are ignored.
+	* `emit` (func(string) error) Called with each chunk of the reply.
+
+- Returns:
+	(string) The text emitted so far, and an error if streaming stopped early.
+*/
+func (provider *MockProvider) Stream(ctx context.Context, history []models.Message, settings models.GenerationSettings, emit func(chunk string) error) (string, error) {
+	output := collector{emit: emit}
+	for _, word := range strings.SplitAfter(mockReply, " ") {
+		select {
+		case <-ctx.Done():
+			return output.text(), ctx.Err()
+		case <-time.After(mockChunkDelay):
+		}
+
+		if err := output.add(word); err != nil {
+			return output.text(), err
+		}
+	}
+	return output.text(), nil
+}
+
+/*
+Summarize builds a heuristic summary from the first words of every message, since the mock provider has no model that
+could answer a summary prompt.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `previous` (string) The summary of the earlier messages, if any.
+	* `messages` ([]models.Message) The messages to add to the summary.
+	* `limit` (int) The number of tokens the summary may take.
+
+- Returns:
+	(string) The summary, or the context error if the request was cancelled.
+*/
+func (provider *MockProvider) Summarize(ctx context.Context, previous string, messages []models.Message, limit int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return heuristicSummary(provider, previous, messages, limit), nil
+}
+
+/*
+Title builds a heuristic title from the first words of the message, since the mock provider has no model that could
+answer a title prompt.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `message` (string) The first user message of the chat.
+
+- Returns:
+	(string) The title, or the context error if the request was cancelled.
+*/
+func (provider *MockProvider) Title(ctx context.Context, message string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return HeuristicTitle(message), nil
+}
@@ -0,0 +1,170 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gochat/models"
+)
+
+const (
+	defaultOllamaBaseURL = "http://localhost:11434"
+	defaultOllamaModel   = "llama3"
+)
+
+// OllamaProvider talks to a local Ollama server through its /api/chat endpoint.
+type OllamaProvider struct {
+	baseURL       string
+	model         string
+	contextWindow int
+	client        *http.Client
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+// ollamaOptions are the model parameters of an Ollama request. NumPredict is Ollama's name for the token limit and
+// NumCtx for the context size.
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message openAIMessage `json:"message"`
+	Done    bool          `json:"done"`
+}
+
+/*
+NewOllamaProvider creates an OllamaProvider.
+
+- Args:
+	* `options` (Options) The provider settings. The base URL defaults to a local Ollama server.
+
+- Returns:
+	(*OllamaProvider) The Ollama provider.
+*/
+func NewOllamaProvider(options Options) *OllamaProvider {
+	provider := &OllamaProvider{
+		baseURL:       strings.TrimRight(options.BaseURL, "/"),
+		model:         options.Model,
+		contextWindow: options.ContextWindow,
+		client:        newHTTPClient(options.Timeout),
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultOllamaBaseURL
+	}
+	if provider.model == "" {
+		provider.model = defaultOllamaModel
+	}
+	return provider
+}
+
+/*
+Complete sends the chat history to Ollama and returns the reply.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+
+- Returns:
+	(string) The reply text, or an error if the request failed.
+*/
+func (provider *OllamaProvider) Complete(ctx context.Context, history []models.Message, settings models.GenerationSettings) (string, error) {
+	payload := provider.request(history, settings, false)
+
+	response, err := postJSON(ctx, provider.client, provider.baseURL+"/api/chat", "", payload)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var result ollamaResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.Message.Content, nil
+}
+
+/*
+Stream requests a streamed reply and emits each message chunk as it arrives.
+
+Ollama streams newline-delimited JSON objects and marks the last one with `done`.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `emit` (func(string) error) Called with each chunk of the reply.
+
+- Returns:
+	(string) The text received so far, and an error if streaming stopped early.
+*/
+func (provider *OllamaProvider) Stream(ctx context.Context, history []models.Message, settings models.GenerationSettings, emit func(chunk string) error) (string, error) {
+	payload := provider.request(history, settings, true)
+
+	response, err := postJSON(ctx, provider.client, provider.baseURL+"/api/chat", "", payload)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	output := collector{emit: emit}
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var chunk ollamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				return output.text(), ctx.Err()
+			}
+			return output.text(), err
+		}
+		if err := output.add(chunk.Message.Content); err != nil {
+			return output.text(), err
+		}
+		if chunk.Done {
+			return output.text(), nil
+		}
+	}
+}
+
+/*
+request builds the /api/chat request for a conversation.
+
+- Args:
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `stream` (bool) Whether the reply should be streamed.
+
+- Returns:
+	(ollamaRequest) The request payload.
+*/
+func (provider *OllamaProvider) request(history []models.Message, settings models.GenerationSettings, stream bool) ollamaRequest {
+	payload := ollamaRequest{
+		Model:    modelFor(settings, provider.model),
+		Messages: openAIMessages(history, settings.SystemPrompt),
+		Stream:   stream,
+	}
+	// A configured context size is passed on, since Ollama otherwise runs every model with its small default.
+	if settings.Temperature != nil || settings.TopP != nil || settings.MaxTokens != nil || provider.contextWindow > 0 {
+		payload.Options = &ollamaOptions{
+			Temperature: settings.Temperature,
+			TopP:        settings.TopP,
+			NumPredict:  settings.MaxTokens,
+			NumCtx:      provider.contextWindow,
+		}
+	}
+	return payload
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gochat/models"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAIProvider talks to any API implementing the OpenAI chat completions endpoint.
+type OpenAIProvider struct {
+	baseURL       string
+	apiKey        string
+	model         string
+	contextWindow int
+	client        *http.Client
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Stream      bool            `json:"stream,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	MaxTokens   *int            `json:"max_tokens,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+/*
+NewOpenAIProvider creates an OpenAIProvider.
+
+- Args:
+	* `options` (Options) The provider settings. The base URL defaults to the OpenAI API.
+
+- Returns:
+	(*OpenAIProvider) The OpenAI compatible provider.
+*/
+func NewOpenAIProvider(options Options) *OpenAIProvider {
+	provider := &OpenAIProvider{
+		baseURL:       strings.TrimRight(options.BaseURL, "/"),
+		apiKey:        options.APIKey,
+		model:         options.Model,
+		contextWindow: options.ContextWindow,
+		client:        newHTTPClient(options.Timeout),
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultOpenAIBaseURL
+	}
+	if provider.model == "" {
+		provider.model = defaultOpenAIModel
+	}
+	return provider
+}
+
+/*
+Complete sends the chat history to the chat completions endpoint and returns the first choice.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+
+- Returns:
+	(string) The reply text, or an error if the request failed.
+*/
+func (provider *OpenAIProvider) Complete(ctx context.Context, history []models.Message, settings models.GenerationSettings) (string, error) {
+	payload := provider.request(history, settings)
+
+	response, err := postJSON(ctx, provider.client, provider.baseURL+"/chat/completions", provider.apiKey, payload)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var result openAIResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("openai: response contained no choices")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+/*
+Stream requests a streamed completion and emits each content delta as it arrives.
+
+The endpoint answers with server-sent events, one JSON chunk per `data:` line, terminated by `data: [DONE]`.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `emit` (func(string) error) Called with each chunk of the reply.
+
+- Returns:
+	(string) The text received so far, and an error if streaming stopped early.
+*/
+func (provider *OpenAIProvider) Stream(ctx context.Context, history []models.Message, settings models.GenerationSettings, emit func(chunk string) error) (string, error) {
+	payload := provider.request(history, settings)
+	payload.Stream = true
+
+	response, err := postJSON(ctx, provider.client, provider.baseURL+"/chat/completions", provider.apiKey, payload)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	output := collector{emit: emit}
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data:")
+		if !found {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return output.text(), nil
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return output.text(), err
+		}
+		for _, choice := range chunk.Choices {
+			if err := output.add(choice.Delta.Content); err != nil {
+				return output.text(), err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return output.text(), err
+	}
+	return output.text(), ctx.Err()
+}
+
+/*
+request builds the chat completions request for a conversation.
+
+- Args:
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+
+- Returns:
+	(openAIRequest) The request payload.
+*/
+func (provider *OpenAIProvider) request(history []models.Message, settings models.GenerationSettings) openAIRequest {
+	return openAIRequest{
+		Model:       modelFor(settings, provider.model),
+		Messages:    openAIMessages(history, settings.SystemPrompt),
+		Temperature: settings.Temperature,
+		TopP:        settings.TopP,
+		MaxTokens:   settings.MaxTokens,
+	}
+}
+
+/*
+openAIMessages converts stored messages into the chat completions message format.
+
+A non-empty system prompt is sent first, as a message with the system role.
+
+- Args:
+	* `history` ([]models.Message) The chat history.
+	* `systemPrompt` (string) The system prompt of the chat.
+
+- Returns:
+	([]openAIMessage) The converted messages.
+*/
+func openAIMessages(history []models.Message, systemPrompt string) []openAIMessage {
+	messages := make([]openAIMessage, 0, len(history)+1)
+	if strings.TrimSpace(systemPrompt) != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: systemPrompt})
+	}
+	for _, message := range history {
+		messages = append(messages, openAIMessage{Role: role(message.MessageType), Content: message.Message})
+	}
+	return messages
+}
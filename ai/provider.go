@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gochat/models"
+)
+
+/*
+Provider produces an AI reply for a conversation.
+
+Implementations receive the chat history in chronological order, with the latest user message last, and the
+generation settings of the chat, and return the complete text of the reply. Settings the provider cannot honour are
+ignored.
+*/
+type Provider interface {
+	Complete(ctx context.Context, history []models.Message, settings models.GenerationSettings) (string, error)
+}
+
+/*
+Options holds the settings used to construct a Provider.
+
+- Fields:
+	* `Name` (string) The provider to use: "mock", "openai" or "ollama".
+	* `BaseURL` (string) The base URL of the provider API. Defaults depend on the provider.
+	* `APIKey` (string) The API key sent as a bearer token, if the provider needs one.
+	* `Model` (string) The model name passed to the provider.
+	* `Timeout` (time.Duration) The HTTP timeout for a single request.
+	* `ContextWindow` (int) The context size of the models in tokens. Zero uses the size the provider knows for the
+	  model.
+*/
+type Options struct {
+	Name          string
+	BaseURL       string
+	APIKey        string
+	Model         string
+	Timeout       time.Duration
+	ContextWindow int
+}
+
+/*
+NewProvider creates the Provider selected by the given options.
+
+- Args:
+	* `options` (Options) The provider settings.
+
+- Returns:
+	(Provider) The configured provider, or an error if the provider name is unknown.
+*/
+func NewProvider(options Options) (Provider, error) {
+	switch strings.ToLower(options.Name) {
+	case "", "mock":
+		provider := NewMockProvider()
+		provider.contextWindow = options.ContextWindow
+		return provider, nil
+	case "openai":
+		return NewOpenAIProvider(options), nil
+	case "ollama":
+		return NewOllamaProvider(options), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", options.Name)
+	}
+}
+
+/*
+role maps a stored message type onto the role name used by chat completion APIs.
+
+- Args:
+	* `messageType` (models.MessageType) The stored message type.
+
+- Returns:
+	(string) "assistant" for AI messages and "user" for everything else.
+*/
+func role(messageType models.MessageType) string {
+	if messageType == models.AIMessageType {
+		return "assistant"
+	}
+	return "user"
+}
+
+/*
+modelFor returns the model a reply is generated with.
+
+- Args:
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `fallback` (string) The model the provider was configured with.
+
+- Returns:
+	(string) The model of the settings, or the fallback if they do not name one.
+*/
+func modelFor(settings models.GenerationSettings, fallback string) string {
+	if model := strings.TrimSpace(settings.Model); model != "" {
+		return model
+	}
+	return fallback
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"strings"
+
+	"gochat/models"
+)
+
+/*
+StreamingProvider is implemented by providers that can deliver a reply incrementally.
+
+Stream calls `emit` for every chunk of the reply as it arrives and returns the text produced so far. If `emit` returns
+an error or the context is cancelled, streaming stops and the partial text is returned together with the error.
+*/
+type StreamingProvider interface {
+	Stream(ctx context.Context, history []models.Message, settings models.GenerationSettings, emit func(chunk string) error) (string, error)
+}
+
+/*
+Stream produces a reply through the provider, streaming it when the provider supports it.
+
+Providers that do not implement StreamingProvider are asked for the complete reply, which is then emitted as a single
+chunk.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `provider` (Provider) The AI provider.
+	* `history` ([]models.Message) The chat history in chronological order.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+	* `emit` (func(string) error) Called with each chunk of the reply.
+
+- Returns:
+	(string) The reply text produced so far, and an error if the reply is incomplete.
+*/
+func Stream(ctx context.Context, provider Provider, history []models.Message, settings models.GenerationSettings, emit func(chunk string) error) (string, error) {
+	if streamer, ok := provider.(StreamingProvider); ok {
+		return streamer.Stream(ctx, history, settings, emit)
+	}
+
+	reply, err := provider.Complete(ctx, history, settings)
+	if err != nil {
+		return "", err
+	}
+	return reply, emit(reply)
+}
+
+/*
+collector accumulates streamed chunks and forwards them to an emit callback.
+*/
+type collector struct {
+	builder strings.Builder
+	emit    func(chunk string) error
+}
+
+/*
+add records a chunk and forwards it to the emit callback. Empty chunks are ignored.
+
+- Args:
+	* `chunk` (string) The chunk of the reply.
+
+- Returns:
+	(error) The error returned by the emit callback.
+*/
+func (collector *collector) add(chunk string) error {
+	if chunk == "" {
+		return nil
+	}
+	collector.builder.WriteString(chunk)
+	return collector.emit(chunk)
+}
+
+/*
+text returns everything collected so far.
+
+- Returns:
+	(string) The collected text.
+*/
+func (collector *collector) text() string {
+	return collector.builder.String()
+}
@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gochat/models"
+)
+
+const (
+	// maxTitleLength is the maximum length of a title in characters.
+	maxTitleLength = 60
+	// maxTitleWords is the number of words kept by the heuristic title.
+	maxTitleWords = 8
+	// titleTimeout bounds how long a provider may take to suggest a title.
+	titleTimeout = 15 * time.Second
+)
+
+// titlePrompt asks the model for a title. The first message of the conversation is appended.
+const titlePrompt = "Write a short title of at most six words for a conversation that starts with the message below. " +
+	"Reply with the title only, without quotes or punctuation at the end.\n\n"
+
+/*
+Titler is implemented by providers that suggest chat titles themselves rather than by answering a title prompt through
+Complete.
+
+Title returns a title for a chat that starts with the given message.
+*/
+type Titler interface {
+	Title(ctx context.Context, message string) (string, error)
+}
+
+/*
+GenerateTitle suggests a title for a chat from its first user message.
+
+Providers that implement Titler suggest the title themselves; any other provider is asked through a title prompt. If
+that fails, takes too long or returns nothing usable, a heuristic title built from the message is returned instead, so
+the result is never empty for a non-empty message.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `provider` (Provider) The AI provider.
+	* `message` (string) The first user message of the chat.
+
+- Returns:
+	(string) The suggested title.
+*/
+func GenerateTitle(ctx context.Context, provider Provider, message string) string {
+	ctx, cancel := context.WithTimeout(ctx, titleTimeout)
+	defer cancel()
+
+	var suggestion string
+	var err error
+	if titler, ok := provider.(Titler); ok {
+		suggestion, err = titler.Title(ctx, message)
+	} else {
+		prompt := []models.Message{{Message: titlePrompt + message, MessageType: models.UserMessageType}}
+		suggestion, err = provider.Complete(ctx, prompt, models.GenerationSettings{})
+	}
+	if err == nil {
+		if title := cleanTitle(suggestion); title != "" {
+			return title
+		}
+	}
+	return HeuristicTitle(message)
+}
+
+/*
+HeuristicTitle builds a title from the first words of a message.
+
+Code blocks and markdown markers are skipped and the title is shortened to a few words, ending in "…" if any words
+were left out.
+
+- Args:
+	* `message` (string) The message.
+
+- Returns:
+	(string) The title, or an empty string if the message has no usable text.
+*/
+func HeuristicTitle(message string) string {
+	var words []string
+	inCodeBlock := false
+	for _, line := range strings.Split(message, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		// One word more than the title keeps is collected, so a title cut off at a line break is marked as well.
+		for _, word := range strings.Fields(line) {
+			word = strings.Trim(word, "#*_`>")
+			if word != "" {
+				words = append(words, word)
+			}
+			if len(words) > maxTitleWords {
+				break
+			}
+		}
+		if len(words) > maxTitleWords {
+			break
+		}
+	}
+
+	truncated := len(words) > maxTitleWords
+	if truncated {
+		words = words[:maxTitleWords]
+	}
+	title := cleanTitle(strings.Join(words, " "))
+	if truncated && title != "" {
+		title += "…"
+	}
+	return title
+}
+
+/*
+cleanTitle normalises a title: it keeps the first line, removes surrounding quotes and trailing punctuation and limits
+the length.
+
+- Args:
+	* `title` (string) The raw title.
+
+- Returns:
+	(string) The cleaned title.
+*/
+func cleanTitle(title string) string {
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+	title = strings.Trim(strings.TrimSpace(title), `"'`+"`*")
+	title = strings.TrimRight(title, ".:;, ")
+	title = strings.Join(strings.Fields(title), " ")
+
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = strings.TrimSpace(string([]rune(title)[:maxTitleLength-1])) + "…"
+	}
+	return title
+}
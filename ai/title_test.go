@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHeuristicTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "short", message: "How do I read a file?", want: "How do I read a file?"},
+		{name: "exactly eight words", message: "one two three four five six seven eight", want: "one two three four five six seven eight"},
+		{name: "long line", message: "one two three four five six seven eight nine", want: "one two three four five six seven eight…"},
+		{name: "cut at a line break", message: "one two three four five six seven eight\nnine ten eleven", want: "one two three four five six seven eight…"},
+		{name: "markdown", message: "# **Heading**\n> quoted `code`", want: "Heading quoted code"},
+		{name: "code block skipped", message: "Fix this:\n```\nfunc main() {}\n```\nplease", want: "Fix this: please"},
+		{name: "only code", message: "```\nfunc main() {}\n```", want: ""},
+		{name: "empty", message: " \n ", want: ""},
+	}
+	for _, test := range tests {
+		if got := HeuristicTitle(test.message); got != test.want {
+			t.Errorf("%s: HeuristicTitle(%q) = %q, want %q", test.name, test.message, got, test.want)
+		}
+	}
+}
+
+func TestGenerateTitle(t *testing.T) {
+	message := "How do I read a file in Go? I keep getting errors."
+
+	if title := GenerateTitle(context.Background(), NewMockProvider(), message); title != HeuristicTitle(message) {
+		t.Errorf("mock title = %q, want the heuristic title %q", title, HeuristicTitle(message))
+	}
+
+	// Wrapped so only the Provider methods are visible, the provider is not a Titler and gets the title prompt.
+	provider := &promptProvider{}
+	if title := GenerateTitle(context.Background(), struct{ Provider }{provider}, message); title != "the model's summary" {
+		t.Errorf("title = %q, want the model's answer", title)
+	}
+	if len(provider.prompts) != 1 || !strings.HasPrefix(provider.prompts[0], titlePrompt) || !strings.HasSuffix(provider.prompts[0], message) {
+		t.Errorf("prompts = %q, want one title prompt for the message", provider.prompts)
+	}
+}
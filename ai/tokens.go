@@ -0,0 +1,233 @@
+package ai
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"gochat/models"
+)
+
+const (
+	// messageOverheadTokens approximates the tokens chat APIs spend on the role and framing of every message.
+	messageOverheadTokens = 4
+	// defaultContextWindow is assumed for providers and models whose context size is unknown.
+	defaultContextWindow = 8192
+	// defaultOllamaContextWindow is the context size Ollama runs models with unless told otherwise.
+	defaultOllamaContextWindow = 4096
+)
+
+/*
+TokenCounter is implemented by providers that know how their models count tokens.
+
+EstimateTokens returns the number of tokens a text is expected to take. ContextWindow returns the number of tokens
+the model selected by the settings accepts for the prompt and the reply together.
+*/
+type TokenCounter interface {
+	EstimateTokens(text string) int
+	ContextWindow(settings models.GenerationSettings) int
+}
+
+// openAIContextWindows lists the context sizes of OpenAI models by name prefix. More specific prefixes come first.
+var openAIContextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+}
+
+/*
+EstimateTokens estimates the number of tokens of a text for a provider.
+
+Providers that do not implement TokenCounter are estimated with the same heuristic as OpenAI models.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `text` (string) The text.
+
+- Returns:
+	(int) The estimated number of tokens.
+*/
+func EstimateTokens(provider Provider, text string) int {
+	if counter, ok := provider.(TokenCounter); ok {
+		return counter.EstimateTokens(text)
+	}
+	return estimateTokens(text, 4)
+}
+
+/*
+EstimateMessages estimates the number of tokens a conversation takes when it is sent to a provider.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `history` ([]models.Message) The messages.
+
+- Returns:
+	(int) The estimated number of tokens, including the framing of every message.
+*/
+func EstimateMessages(provider Provider, history []models.Message) int {
+	total := 0
+	for _, message := range history {
+		total += EstimateTokens(provider, message.Message) + messageOverheadTokens
+	}
+	return total
+}
+
+/*
+ContextWindow returns the context size of the model a provider uses for the given settings.
+
+- Args:
+	* `provider` (Provider) The AI provider.
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+
+- Returns:
+	(int) The context size in tokens.
+*/
+func ContextWindow(provider Provider, settings models.GenerationSettings) int {
+	if counter, ok := provider.(TokenCounter); ok {
+		if window := counter.ContextWindow(settings); window > 0 {
+			return window
+		}
+	}
+	return defaultContextWindow
+}
+
+/*
+estimateTokens estimates the number of tokens of a text from its characters.
+
+Tokenizers encode English at roughly four characters per token. Ideographs and kana usually take a token each, so they
+are counted separately.
+
+- Args:
+	* `text` (string) The text.
+	* `charsPerToken` (float64) The average number of other characters per token.
+
+- Returns:
+	(int) The estimated number of tokens.
+*/
+func estimateTokens(text string, charsPerToken float64) int {
+	wide, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			wide++
+		} else {
+			other++
+		}
+	}
+	return wide + int(math.Ceil(float64(other)/charsPerToken))
+}
+
+/*
+openAIContextWindow looks up the context size of an OpenAI model.
+
+- Args:
+	* `model` (string) The model name.
+
+- Returns:
+	(int) The context size in tokens, or defaultContextWindow for unknown models.
+*/
+func openAIContextWindow(model string) int {
+	model = strings.ToLower(model)
+	for _, known := range openAIContextWindows {
+		if strings.HasPrefix(model, known.prefix) {
+			return known.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+/*
+EstimateTokens estimates the number of tokens of a text for OpenAI models.
+
+- Args:
+	* `text` (string) The text.
+
+- Returns:
+	(int) The estimated number of tokens.
+*/
+func (provider *OpenAIProvider) EstimateTokens(text string) int {
+	return estimateTokens(text, 4)
+}
+
+/*
+ContextWindow returns the context size of the model selected by the settings.
+
+- Args:
+	* `settings` (models.GenerationSettings) The generation settings of the chat.
+
+- Returns:
+	(int) The configured context size, or the known size of the model.
+*/
+func (provider *OpenAIProvider) ContextWindow(settings models.GenerationSettings) int {
+	if provider.contextWindow > 0 {
+		return provider.contextWindow
+	}
+	return openAIContextWindow(modelFor(settings, provider.model))
+}
+
+/*
+EstimateTokens estimates the number of tokens of a text for Ollama models, whose tokenizers tend to produce more
+tokens than OpenAI's.
+
+- Args:
+	* `text` (string) The text.
+
+- Returns:
+	(int) The estimated number of tokens.
+*/
+func (provider *OllamaProvider) EstimateTokens(text string) int {
+	return estimateTokens(text, 3.5)
+}
+
+/*
+ContextWindow returns the context size Ollama runs the models with.
+
+- Args:
+	* `settings` (models.GenerationSettings) The generation settings of the chat. They are ignored.
+
+- Returns:
+	(int) The configured context size, or Ollama's default.
+*/
+func (provider *OllamaProvider) ContextWindow(settings models.GenerationSettings) int {
+	if provider.contextWindow > 0 {
+		return provider.contextWindow
+	}
+	return defaultOllamaContextWindow
+}
+
+/*
+EstimateTokens estimates the number of tokens of a text as if it were sent to an OpenAI model.
+
+- Args:
+	* `text` (string) The text.
+
+- Returns:
+	(int) The estimated number of tokens.
+*/
+func (provider *MockProvider) EstimateTokens(text string) int {
+	return estimateTokens(text, 4)
+}
+
+/*
+ContextWindow returns the context size the mock provider pretends to have.
+
+- Args:
+	* `settings` (models.GenerationSettings) The generation settings of the chat. They are ignored.
+
+- Returns:
+	(int) The configured context size, or defaultContextWindow.
+*/
+func (provider *MockProvider) ContextWindow(settings models.GenerationSettings) int {
+	if provider.contextWindow > 0 {
+		return provider.contextWindow
+	}
+	return defaultContextWindow
+}
@@ -0,0 +1,268 @@
+// Package attachment handles the files users attach to their messages: it checks uploads against the configured
+// limits, extracts the text of text files for the AI provider, renders thumbnails of images and keeps the files in a
+// storage backend.
+package attachment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"gochat/models"
+	"gochat/storage"
+)
+
+var (
+	// ErrTooManyFiles is returned when more files are attached to a message than the limit allows.
+	ErrTooManyFiles = errors.New("too many files")
+	// ErrFileTooLarge is returned when a file is larger than the limit allows.
+	ErrFileTooLarge = errors.New("file is too large")
+	// ErrUnsupportedType is returned when a file is neither text, a supported image nor a PDF document.
+	ErrUnsupportedType = errors.New("this type of file cannot be attached")
+)
+
+/*
+Limits bound what can be attached to a message.
+
+- Fields:
+	* `MaxFileSize` (int64) The maximum size of a single file in bytes.
+	* `MaxFiles` (int) The maximum number of files per message; 0 disables attachments.
+*/
+type Limits struct {
+	MaxFileSize int64
+	MaxFiles    int
+}
+
+/*
+RequestSize returns the largest request body a message with the maximum number of files of the maximum size can
+have, including room for the message text and the multipart framing.
+
+- Returns:
+	(int64) The size in bytes.
+*/
+func (limits Limits) RequestSize() int64 {
+	return int64(limits.MaxFiles)*limits.MaxFileSize + 1<<20
+}
+
+// Store keeps the files attached to messages in a storage backend and enforces the upload limits.
+type Store struct {
+	backend storage.Storage
+	limits  Limits
+}
+
+/*
+NewStore creates a Store.
+
+- Args:
+	* `backend` (storage.Storage) The storage the files are kept in.
+	* `limits` (Limits) The upload limits.
+
+- Returns:
+	(*Store) The attachment store.
+*/
+func NewStore(backend storage.Storage, limits Limits) *Store {
+	return &Store{backend: backend, limits: limits}
+}
+
+/*
+Limits returns the upload limits of the store.
+
+- Returns:
+	(Limits) The limits.
+*/
+func (store *Store) Limits() Limits {
+	return store.limits
+}
+
+/*
+Save checks uploaded files against the limits and stores them together with their thumbnails.
+
+The files are not linked to a message yet; the returned attachments are saved with the message they belong to. If a
+file is rejected or cannot be stored, the files stored so far are removed again.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `userID` (uint) The ID of the user uploading the files, used to group the stored files.
+	* `files` ([]*multipart.FileHeader) The uploaded files.
+
+- Returns:
+	([]models.Attachment) The attachments, ErrTooManyFiles, ErrFileTooLarge or ErrUnsupportedType wrapped with the
+	name of the file if the files are not acceptable, or another error if a file could not be read or stored.
+*/
+func (store *Store) Save(ctx context.Context, userID uint, files []*multipart.FileHeader) ([]models.Attachment, error) {
+	if len(files) > store.limits.MaxFiles {
+		return nil, fmt.Errorf("%w: at most %d per message", ErrTooManyFiles, store.limits.MaxFiles)
+	}
+
+	uploads := make([]upload, len(files))
+	for i, file := range files {
+		var err error
+		if uploads[i], err = store.read(file); err != nil {
+			return nil, err
+		}
+	}
+
+	attachments := make([]models.Attachment, 0, len(uploads))
+	for _, upload := range uploads {
+		attachment, err := store.put(ctx, userID, upload)
+		if err != nil {
+			store.Remove(ctx, attachments)
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+/*
+Open opens a stored file or thumbnail.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The storage key of the file or thumbnail.
+
+- Returns:
+	(io.ReadCloser) The content, storage.ErrNotFound if the file is missing, or another error if it could not be opened.
+*/
+func (store *Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return store.backend.Open(ctx, key)
+}
+
+/*
+Remove deletes the stored files and thumbnails of attachments.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `attachments` ([]models.Attachment) The attachments.
+
+- Returns:
+	(error) The errors of the files that could not be deleted, joined, or nil.
+*/
+func (store *Store) Remove(ctx context.Context, attachments []models.Attachment) error {
+	var problems []error
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := store.backend.Delete(ctx, key); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+/*
+read reads an uploaded file and checks it against the limits.
+
+- Args:
+	* `file` (*multipart.FileHeader) The uploaded file.
+
+- Returns:
+	(upload) The file with its type, text and thumbnail, or an error if it is not acceptable or could not be read.
+*/
+func (store *Store) read(file *multipart.FileHeader) (upload, error) {
+	name := cleanFilename(file.Filename)
+	if file.Size > store.limits.MaxFileSize {
+		return upload{}, fmt.Errorf("%s: %w (at most %s)", name, ErrFileTooLarge, FormatSize(store.limits.MaxFileSize))
+	}
+
+	opened, err := file.Open()
+	if err != nil {
+		return upload{}, err
+	}
+	defer opened.Close()
+	content, err := io.ReadAll(io.LimitReader(opened, store.limits.MaxFileSize+1))
+	if err != nil {
+		return upload{}, err
+	}
+	if int64(len(content)) > store.limits.MaxFileSize {
+		return upload{}, fmt.Errorf("%s: %w (at most %s)", name, ErrFileTooLarge, FormatSize(store.limits.MaxFileSize))
+	}
+
+	prepared, err := prepare(name, content)
+	if err != nil {
+		return upload{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return prepared, nil
+}
+
+/*
+put stores an upload and its thumbnail under new random keys.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `userID` (uint) The ID of the user uploading the file.
+	* `upload` (upload) The file.
+
+- Returns:
+	(models.Attachment) The attachment, or an error if the file could not be stored.
+*/
+func (store *Store) put(ctx context.Context, userID uint, upload upload) (models.Attachment, error) {
+	key, err := newKey(userID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachment := models.Attachment{
+		Filename:    upload.filename,
+		ContentType: upload.contentType,
+		Size:        int64(len(upload.content)),
+		StorageKey:  key,
+		Text:        upload.text,
+	}
+	if err := store.backend.Put(ctx, key, upload.content, upload.contentType); err != nil {
+		return models.Attachment{}, err
+	}
+
+	if upload.thumbnail != nil {
+		attachment.ThumbnailKey = key + thumbnailSuffix
+		if err := store.backend.Put(ctx, attachment.ThumbnailKey, upload.thumbnail, thumbnailType); err != nil {
+			store.Remove(ctx, []models.Attachment{attachment})
+			return models.Attachment{}, err
+		}
+	}
+	return attachment, nil
+}
+
+/*
+newKey creates a random storage key for a file of a user.
+
+- Args:
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	(string) The key, or an error if no random bytes were available.
+*/
+func newKey(userID uint) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(uint64(userID), 10) + "/" + hex.EncodeToString(random), nil
+}
+
+/*
+FormatSize formats a size in bytes for people, such as "1.5 MB".
+
+- Args:
+	* `size` (int64) The size in bytes.
+
+- Returns:
+	(string) The formatted size.
+*/
+func FormatSize(size int64) string {
+	switch {
+	case size < 1<<10:
+		return strconv.FormatInt(size, 10) + " B"
+	case size < 1<<20:
+		return strconv.FormatFloat(float64(size)/(1<<10), 'f', 1, 64) + " KB"
+	default:
+		return strconv.FormatFloat(float64(size)/(1<<20), 'f', 1, 64) + " MB"
+	}
+}
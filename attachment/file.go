@@ -0,0 +1,149 @@
+package attachment
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxTextLength is the number of bytes of a text file that are sent to the AI provider.
+	maxTextLength = 64 << 10
+	// maxFilenameLength is the maximum number of characters kept of a file name.
+	maxFilenameLength = 255
+	// textType is the content type text files are stored and served with, whatever their language.
+	textType = "text/plain; charset=utf-8"
+	// truncatedMarker ends the text of a file that was too long to be sent in full.
+	truncatedMarker = "\n[truncated]"
+)
+
+// binaryTypes are the types, besides text, that can be attached.
+var binaryTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// upload is an uploaded file that has been checked and prepared for storing.
+type upload struct {
+	filename    string
+	contentType string
+	content     []byte
+	text        string
+	thumbnail   []byte
+}
+
+/*
+prepare determines the type of an uploaded file from its content and extracts its text or renders its thumbnail.
+
+The type the client claims is ignored. Files that are valid UTF-8 without NUL bytes are text, whatever their
+extension, so source code of any language can be attached.
+
+- Args:
+	* `filename` (string) The cleaned name of the file.
+	* `content` ([]byte) The content of the file.
+
+- Returns:
+	(upload) The prepared file, or ErrUnsupportedType if the file is neither text nor of one of the binaryTypes.
+*/
+func prepare(filename string, content []byte) (upload, error) {
+	prepared := upload{filename: filename, content: content}
+	if isText(content) {
+		prepared.contentType = textType
+		prepared.text = extractText(content)
+		return prepared, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	if !binaryTypes[mediaType] {
+		return upload{}, ErrUnsupportedType
+	}
+	prepared.contentType = mediaType
+	if IsImage(mediaType) {
+		prepared.thumbnail = thumbnail(content)
+	}
+	return prepared, nil
+}
+
+/*
+IsImage reports whether files of a content type are shown as images.
+
+- Args:
+	* `contentType` (string) The content type of the file.
+
+- Returns:
+	(bool) True for the supported image types.
+*/
+func IsImage(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") && binaryTypes[contentType]
+}
+
+/*
+isText reports whether content is text: valid UTF-8 without NUL bytes.
+
+- Args:
+	* `content` ([]byte) The content of the file.
+
+- Returns:
+	(bool) True if the content is text.
+*/
+func isText(content []byte) bool {
+	return utf8.Valid(content) && bytes.IndexByte(content, 0) < 0
+}
+
+/*
+extractText returns the text of a text file that is sent to the AI provider, cut at maxTextLength bytes.
+
+- Args:
+	* `content` ([]byte) The content of the file, valid UTF-8.
+
+- Returns:
+	(string) The text, ending in truncatedMarker if it was cut.
+*/
+func extractText(content []byte) string {
+	text := strings.TrimPrefix(string(content), "\uFEFF")
+	if len(text) <= maxTextLength {
+		return text
+	}
+
+	cut := maxTextLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + truncatedMarker
+}
+
+/*
+cleanFilename turns the name a client sent for a file into one that is safe to show and to offer for download.
+
+Directories are dropped, control characters removed and the length is limited.
+
+- Args:
+	* `name` (string) The name sent by the client.
+
+- Returns:
+	(string) The cleaned name, "file" if nothing is left of it.
+*/
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "file"
+	}
+	return name
+}
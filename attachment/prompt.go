@@ -0,0 +1,56 @@
+package attachment
+
+import (
+	"fmt"
+	"strings"
+
+	"gochat/models"
+)
+
+/*
+WithText returns the messages as they are sent to the AI provider: the text of every attached text file follows the
+text of its message, and other attached files are named so the model knows they exist.
+
+The messages passed in are not changed.
+
+- Args:
+	* `messages` ([]models.Message) The messages with their attachments loaded.
+
+- Returns:
+	([]models.Message) Copies of the messages with attachments, and the other messages as they are.
+*/
+func WithText(messages []models.Message) []models.Message {
+	expanded := make([]models.Message, len(messages))
+	for i, message := range messages {
+		expanded[i] = message
+		if len(message.Attachments) > 0 {
+			expanded[i].Message = PromptText(message)
+		}
+	}
+	return expanded
+}
+
+/*
+PromptText returns the text of a message followed by its attachments, each wrapped in a `<file>` element.
+
+- Args:
+	* `message` (models.Message) The message with its attachments loaded.
+
+- Returns:
+	(string) The text sent to the AI provider.
+*/
+func PromptText(message models.Message) string {
+	var text strings.Builder
+	text.WriteString(message.Message)
+	for _, attachment := range message.Attachments {
+		if text.Len() > 0 {
+			text.WriteString("\n\n")
+		}
+		if attachment.ContentType != textType {
+			fmt.Fprintf(&text, "<file name=%q type=%q>(not readable as text)</file>", attachment.Filename, attachment.ContentType)
+			continue
+		}
+		fmt.Fprintf(&text, "<file name=%q>\n%s\n</file>", attachment.Filename, attachment.Text)
+	}
+	return text.String()
+}
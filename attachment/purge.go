@@ -0,0 +1,53 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+
+	"gochat/database"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize is the number of purged attachments whose files are removed per round.
+const purgeBatchSize = 100
+
+/*
+RemovePurged removes the files of the attachments of purged chats from the storage and then deletes the attachments.
+
+Attachments whose files cannot be removed are kept and tried again on the next call.
+
+- Args:
+	* `ctx` (context.Context) The context of the job.
+	* `db` (*gorm.DB) The database connection.
+	* `store` (*Store) The attachment store.
+
+- Returns:
+	(int) The number of attachments removed, and an error if a file or the attachments could not be deleted.
+*/
+func RemovePurged(ctx context.Context, db *gorm.DB, store *Store) (int, error) {
+	removed := 0
+	for {
+		attachments, err := database.GetPurgedAttachments(db, purgeBatchSize)
+		if err != nil || len(attachments) == 0 {
+			return removed, err
+		}
+
+		var ids []uint
+		var problems []error
+		for i, attachment := range attachments {
+			if err := store.Remove(ctx, attachments[i:i+1]); err != nil {
+				problems = append(problems, err)
+				continue
+			}
+			ids = append(ids, attachment.ID)
+		}
+		if err := database.ForgetAttachments(db, ids); err != nil {
+			return removed, err
+		}
+		removed += len(ids)
+		if len(problems) > 0 || len(attachments) < purgeBatchSize {
+			return removed, errors.Join(problems...)
+		}
+	}
+}
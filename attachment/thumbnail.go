@@ -0,0 +1,105 @@
+package attachment
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+)
+
+const (
+	// thumbnailSize is the largest width and height of a thumbnail in pixels.
+	thumbnailSize = 256
+	// thumbnailSamples is the number of source pixels averaged along each axis for a thumbnail pixel.
+	thumbnailSamples = 4
+	// maxThumbnailPixels bounds the size of the images thumbnails are rendered for, since the whole image is decoded.
+	maxThumbnailPixels = 50_000_000
+	// thumbnailSuffix is appended to the storage key of a file to get the key of its thumbnail.
+	thumbnailSuffix = ".thumb.png"
+	// thumbnailType is the content type of thumbnails.
+	thumbnailType = "image/png"
+)
+
+/*
+thumbnail renders a PNG thumbnail of an image that fits into thumbnailSize pixels.
+
+Images that cannot be decoded, such as WebP images, or that are too large to decode get no thumbnail; they are shown
+as a download link instead.
+
+- Args:
+	* `content` ([]byte) The content of the image file.
+
+- Returns:
+	([]byte) The encoded thumbnail, or nil if none could be rendered.
+*/
+func thumbnail(content []byte) []byte {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || config.Width == 0 || config.Height == 0 || config.Width*config.Height > maxThumbnailPixels {
+		return nil
+	}
+	source, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		log.Println("error", err, "thumbnail: decode image")
+		return nil
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, scaleDown(source, thumbnailSize)); err != nil {
+		log.Println("error", err, "thumbnail: encode thumbnail")
+		return nil
+	}
+	return encoded.Bytes()
+}
+
+/*
+scaleDown scales an image down to fit into a square, keeping its aspect ratio. Images that already fit keep their size.
+
+Every pixel of the result averages a grid of thumbnailSamples by thumbnailSamples source pixels spread over the area
+it covers, which is much cheaper than averaging the whole area and close enough for a thumbnail.
+
+- Args:
+	* `source` (image.Image) The image.
+	* `size` (int) The width and height of the square.
+
+- Returns:
+	(*image.RGBA) The scaled image.
+*/
+func scaleDown(source image.Image, size int) *image.RGBA {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaleX := float64(bounds.Dx()) / float64(width)
+	scaleY := float64(bounds.Dy()) / float64(height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b, a uint32
+			for sy := 0; sy < thumbnailSamples; sy++ {
+				for sx := 0; sx < thumbnailSamples; sx++ {
+					px := bounds.Min.X + int((float64(x)+(float64(sx)+0.5)/thumbnailSamples)*scaleX)
+					py := bounds.Min.Y + int((float64(y)+(float64(sy)+0.5)/thumbnailSamples)*scaleY)
+					pr, pg, pb, pa := source.At(px, py).RGBA()
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+				}
+			}
+			samples := uint32(thumbnailSamples * thumbnailSamples)
+			scaled.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / samples >> 8),
+				G: uint8(g / samples >> 8),
+				B: uint8(b / samples >> 8),
+				A: uint8(a / samples >> 8),
+			})
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,153 @@
+// Package client is a Go client for the gochat HTTP API.
+//
+// The request and response types and the methods in client_gen.go are generated from routes/openapi.json; run
+// `go generate ./client` after changing the document. Only the operations that exchange JSON are generated, the pages
+// and fragments of the web interface are left out.
+package client
+
+//go:generate go run ./internal/gen -spec ../routes/openapi.json -out client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls a gochat server on behalf of one user.
+type Client struct {
+	// BaseURL is the address of the server, such as http://localhost:8080.
+	BaseURL string
+	// Token is an API token created at /user/tokens. It is sent as a bearer token when it is set.
+	Token string
+	// HTTPClient sends the requests. Give it a cookie jar to authenticate with a session from LoginUser instead of a
+	// token.
+	HTTPClient *http.Client
+}
+
+// Error is an error response of the server.
+type Error struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the machine readable error code, empty if the response had no JSON error body.
+	Code string
+	// Message is the human readable error message.
+	Message string
+}
+
+/*
+New creates a client for a server.
+
+- Args:
+	* `baseURL` (string) The address of the server.
+	* `token` (string) An API token, or an empty string to make unauthenticated requests.
+
+- Returns:
+	(*Client) The client.
+*/
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("gochat: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("gochat: %d: %s", e.StatusCode, e.Message)
+}
+
+/*
+do sends a request and decodes the JSON response.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `method` (string) The HTTP method.
+	* `path` (string) The path of the endpoint, with the path parameters filled in.
+	* `query` (url.Values) The query parameters, or nil.
+	* `body` (any) The value sent as the JSON request body, or nil for no body.
+	* `out` (any) A pointer the response body is decoded into, or nil to discard it.
+
+- Returns:
+	(error) An *Error for an error response, or another error if the request failed.
+*/
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	target := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("gochat: encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return decodeError(response)
+	}
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("gochat: decode response: %w", err)
+	}
+	return nil
+}
+
+/*
+decodeError reads an error response.
+
+Every endpoint sends `{"error": {"code", "message"}}`; a response without it, such as one from a proxy, keeps the
+status text as its message.
+
+- Args:
+	* `response` (*http.Response) The error response.
+
+- Returns:
+	(*Error) The error.
+*/
+func decodeError(response *http.Response) *Error {
+	apiErr := &Error{StatusCode: response.StatusCode, Message: http.StatusText(response.StatusCode)}
+
+	var envelope struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil || envelope.Error.Message == "" {
+		return apiErr
+	}
+	apiErr.Code, apiErr.Message = envelope.Error.Code, envelope.Error.Message
+	return apiErr
+}
@@ -0,0 +1,1108 @@
+// Code generated by go run ./internal/gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Attachment: A file attached to a message. The text of text files is sent to the AI provider with the message.
+type Attachment struct {
+	ID       int64  `json:"id"`
+	Filename string `json:"filename"`
+	// Detected from the content: `text/plain; charset=utf-8` for text and code, or a PNG, JPEG, GIF, WebP or PDF type.
+	ContentType string `json:"content_type"`
+	// Size of the file in bytes.
+	Size int64 `json:"size"`
+	// Where the file is downloaded.
+	URL string `json:"url"`
+	// Where a PNG thumbnail of an image is downloaded; null for other files.
+	ThumbnailURL *string `json:"thumbnail_url"`
+}
+
+// Chat: A chat. Messages holds the active branch and is only included when a single chat is requested.
+type Chat struct {
+	ID int64 `json:"id"`
+	// Title set by the user or generated from the first message, empty until then.
+	Title string `json:"title"`
+	// Title shown in the interface.
+	DisplayTitle string `json:"display_title"`
+	// Last message of the branch that is shown.
+	ActiveMessageID *int64 `json:"active_message_id"`
+	// Folder the chat is filed in, null if it is in none.
+	FolderID *int64 `json:"folder_id"`
+	// Pinned chats are listed first.
+	Pinned    bool               `json:"pinned"`
+	Tags      []string           `json:"tags"`
+	Settings  GenerationSettings `json:"settings"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Messages  []Message          `json:"messages,omitempty"`
+	// Set when the messages are a page with older messages before it; pass it as `before` to get them.
+	NextBefore int64 `json:"next_before,omitempty"`
+}
+
+// ChatInput: A new chat. Without settings the chat starts with the user's defaults.
+type ChatInput struct {
+	Title    string              `json:"title,omitempty"`
+	Settings *GenerationSettings `json:"settings,omitempty"`
+}
+
+// ChatList is the ChatList schema.
+type ChatList struct {
+	Chats []Chat `json:"chats"`
+	// Pass as `before` to get the next page. Missing on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ChatTitle is the ChatTitle schema.
+type ChatTitle struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// ContextUsage: How much of the model's context window a chat uses. Once tokens exceed the budget, the next reply summarises older messages.
+type ContextUsage struct {
+	// Estimated tokens of the system prompt, the summary and the messages the next reply is generated from.
+	Tokens int64 `json:"tokens"`
+	// Tokens available for them; the rest of the window is kept for the reply.
+	Budget int64 `json:"budget"`
+	// Context size of the model.
+	Window int64 `json:"window"`
+	// Share of the window in use.
+	Percent int64 `json:"percent"`
+	// Number of messages of the active branch replaced by the summary.
+	SummarizedMessages int64 `json:"summarized_messages"`
+	// Rolling summary sent in place of the oldest messages. Empty for none.
+	Summary string `json:"summary"`
+}
+
+// CreatedToken is the CreatedToken schema.
+type CreatedToken struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// The token in plain text. It is only shown once.
+	Token string `json:"token"`
+}
+
+// Credentials is the Credentials schema.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Exchange: A user message and the AI reply to it.
+type Exchange struct {
+	Message Message `json:"message"`
+	Reply   Message `json:"reply"`
+}
+
+// Folder: A user-defined group of chats.
+type Folder struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FolderAssignment: The folder to file a chat into.
+type FolderAssignment struct {
+	// The folder; null or 0 takes the chat out of its folder.
+	FolderID *int64 `json:"folder_id,omitempty"`
+}
+
+// FolderInput is the FolderInput schema.
+type FolderInput struct {
+	Name string `json:"name"`
+}
+
+// FolderList is the FolderList schema.
+type FolderList struct {
+	Folders []Folder `json:"folders"`
+}
+
+// GenerationSettings: Parameters AI replies are generated with. Null numbers leave the choice to the provider.
+type GenerationSettings struct {
+	// Sent to the model before the conversation. Empty for none.
+	SystemPrompt string `json:"system_prompt"`
+	// Model name. Empty for the model the server is configured with.
+	Model       string   `json:"model"`
+	Temperature *float64 `json:"temperature"`
+	TopP        *float64 `json:"top_p"`
+	// Maximum length of a reply.
+	MaxTokens *int64 `json:"max_tokens"`
+}
+
+// ImportReport is the ImportReport schema.
+type ImportReport struct {
+	DryRun   bool                 `json:"dry_run"`
+	Chats    []ImportedChatReport `json:"chats"`
+	Messages int64                `json:"messages"`
+	Skipped  int64                `json:"skipped"`
+}
+
+// ImportedChatReport is the ImportedChatReport schema.
+type ImportedChatReport struct {
+	// ID of the new chat, omitted in a dry run.
+	ID       int64  `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Messages int64  `json:"messages"`
+	Skipped  int64  `json:"skipped"`
+}
+
+// LogoutStatus is the LogoutStatus schema.
+type LogoutStatus struct {
+	Status string `json:"status"`
+}
+
+// Message is the Message schema.
+type Message struct {
+	ID     int64 `json:"id"`
+	ChatID int64 `json:"chat_id"`
+	// Message this message follows; siblings are alternative branches.
+	ParentID    *int64       `json:"parent_id"`
+	MessageType string       `json:"message_type"`
+	Message     string       `json:"message"`
+	Attachments []Attachment `json:"attachments"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// MessageInput is the MessageInput schema.
+type MessageInput struct {
+	Message string `json:"message"`
+}
+
+// MessageList is the MessageList schema.
+type MessageList struct {
+	Messages []Message `json:"messages"`
+	// Pass as `before` to get the older messages. Missing on the page that starts the chat.
+	NextBefore int64 `json:"next_before,omitempty"`
+}
+
+// MessageUpload: A message with attached files, sent as a multipart form.
+type MessageUpload struct {
+	// The text of the message; may be empty when files are attached.
+	Message string   `json:"message,omitempty"`
+	Files   []string `json:"files,omitempty"`
+}
+
+// PromptTemplate: A reusable prompt in the template library.
+type PromptTemplate struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Go text/template source; its fields are the variables. Loops (`range`), `define`, `block`, `template` and `printf` are not allowed.
+	Body string `json:"body"`
+	// Whether every user can use the template.
+	Shared bool `json:"shared"`
+	// Username of the owner.
+	Owner string `json:"owner"`
+	// Whether the current user owns the template and may change it.
+	Owned     bool      `json:"owned"`
+	Variables []string  `json:"variables"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PromptTemplateInput is the PromptTemplateInput schema.
+type PromptTemplateInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Body        string `json:"body"`
+	Shared      bool   `json:"shared,omitempty"`
+}
+
+// PromptTemplateList is the PromptTemplateList schema.
+type PromptTemplateList struct {
+	Templates []PromptTemplate `json:"templates"`
+}
+
+// PurgeResult is the PurgeResult schema.
+type PurgeResult struct {
+	// Number of chats deleted for good.
+	Purged int64 `json:"purged"`
+}
+
+// RenderInput: Values of the template variables and what to do with the text.
+type RenderInput struct {
+	Variables map[string]string `json:"variables,omitempty"`
+	Target    string            `json:"target,omitempty"`
+}
+
+// RenderResult: The expanded text and, for the chat target, the new chat.
+type RenderResult struct {
+	Text string `json:"text"`
+	Chat *Chat  `json:"chat,omitempty"`
+}
+
+// SearchHit is the SearchHit schema.
+type SearchHit struct {
+	ChatID      int64  `json:"chat_id"`
+	ChatTitle   string `json:"chat_title"`
+	MessageID   int64  `json:"message_id"`
+	MessageType string `json:"message_type"`
+	// HTML excerpt with the matches wrapped in mark elements.
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchResults is the SearchResults schema.
+type SearchResults struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// TagCount is the TagCount schema.
+type TagCount struct {
+	Name string `json:"name"`
+	// Number of chats with the tag, not counting the trash.
+	Chats int64 `json:"chats"`
+}
+
+// TagList is the TagList schema.
+type TagList struct {
+	Tags []TagCount `json:"tags"`
+}
+
+// TagsInput: The new tags of a chat. They are trimmed and lower-cased; blank and duplicate tags are dropped.
+type TagsInput struct {
+	Tags []string `json:"tags"`
+}
+
+// TitleInput is the TitleInput schema.
+type TitleInput struct {
+	Title string `json:"title"`
+}
+
+// Token is the Token schema.
+type Token struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// TokenInput is the TokenInput schema.
+type TokenInput struct {
+	Name string `json:"name,omitempty"`
+}
+
+// TokenList is the TokenList schema.
+type TokenList struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// TrashList is the TrashList schema.
+type TrashList struct {
+	Chats []TrashedChat `json:"chats"`
+}
+
+// TrashedChat: A deleted chat in the trash.
+type TrashedChat struct {
+	ID           int64     `json:"id"`
+	Title        string    `json:"title"`
+	DisplayTitle string    `json:"display_title"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	// When the chat is deleted for good; null if deleted chats are kept until the trash is emptied.
+	PurgeAt *time.Time `json:"purge_at"`
+}
+
+// User is the User schema.
+type User struct {
+	ID              int64              `json:"id"`
+	Username        string             `json:"username"`
+	IsAdmin         bool               `json:"is_admin"`
+	DefaultSettings GenerationSettings `json:"default_settings"`
+	CreatedAt       time.Time          `json:"created_at"`
+}
+
+// UserSummary is the UserSummary schema.
+type UserSummary struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// GetOpenAPISpec sends GET /api/openapi.json: Get this document.
+func (c *Client) GetOpenAPISpec(ctx context.Context) (json.RawMessage, error) {
+	path := "/api/openapi.json"
+	var out json.RawMessage
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// APIListChatsParams are the query parameters of APIListChats.
+type APIListChatsParams struct {
+	// The `next_cursor` of the previous page.
+	Before string
+	// Chats per page.
+	Limit int64
+	// Only chats in this folder: a folder ID, or `none` for chats outside any folder.
+	Folder string
+	// Only chats with this tag.
+	Tag string
+}
+
+// APIListChats sends GET /api/v1/chats: List chats.
+func (c *Client) APIListChats(ctx context.Context, params *APIListChatsParams) (*ChatList, error) {
+	path := "/api/v1/chats"
+	query := url.Values{}
+	if params != nil {
+		if params.Before != "" {
+			query.Set("before", params.Before)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.FormatInt(params.Limit, 10))
+		}
+		if params.Folder != "" {
+			query.Set("folder", params.Folder)
+		}
+		if params.Tag != "" {
+			query.Set("tag", params.Tag)
+		}
+	}
+	var out ChatList
+	if err := c.do(ctx, "GET", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APICreateChat sends POST /api/v1/chats: Create a chat.
+func (c *Client) APICreateChat(ctx context.Context, body *ChatInput) (*Chat, error) {
+	path := "/api/v1/chats"
+	var out Chat
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIGetChat sends GET /api/v1/chats/{chat_id}: Get a chat.
+func (c *Client) APIGetChat(ctx context.Context, chatID int64) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d", chatID)
+	var out Chat
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIRenameChat sends PATCH /api/v1/chats/{chat_id}: Rename a chat.
+func (c *Client) APIRenameChat(ctx context.Context, chatID int64, body TitleInput) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d", chatID)
+	var out Chat
+	if err := c.do(ctx, "PATCH", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIDeleteChat sends DELETE /api/v1/chats/{chat_id}: Move a chat to the trash.
+func (c *Client) APIDeleteChat(ctx context.Context, chatID int64) error {
+	path := fmt.Sprintf("/api/v1/chats/%d", chatID)
+	return c.do(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// APIGetChatContext sends GET /api/v1/chats/{chat_id}/context: Get the context window usage of a chat.
+func (c *Client) APIGetChatContext(ctx context.Context, chatID int64) (*ContextUsage, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/context", chatID)
+	var out ContextUsage
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIMoveChat sends PUT /api/v1/chats/{chat_id}/folder: File a chat into a folder.
+// Moving a chat does not change its `updated_at`.
+func (c *Client) APIMoveChat(ctx context.Context, chatID int64, body FolderAssignment) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/folder", chatID)
+	var out Chat
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIListMessagesParams are the query parameters of APIListMessages.
+type APIListMessagesParams struct {
+	// The `next_before` of the previous page: the ID of the oldest message already shown.
+	Before int64
+	// Messages per page.
+	Limit int64
+}
+
+// APIListMessages sends GET /api/v1/chats/{chat_id}/messages: List the messages of the active branch.
+func (c *Client) APIListMessages(ctx context.Context, chatID int64, params *APIListMessagesParams) (*MessageList, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/messages", chatID)
+	query := url.Values{}
+	if params != nil {
+		if params.Before != 0 {
+			query.Set("before", strconv.FormatInt(params.Before, 10))
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.FormatInt(params.Limit, 10))
+		}
+	}
+	var out MessageList
+	if err := c.do(ctx, "GET", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APISendMessage sends POST /api/v1/chats/{chat_id}/messages: Send a message and wait for the reply.
+func (c *Client) APISendMessage(ctx context.Context, chatID int64, body MessageInput) (*Exchange, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/messages", chatID)
+	var out Exchange
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIEditMessage sends PUT /api/v1/chats/{chat_id}/messages/{message_id}: Edit a user message and wait for the reply.
+func (c *Client) APIEditMessage(ctx context.Context, chatID int64, messageID int64, body MessageInput) (*Exchange, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/messages/%d", chatID, messageID)
+	var out Exchange
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIRegenerateMessage sends POST /api/v1/chats/{chat_id}/messages/{message_id}/regenerate: Regenerate an AI reply and wait for it.
+func (c *Client) APIRegenerateMessage(ctx context.Context, chatID int64, messageID int64) (*Exchange, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/messages/%d/regenerate", chatID, messageID)
+	var out Exchange
+	if err := c.do(ctx, "POST", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIPinChat sends PUT /api/v1/chats/{chat_id}/pin: Pin a chat.
+func (c *Client) APIPinChat(ctx context.Context, chatID int64) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/pin", chatID)
+	var out Chat
+	if err := c.do(ctx, "PUT", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIUnpinChat sends DELETE /api/v1/chats/{chat_id}/pin: Unpin a chat.
+func (c *Client) APIUnpinChat(ctx context.Context, chatID int64) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/pin", chatID)
+	var out Chat
+	if err := c.do(ctx, "DELETE", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIGetChatSettings sends GET /api/v1/chats/{chat_id}/settings: Get the generation settings of a chat.
+func (c *Client) APIGetChatSettings(ctx context.Context, chatID int64) (*GenerationSettings, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/settings", chatID)
+	var out GenerationSettings
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIUpdateChatSettings sends PUT /api/v1/chats/{chat_id}/settings: Change the generation settings of a chat.
+func (c *Client) APIUpdateChatSettings(ctx context.Context, chatID int64, body GenerationSettings) (*GenerationSettings, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/settings", chatID)
+	var out GenerationSettings
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APITagChat sends PUT /api/v1/chats/{chat_id}/tags: Replace the tags of a chat.
+func (c *Client) APITagChat(ctx context.Context, chatID int64, body TagsInput) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/chats/%d/tags", chatID)
+	var out Chat
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIListFolders sends GET /api/v1/folders: List folders.
+func (c *Client) APIListFolders(ctx context.Context) (*FolderList, error) {
+	path := "/api/v1/folders"
+	var out FolderList
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APICreateFolder sends POST /api/v1/folders: Create a folder.
+func (c *Client) APICreateFolder(ctx context.Context, body FolderInput) (*Folder, error) {
+	path := "/api/v1/folders"
+	var out Folder
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIRenameFolder sends PATCH /api/v1/folders/{folder_id}: Rename a folder.
+func (c *Client) APIRenameFolder(ctx context.Context, folderID int64, body FolderInput) (*Folder, error) {
+	path := fmt.Sprintf("/api/v1/folders/%d", folderID)
+	var out Folder
+	if err := c.do(ctx, "PATCH", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIDeleteFolder sends DELETE /api/v1/folders/{folder_id}: Delete a folder.
+func (c *Client) APIDeleteFolder(ctx context.Context, folderID int64) error {
+	path := fmt.Sprintf("/api/v1/folders/%d", folderID)
+	return c.do(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// APIGetMe sends GET /api/v1/me: Get the current user.
+func (c *Client) APIGetMe(ctx context.Context) (*User, error) {
+	path := "/api/v1/me"
+	var out User
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIGetDefaultSettings sends GET /api/v1/me/settings: Get the settings new chats start with.
+func (c *Client) APIGetDefaultSettings(ctx context.Context) (*GenerationSettings, error) {
+	path := "/api/v1/me/settings"
+	var out GenerationSettings
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIUpdateDefaultSettings sends PUT /api/v1/me/settings: Change the settings new chats start with.
+func (c *Client) APIUpdateDefaultSettings(ctx context.Context, body GenerationSettings) (*GenerationSettings, error) {
+	path := "/api/v1/me/settings"
+	var out GenerationSettings
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APISearchParams are the query parameters of APISearch.
+type APISearchParams struct {
+	// Search terms.
+	Q string
+	// Maximum number of hits.
+	Limit int64
+}
+
+// APISearch sends GET /api/v1/search: Search messages.
+func (c *Client) APISearch(ctx context.Context, params *APISearchParams) (*SearchResults, error) {
+	path := "/api/v1/search"
+	query := url.Values{}
+	if params != nil {
+		if params.Q != "" {
+			query.Set("q", params.Q)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.FormatInt(params.Limit, 10))
+		}
+	}
+	var out SearchResults
+	if err := c.do(ctx, "GET", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIListTags sends GET /api/v1/tags: List tags.
+func (c *Client) APIListTags(ctx context.Context) (*TagList, error) {
+	path := "/api/v1/tags"
+	var out TagList
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIListTrash sends GET /api/v1/trash: List deleted chats.
+func (c *Client) APIListTrash(ctx context.Context) (*TrashList, error) {
+	path := "/api/v1/trash"
+	var out TrashList
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIEmptyTrash sends DELETE /api/v1/trash: Empty the trash.
+func (c *Client) APIEmptyTrash(ctx context.Context) (*PurgeResult, error) {
+	path := "/api/v1/trash"
+	var out PurgeResult
+	if err := c.do(ctx, "DELETE", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// APIRestoreChat sends POST /api/v1/trash/{chat_id}/restore: Restore a deleted chat.
+func (c *Client) APIRestoreChat(ctx context.Context, chatID int64) (*Chat, error) {
+	path := fmt.Sprintf("/api/v1/trash/%d/restore", chatID)
+	var out Chat
+	if err := c.do(ctx, "POST", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportChatsParams are the query parameters of ImportChats.
+type ImportChatsParams struct {
+	// Report what would be imported without saving it.
+	DryRun bool
+}
+
+// ImportChats sends POST /chat/import: Import chats.
+func (c *Client) ImportChats(ctx context.Context, params *ImportChatsParams, body json.RawMessage) (*ImportReport, error) {
+	path := "/chat/import"
+	query := url.Values{}
+	if params != nil {
+		if params.DryRun {
+			query.Set("dry_run", "true")
+		}
+	}
+	var out ImportReport
+	if err := c.do(ctx, "POST", path, query, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetChatParams are the query parameters of GetChat.
+type GetChatParams struct {
+	// Message of the active branch to show; the first page reaches back to it. Messages on other branches are ignored, switch to them with selectBranch.
+	Message int64
+	// The `next_before` of the previous page: the ID of the oldest message already shown.
+	Before int64
+	// Messages per page.
+	Limit int64
+}
+
+// GetChat sends GET /chat/{chat_id}: Get a chat.
+func (c *Client) GetChat(ctx context.Context, chatID int64, params *GetChatParams) (*Chat, error) {
+	path := fmt.Sprintf("/chat/%d", chatID)
+	query := url.Values{}
+	if params != nil {
+		if params.Message != 0 {
+			query.Set("message", strconv.FormatInt(params.Message, 10))
+		}
+		if params.Before != 0 {
+			query.Set("before", strconv.FormatInt(params.Before, 10))
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.FormatInt(params.Limit, 10))
+		}
+	}
+	var out Chat
+	if err := c.do(ctx, "GET", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RenameChat sends PATCH /chat/{chat_id}: Rename a chat.
+func (c *Client) RenameChat(ctx context.Context, chatID int64, body TitleInput) (*ChatTitle, error) {
+	path := fmt.Sprintf("/chat/%d", chatID)
+	var out ChatTitle
+	if err := c.do(ctx, "PATCH", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteChat sends DELETE /chat/{chat_id}: Move a chat to the trash.
+func (c *Client) DeleteChat(ctx context.Context, chatID int64) error {
+	path := fmt.Sprintf("/chat/%d", chatID)
+	return c.do(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// MoveChat sends PUT /chat/{chat_id}/folder: File a chat into a folder.
+func (c *Client) MoveChat(ctx context.Context, chatID int64, body FolderAssignment) (*Chat, error) {
+	path := fmt.Sprintf("/chat/%d/folder", chatID)
+	var out Chat
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PinChatParams are the query parameters of PinChat.
+type PinChatParams struct {
+	// The tag the sidebar is filtered by; the re-rendered sections apply it.
+	Tag string
+}
+
+// PinChat sends PUT /chat/{chat_id}/pin: Pin a chat.
+func (c *Client) PinChat(ctx context.Context, chatID int64, params *PinChatParams) (*Chat, error) {
+	path := fmt.Sprintf("/chat/%d/pin", chatID)
+	query := url.Values{}
+	if params != nil {
+		if params.Tag != "" {
+			query.Set("tag", params.Tag)
+		}
+	}
+	var out Chat
+	if err := c.do(ctx, "PUT", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UnpinChatParams are the query parameters of UnpinChat.
+type UnpinChatParams struct {
+	// The tag the sidebar is filtered by; the re-rendered sections apply it.
+	Tag string
+}
+
+// UnpinChat sends DELETE /chat/{chat_id}/pin: Unpin a chat.
+func (c *Client) UnpinChat(ctx context.Context, chatID int64, params *UnpinChatParams) (*Chat, error) {
+	path := fmt.Sprintf("/chat/%d/pin", chatID)
+	query := url.Values{}
+	if params != nil {
+		if params.Tag != "" {
+			query.Set("tag", params.Tag)
+		}
+	}
+	var out Chat
+	if err := c.do(ctx, "DELETE", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetChatSettings sends GET /chat/{chat_id}/settings: Get the generation settings of a chat.
+func (c *Client) GetChatSettings(ctx context.Context, chatID int64) (*GenerationSettings, error) {
+	path := fmt.Sprintf("/chat/%d/settings", chatID)
+	var out GenerationSettings
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateChatSettings sends PUT /chat/{chat_id}/settings: Change the generation settings of a chat.
+// Form fields that are empty, and JSON properties that are missing or null, are cleared.
+func (c *Client) UpdateChatSettings(ctx context.Context, chatID int64, body GenerationSettings) (*GenerationSettings, error) {
+	path := fmt.Sprintf("/chat/%d/settings", chatID)
+	var out GenerationSettings
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TagChat sends PUT /chat/{chat_id}/tags: Replace the tags of a chat.
+// Commas also separate tags, so a form can send them in one text field.
+func (c *Client) TagChat(ctx context.Context, chatID int64, body TagsInput) (*Chat, error) {
+	path := fmt.Sprintf("/chat/%d/tags", chatID)
+	var out Chat
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateFolder sends POST /folders: Create a folder.
+func (c *Client) CreateFolder(ctx context.Context, body FolderInput) (*Folder, error) {
+	path := "/folders"
+	var out Folder
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RenameFolder sends PATCH /folders/{folder_id}: Rename a folder.
+func (c *Client) RenameFolder(ctx context.Context, folderID int64, body FolderInput) (*Folder, error) {
+	path := fmt.Sprintf("/folders/%d", folderID)
+	var out Folder
+	if err := c.do(ctx, "PATCH", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteFolderParams are the query parameters of DeleteFolder.
+type DeleteFolderParams struct {
+	// The tag the sidebar is filtered by; the re-rendered sections apply it.
+	Tag string
+}
+
+// DeleteFolder sends DELETE /folders/{folder_id}: Delete a folder.
+func (c *Client) DeleteFolder(ctx context.Context, folderID int64, params *DeleteFolderParams) error {
+	path := fmt.Sprintf("/folders/%d", folderID)
+	query := url.Values{}
+	if params != nil {
+		if params.Tag != "" {
+			query.Set("tag", params.Tag)
+		}
+	}
+	return c.do(ctx, "DELETE", path, query, nil, nil)
+}
+
+// SearchMessagesParams are the query parameters of SearchMessages.
+type SearchMessagesParams struct {
+	// Search terms.
+	Q string
+	// Maximum number of hits.
+	Limit int64
+}
+
+// SearchMessages sends GET /search: Search messages.
+func (c *Client) SearchMessages(ctx context.Context, params *SearchMessagesParams) (*SearchResults, error) {
+	path := "/search"
+	query := url.Values{}
+	if params != nil {
+		if params.Q != "" {
+			query.Set("q", params.Q)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.FormatInt(params.Limit, 10))
+		}
+	}
+	var out SearchResults
+	if err := c.do(ctx, "GET", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPromptTemplates sends GET /templates: List prompt templates.
+func (c *Client) ListPromptTemplates(ctx context.Context) (*PromptTemplateList, error) {
+	path := "/templates"
+	var out PromptTemplateList
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePromptTemplate sends POST /templates: Create a prompt template.
+func (c *Client) CreatePromptTemplate(ctx context.Context, body PromptTemplateInput) (*PromptTemplate, error) {
+	path := "/templates"
+	var out PromptTemplate
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPromptTemplate sends GET /templates/{template_id}: Get a prompt template.
+func (c *Client) GetPromptTemplate(ctx context.Context, templateID int64) (*PromptTemplate, error) {
+	path := fmt.Sprintf("/templates/%d", templateID)
+	var out PromptTemplate
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdatePromptTemplate sends PUT /templates/{template_id}: Change a prompt template.
+func (c *Client) UpdatePromptTemplate(ctx context.Context, templateID int64, body PromptTemplateInput) (*PromptTemplate, error) {
+	path := fmt.Sprintf("/templates/%d", templateID)
+	var out PromptTemplate
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeletePromptTemplate sends DELETE /templates/{template_id}: Delete a prompt template.
+func (c *Client) DeletePromptTemplate(ctx context.Context, templateID int64) error {
+	path := fmt.Sprintf("/templates/%d", templateID)
+	return c.do(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// RenderPromptTemplate sends POST /templates/{template_id}/render: Expand a prompt template.
+// Form clients send the values as `vars[name]` fields.
+func (c *Client) RenderPromptTemplate(ctx context.Context, templateID int64, body *RenderInput) (*RenderResult, error) {
+	path := fmt.Sprintf("/templates/%d/render", templateID)
+	var out RenderResult
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTrash sends GET /trash: List deleted chats.
+func (c *Client) ListTrash(ctx context.Context) (*TrashList, error) {
+	path := "/trash"
+	var out TrashList
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EmptyTrash sends DELETE /trash: Empty the trash.
+func (c *Client) EmptyTrash(ctx context.Context) (*PurgeResult, error) {
+	path := "/trash"
+	var out PurgeResult
+	if err := c.do(ctx, "DELETE", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestoreChat sends POST /trash/{chat_id}/restore: Restore a deleted chat.
+func (c *Client) RestoreChat(ctx context.Context, chatID int64) (*Chat, error) {
+	path := fmt.Sprintf("/trash/%d/restore", chatID)
+	var out Chat
+	if err := c.do(ctx, "POST", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListChatsParams are the query parameters of ListChats.
+type ListChatsParams struct {
+	// The `next_cursor` of the previous page.
+	Before string
+	// Chats per page.
+	Limit int64
+	// Only chats in this folder: a folder ID, or `none` for chats outside any folder.
+	Folder string
+	// Only chats with this tag.
+	Tag string
+}
+
+// ListChats sends GET /user/chats: List chats.
+func (c *Client) ListChats(ctx context.Context, params *ListChatsParams) (*ChatList, error) {
+	path := "/user/chats"
+	query := url.Values{}
+	if params != nil {
+		if params.Before != "" {
+			query.Set("before", params.Before)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.FormatInt(params.Limit, 10))
+		}
+		if params.Folder != "" {
+			query.Set("folder", params.Folder)
+		}
+		if params.Tag != "" {
+			query.Set("tag", params.Tag)
+		}
+	}
+	var out ChatList
+	if err := c.do(ctx, "GET", path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LoginUser sends POST /user/login: Log in.
+func (c *Client) LoginUser(ctx context.Context, body Credentials) (*UserSummary, error) {
+	path := "/user/login"
+	var out UserSummary
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LogoutUser sends POST /user/logout: Log out.
+func (c *Client) LogoutUser(ctx context.Context) (*LogoutStatus, error) {
+	path := "/user/logout"
+	var out LogoutStatus
+	if err := c.do(ctx, "POST", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCurrentUser sends GET /user/me: Get the current user.
+func (c *Client) GetCurrentUser(ctx context.Context) (*UserSummary, error) {
+	path := "/user/me"
+	var out UserSummary
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RegisterUser sends POST /user/register: Create an account and log in.
+func (c *Client) RegisterUser(ctx context.Context, body Credentials) (*UserSummary, error) {
+	path := "/user/register"
+	var out UserSummary
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDefaultSettings sends GET /user/settings: Get the settings new chats start with.
+func (c *Client) GetDefaultSettings(ctx context.Context) (*GenerationSettings, error) {
+	path := "/user/settings"
+	var out GenerationSettings
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateDefaultSettings sends PUT /user/settings: Change the settings new chats start with.
+// Existing chats keep their settings.
+func (c *Client) UpdateDefaultSettings(ctx context.Context, body GenerationSettings) (*GenerationSettings, error) {
+	path := "/user/settings"
+	var out GenerationSettings
+	if err := c.do(ctx, "PUT", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTokens sends GET /user/tokens: List API tokens.
+func (c *Client) ListTokens(ctx context.Context) (*TokenList, error) {
+	path := "/user/tokens"
+	var out TokenList
+	if err := c.do(ctx, "GET", path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateToken sends POST /user/tokens: Create an API token.
+func (c *Client) CreateToken(ctx context.Context, body *TokenInput) (*CreatedToken, error) {
+	path := "/user/tokens"
+	var out CreatedToken
+	if err := c.do(ctx, "POST", path, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteToken sends DELETE /user/tokens/{token_id}: Revoke an API token.
+func (c *Client) DeleteToken(ctx context.Context, tokenID int64) error {
+	path := fmt.Sprintf("/user/tokens/%d", tokenID)
+	return c.do(ctx, "DELETE", path, nil, nil, nil)
+}
@@ -0,0 +1,413 @@
+// Command gen generates the types and methods of the gochat client from the OpenAPI document.
+//
+// Usage:
+//
+//	go run ./internal/gen -spec ../routes/openapi.json -out client_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// methods lists the HTTP methods in the order their operations are generated for a path.
+var methods = []string{"get", "post", "put", "patch", "delete"}
+
+// initialisms are the words written in upper case in Go identifiers.
+var initialisms = map[string]string{"id": "ID", "api": "API", "url": "URL", "ai": "AI"}
+
+// errorSchema is the schema of error responses, which client.go decodes into Error.
+const errorSchema = "Error"
+
+// pathParam matches a parameter in an OpenAPI path.
+var pathParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// document is the subset of an OpenAPI 3.0 document the generator reads.
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// operation is an OpenAPI operation.
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool                  `json:"required"`
+		Content  map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Description string               `json:"description"`
+		Content     map[string]mediaType `json:"content"`
+	} `json:"responses"`
+}
+
+// parameter is an OpenAPI path or query parameter.
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+// mediaType is the content of a request or response body for one media type.
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is an OpenAPI schema.
+type schema struct {
+	Ref         string     `json:"$ref"`
+	Type        string     `json:"type"`
+	Format      string     `json:"format"`
+	Description string     `json:"description"`
+	Nullable    bool       `json:"nullable"`
+	Required    []string   `json:"required"`
+	Items       *schema    `json:"items"`
+	Additional  *schema    `json:"additionalProperties"`
+	Properties  properties `json:"properties"`
+}
+
+// properties are the properties of an object schema in the order of the document.
+type properties struct {
+	names   []string
+	schemas map[string]*schema
+}
+
+// UnmarshalJSON decodes the properties, keeping their order.
+func (p *properties) UnmarshalJSON(data []byte) error {
+	p.schemas = map[string]*schema{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+		var property schema
+		if err := decoder.Decode(&property); err != nil {
+			return err
+		}
+		p.names = append(p.names, name)
+		p.schemas[name] = &property
+	}
+	return nil
+}
+
+func main() {
+	specPath := flag.String("spec", "../routes/openapi.json", "OpenAPI document")
+	outPath := flag.String("out", "client_gen.go", "generated file")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Fatalf("parse %s: %v", *specPath, err)
+	}
+
+	var body bytes.Buffer
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		if name != errorSchema {
+			writeType(&body, name, doc.Components.Schemas[name])
+		}
+	}
+	for _, path := range sortedKeys(doc.Paths) {
+		for _, method := range methods {
+			if op := doc.Paths[path][method]; op != nil {
+				writeOperation(&body, path, method, op)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by go run ./internal/gen; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	for _, pkg := range []string{"context", "encoding/json", "fmt", "net/url", "strconv", "time"} {
+		if bytes.Contains(body.Bytes(), []byte(pkg[strings.LastIndex(pkg, "/")+1:]+".")) {
+			fmt.Fprintf(&out, "%q\n", pkg)
+		}
+	}
+	out.WriteString(")\n\n")
+	out.Write(body.Bytes())
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("format generated code: %v\n%s", err, out.Bytes())
+	}
+	if err := os.WriteFile(*outPath, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeType writes the Go type of a component schema.
+func writeType(out *bytes.Buffer, name string, s *schema) {
+	if s.Type != "object" || len(s.Properties.names) == 0 {
+		return
+	}
+	if s.Description != "" {
+		writeComment(out, name+": "+s.Description, "")
+	} else {
+		writeComment(out, name+" is the "+name+" schema.", "")
+	}
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	for _, property := range s.Properties.names {
+		ps := s.Properties.schemas[property]
+		if ps.Description != "" {
+			writeComment(out, "", ps.Description)
+		}
+		tag := property
+		required := slices.Contains(s.Required, property)
+		if !required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(out, "%s %s `json:%q`\n", identifier(property), goType(ps, required), tag)
+	}
+	out.WriteString("}\n\n")
+}
+
+// writeOperation writes the method of an operation, and the type of its query parameters, if the operation exchanges
+// JSON.
+func writeOperation(out *bytes.Buffer, path, method string, op *operation) {
+	result, ok := resultType(op)
+	if !ok {
+		return
+	}
+	name := identifier(op.OperationID)
+
+	var pathArgs, queryParams []parameter
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			pathArgs = append(pathArgs, param)
+		case "query":
+			queryParams = append(queryParams, param)
+		}
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, param := range pathArgs {
+		args = append(args, lowerIdentifier(param.Name)+" int64")
+	}
+	if len(queryParams) > 0 {
+		fmt.Fprintf(out, "// %sParams are the query parameters of %s.\ntype %sParams struct {\n", name, name, name)
+		for _, param := range queryParams {
+			if param.Description != "" {
+				writeComment(out, "", param.Description)
+			}
+			fmt.Fprintf(out, "%s %s\n", identifier(param.Name), goType(param.Schema, true))
+		}
+		out.WriteString("}\n\n")
+		args = append(args, "params *"+name+"Params")
+	}
+	body := "nil"
+	if bodyType, ok := requestType(op); ok {
+		args = append(args, "body "+bodyType)
+		body = "body"
+	}
+
+	writeComment(out, fmt.Sprintf("%s sends %s %s: %s.", name, strings.ToUpper(method), path, op.Summary), op.Description)
+	if result == "" {
+		fmt.Fprintf(out, "func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(out, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), result)
+	}
+
+	format, values := path, []string{}
+	for _, param := range pathArgs {
+		format = strings.Replace(format, "{"+param.Name+"}", "%d", 1)
+		values = append(values, lowerIdentifier(param.Name))
+	}
+	if len(values) > 0 {
+		fmt.Fprintf(out, "path := fmt.Sprintf(%q, %s)\n", format, strings.Join(values, ", "))
+	} else {
+		fmt.Fprintf(out, "path := %q\n", format)
+	}
+
+	query := "nil"
+	if len(queryParams) > 0 {
+		query = "query"
+		out.WriteString("query := url.Values{}\nif params != nil {\n")
+		for _, param := range queryParams {
+			field := "params." + identifier(param.Name)
+			switch goType(param.Schema, true) {
+			case "int64":
+				fmt.Fprintf(out, "if %s != 0 {\nquery.Set(%q, strconv.FormatInt(%s, 10))\n}\n", field, param.Name, field)
+			case "bool":
+				fmt.Fprintf(out, "if %s {\nquery.Set(%q, \"true\")\n}\n", field, param.Name)
+			default:
+				fmt.Fprintf(out, "if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, param.Name, field)
+			}
+		}
+		out.WriteString("}\n")
+	}
+
+	switch {
+	case result == "":
+		fmt.Fprintf(out, "return c.do(ctx, %q, path, %s, %s, nil)\n}\n\n", strings.ToUpper(method), query, body)
+	case strings.HasPrefix(result, "*"):
+		fmt.Fprintf(out, "var out %s\nif err := c.do(ctx, %q, path, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n\n",
+			result[1:], strings.ToUpper(method), query, body)
+	default:
+		fmt.Fprintf(out, "var out %s\nif err := c.do(ctx, %q, path, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n}\n\n",
+			result, strings.ToUpper(method), query, body)
+	}
+}
+
+// resultType returns the Go type an operation returns, or false if the operation does not exchange JSON.
+//
+// The first success response with a JSON body decides the type. Success responses that are only HTML are meant for
+// the web interface and ignored; if the rest have no body, the method only returns an error.
+func resultType(op *operation) (string, bool) {
+	empty := false
+	for _, status := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		content := op.Responses[status].Content
+		if len(content) == 0 {
+			empty = true
+			continue
+		}
+		media, ok := content["application/json"]
+		if !ok {
+			continue
+		}
+		for contentType := range content {
+			if contentType != "application/json" && contentType != "text/html" {
+				return "", false
+			}
+		}
+		if media.Schema != nil && media.Schema.Ref != "" {
+			return "*" + refName(media.Schema.Ref), true
+		}
+		return "json.RawMessage", true
+	}
+	return "", empty
+}
+
+// requestType returns the Go type of the JSON request body of an operation, or false if it has none.
+func requestType(op *operation) (string, bool) {
+	if op.RequestBody == nil {
+		return "", false
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return "", false
+	}
+	if media.Schema != nil && media.Schema.Ref != "" {
+		if op.RequestBody.Required {
+			return refName(media.Schema.Ref), true
+		}
+		return "*" + refName(media.Schema.Ref), true
+	}
+	return "json.RawMessage", true
+}
+
+// goType returns the Go type of a schema.
+func goType(s *schema, required bool) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = refName(s.Ref)
+	case s.Type == "array":
+		return "[]" + goType(s.Items, true)
+	case s.Type == "object" && s.Additional != nil:
+		return "map[string]" + goType(s.Additional, true)
+	case s.Type == "string" && s.Format == "date-time":
+		t = "time.Time"
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer":
+		t = "int64"
+	case s.Type == "number":
+		t = "float64"
+	case s.Type == "boolean":
+		t = "bool"
+	default:
+		return "json.RawMessage"
+	}
+	if s.Nullable || (!required && s.Ref != "") {
+		return "*" + t
+	}
+	return t
+}
+
+// writeComment writes a doc comment from a first line and an optional description.
+func writeComment(out *bytes.Buffer, first, description string) {
+	for _, text := range []string{first, description} {
+		if text == "" {
+			continue
+		}
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(out, "// %s\n", line)
+		}
+	}
+}
+
+// identifier converts a snake_case or camelCase name into an exported Go identifier.
+func identifier(name string) string {
+	var words []string
+	for _, part := range strings.Split(name, "_") {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if part[i] >= 'A' && part[i] <= 'Z' && part[i-1] >= 'a' && part[i-1] <= 'z' {
+				words = append(words, part[start:i])
+				start = i
+			}
+		}
+		words = append(words, part[start:])
+	}
+
+	var b strings.Builder
+	for _, word := range words {
+		if upper, ok := initialisms[strings.ToLower(word)]; ok {
+			b.WriteString(upper)
+		} else if word != "" {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// lowerIdentifier converts a name into an unexported Go identifier.
+func lowerIdentifier(name string) string {
+	id := identifier(name)
+	for upper, initial := range map[string]string{"ID": "id", "API": "api", "URL": "url", "AI": "ai"} {
+		if id == upper {
+			return initial
+		}
+	}
+	return strings.ToLower(id[:1]) + id[1:]
+}
+
+// refName returns the schema name of a local reference.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gochat/config"
+	"gochat/database"
+	"gochat/routes"
+	"gochat/transcript"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+loadConfig loads the configuration for a command and applies the settings that configure packages globally.
+
+It exits the process if the configuration is invalid.
+
+- Args:
+	* `flags` (*flag.FlagSet) The flag set of the command, with any command specific flags already registered.
+	* `args` ([]string) The command line arguments following the command name.
+
+- Returns:
+	(*config.Config) The configuration.
+*/
+func loadConfig(flags *flag.FlagSet, args []string) *config.Config {
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.SetPasswordCost(cfg.Auth.BcryptCost); err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+/*
+runCreateAdmin implements the `create-admin` command, which bootstraps an administrator account.
+
+The password is taken from the `GOCHAT_ADMIN_PASSWORD` environment variable if it is set and read from standard input
+otherwise, so it does not end up in the shell history.
+
+- Args:
+	* `args` ([]string) The command line arguments following the command name.
+*/
+func runCreateAdmin(args []string) {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := flags.String("username", "admin", "username of the administrator")
+	cfg := loadConfig(flags, args)
+
+	password := os.Getenv("GOCHAT_ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Fprintf(os.Stderr, "Password for %s: ", *username)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatalf("Failed to read password: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len(password) < 8 {
+		log.Fatal("The password needs at least 8 characters")
+	}
+
+	db := database.InitDB(cfg.Database.DSN, cfg.Database.Search == "fulltext")
+	admin, err := database.CreateAdmin(db, *username, password)
+	if err != nil {
+		log.Fatalf("Failed to create administrator: %v", err)
+	}
+	fmt.Printf("Administrator %s (ID %d) is ready\n", admin.Username, admin.ID)
+}
+
+/*
+runImport implements the `import` command, which imports chats from export files for a user.
+
+Usage: `gochat import -user alice [-dry-run] conversations.json [more files...]`. Every file is a ChatGPT
+`conversations.json` or a gochat JSON export; all of them are imported in a single transaction.
+
+- Args:
+	* `args` ([]string) The command line arguments following the command name.
+*/
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	username := flags.String("user", "", "username of the user the chats are imported for")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without saving anything")
+	cfg := loadConfig(flags, args)
+
+	if *username == "" || flags.NArg() == 0 {
+		log.Fatal("Usage: gochat import -user <username> [-dry-run] <file>...")
+	}
+
+	var chats []transcript.ImportedChat
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		parsed, err := transcript.ParseImport(data)
+		if err != nil {
+			log.Fatalf("Failed to parse %s: %v", path, err)
+		}
+		chats = append(chats, parsed...)
+	}
+
+	db := database.InitDB(cfg.Database.DSN, cfg.Database.Search == "fulltext")
+	user, err := database.GetUserByUsername(db, *username)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %v", *username, err)
+	}
+
+	report, err := database.ImportChatsForUser(db, user.ID, chats, *dryRun)
+	if err != nil {
+		log.Fatalf("Import failed, nothing was saved: %v", err)
+	}
+
+	for _, chat := range report.Chats {
+		fmt.Printf("%-50s %5d messages %5d skipped\n", chat.Title, chat.Messages, chat.Skipped)
+	}
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Dry run: would import"
+	}
+	fmt.Printf("%s %d chats with %d messages for %s, skipping %d messages\n", verb, len(report.Chats), report.Messages, user.Username, report.Skipped)
+}
+
+/*
+runOpenAPI implements the `openapi` command, which prints the OpenAPI document of the HTTP interface.
+
+With `-check` it prints the differences between the document and the routes the server registers instead, and exits
+with status 1 if there are any, so the document can be verified in CI.
+
+- Args:
+	* `args` ([]string) The command line arguments following the command name.
+*/
+func runOpenAPI(args []string) {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	check := flags.Bool("check", false, "compare the document with the registered routes")
+	cfg := loadConfig(flags, args)
+
+	if !*check {
+		os.Stdout.Write(routes.OpenAPISpec())
+		return
+	}
+
+	// The handlers are only registered, never called, so they need no database, provider or attachment store
+	gin.SetMode(gin.ReleaseMode)
+	problems, err := routes.CheckOpenAPISpec(routes.SetupRouter(nil, nil, nil, cfg).Routes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("The OpenAPI document matches the registered routes")
+}
+
+/*
+runMigrate implements the `migrate` command, which shows and changes the version of the database schema.
+
+Usage: `gochat migrate status`, `gochat migrate up` or `gochat migrate down [-steps N]`. The server applies pending
+migrations when it starts, so `up` is only needed to migrate without starting it. `down` reverts the most recently
+applied migrations, one unless `-steps` says otherwise.
+
+- Args:
+	* `args` ([]string) The command line arguments following the command name.
+*/
+func runMigrate(args []string) {
+	usage := "Usage: gochat migrate status|up|down [-steps N]"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	action := args[0]
+	flags := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	cfg := loadConfig(flags, args[1:])
+
+	db, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.CloseDB(db)
+
+	switch action {
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatalf("Failed to read the migrations: %v", err)
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if !status.Known {
+				applied += " (unknown to this version of gochat)"
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		writer.Flush()
+	case "up":
+		applied, err := database.MigrateUp(db)
+		for _, migration := range applied {
+			fmt.Printf("Applied migration %d: %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("The database schema is up to date")
+		}
+	case "down":
+		if *steps < 1 {
+			log.Fatal("-steps must be at least 1")
+		}
+		reverted, err := database.MigrateDown(db, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted migration %d: %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migrations are applied")
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+/*
+warnUndocumentedRoutes logs the differences between the OpenAPI document and the routes of the router.
+
+- Args:
+	* `router` (*gin.Engine) The router, before routes outside the routes package are added.
+*/
+func warnUndocumentedRoutes(router *gin.Engine) {
+	problems, err := routes.CheckOpenAPISpec(router.Routes())
+	if err != nil {
+		log.Printf("Failed to check the OpenAPI document: %v", err)
+		return
+	}
+	for _, problem := range problems {
+		log.Printf("OpenAPI document out of date: %s", problem)
+	}
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gochat/ai"
+	"gochat/attachment"
+	"gochat/storage"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// minSessionSecretLength is the minimum length of a configured session secret in bytes.
+const minSessionSecretLength = 32
+
+// redacted replaces secrets when the configuration is printed.
+const redacted = "<redacted>"
+
+/*
+Config holds the settings of a gochat server.
+
+Settings are read from defaults, an optional YAML or TOML file, `GOCHAT_*` environment variables and command line
+flags, with later sources taking precedence.
+*/
+type Config struct {
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Session     SessionConfig     `yaml:"session" toml:"session"`
+	Paths       PathsConfig       `yaml:"paths" toml:"paths"`
+	AI          AIConfig          `yaml:"ai" toml:"ai"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	Attachments AttachmentsConfig `yaml:"attachments" toml:"attachments"`
+}
+
+// DatabaseConfig holds the database settings.
+// DSN is a `postgres://` or `mysql://` URL, or the path of a SQLite database file.
+// Deleted chats are purged once they have been in the trash for TrashRetention; 0 keeps them until the trash is emptied.
+// Search is `fulltext` to require the full-text index of the database, or `like` to search with slower LIKE scans.
+type DatabaseConfig struct {
+	DSN            string   `yaml:"dsn" toml:"dsn"`
+	TrashRetention Duration `yaml:"trash_retention" toml:"trash_retention"`
+	Search         string   `yaml:"search" toml:"search"`
+}
+
+// ServerConfig holds the HTTP server settings. TLS is enabled when both the certificate and key files are set.
+// ShutdownTimeout bounds how long in-flight requests may run after a shutdown signal before they are cancelled.
+type ServerConfig struct {
+	Addr            string   `yaml:"addr" toml:"addr"`
+	TLSCertFile     string   `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile      string   `yaml:"tls_key_file" toml:"tls_key_file"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// SessionConfig holds the session cookie settings.
+type SessionConfig struct {
+	Secret        string   `yaml:"secret" toml:"secret"`
+	TTL           Duration `yaml:"ttl" toml:"ttl"`
+	SecureCookies bool     `yaml:"secure_cookies" toml:"secure_cookies"`
+}
+
+// PathsConfig holds the locations of the frontend files.
+type PathsConfig struct {
+	Templates string `yaml:"templates" toml:"templates"`
+	Static    string `yaml:"static" toml:"static"`
+	Dist      string `yaml:"dist" toml:"dist"`
+}
+
+// AIConfig holds the AI provider settings.
+type AIConfig struct {
+	Provider string   `yaml:"provider" toml:"provider"`
+	BaseURL  string   `yaml:"base_url" toml:"base_url"`
+	APIKey   string   `yaml:"api_key" toml:"api_key"`
+	Model    string   `yaml:"model" toml:"model"`
+	Timeout  Duration `yaml:"timeout" toml:"timeout"`
+	// ContextWindow overrides the context size in tokens the provider assumes for its models; 0 keeps the built-in sizes.
+	ContextWindow int `yaml:"context_window" toml:"context_window"`
+}
+
+// AuthConfig holds the authentication settings.
+type AuthConfig struct {
+	BcryptCost int `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+}
+
+// AttachmentsConfig holds the settings of the files attached to messages.
+// Files are kept in Dir when Storage is "local" and in an S3-compatible bucket when it is "s3".
+// MaxFileSize is in bytes; MaxFiles of 0 disables attachments.
+type AttachmentsConfig struct {
+	Storage     string   `yaml:"storage" toml:"storage"`
+	Dir         string   `yaml:"dir" toml:"dir"`
+	MaxFileSize int      `yaml:"max_file_size" toml:"max_file_size"`
+	MaxFiles    int      `yaml:"max_files" toml:"max_files"`
+	S3          S3Config `yaml:"s3" toml:"s3"`
+}
+
+// S3Config holds the settings of the S3-compatible attachment storage. Endpoint defaults to AWS S3 in Region.
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint"`
+	Region    string `yaml:"region" toml:"region"`
+	Bucket    string `yaml:"bucket" toml:"bucket"`
+	AccessKey string `yaml:"access_key" toml:"access_key"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key"`
+}
+
+/*
+Default returns the configuration used when nothing else is configured.
+
+- Returns:
+	(*Config) The default configuration.
+*/
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{DSN: "test.db", TrashRetention: Duration(30 * 24 * time.Hour), Search: "fulltext"},
+		Server:   ServerConfig{Addr: ":8080", ShutdownTimeout: Duration(30 * time.Second)},
+		Session:  SessionConfig{TTL: Duration(time.Hour)},
+		Paths: PathsConfig{
+			Templates: "frontend/templates",
+			Static:    "frontend/static",
+			Dist:      "frontend/dist",
+		},
+		AI:   AIConfig{Provider: "mock", Timeout: Duration(2 * time.Minute)},
+		Auth: AuthConfig{BcryptCost: bcrypt.DefaultCost},
+		Attachments: AttachmentsConfig{
+			Storage:     "local",
+			Dir:         "attachments",
+			MaxFileSize: 10 << 20,
+			MaxFiles:    5,
+		},
+	}
+}
+
+/*
+TLSEnabled reports whether the server should serve HTTPS.
+
+- Returns:
+	(bool) True if both TLS files are configured.
+*/
+func (config *Config) TLSEnabled() bool {
+	return config.Server.TLSCertFile != "" && config.Server.TLSKeyFile != ""
+}
+
+/*
+SecureCookies reports whether session cookies should carry the Secure attribute.
+
+They do when the server serves HTTPS itself or when it is explicitly enabled, for example behind a TLS terminating
+proxy.
+
+- Returns:
+	(bool) True if cookies should only be sent over HTTPS.
+*/
+func (config *Config) SecureCookies() bool {
+	return config.TLSEnabled() || config.Session.SecureCookies
+}
+
+/*
+ProviderOptions converts the AI settings into options for ai.NewProvider.
+
+- Returns:
+	(ai.Options) The provider options.
+*/
+func (config *Config) ProviderOptions() ai.Options {
+	return ai.Options{
+		Name:          config.AI.Provider,
+		BaseURL:       config.AI.BaseURL,
+		APIKey:        config.AI.APIKey,
+		Model:         config.AI.Model,
+		Timeout:       time.Duration(config.AI.Timeout),
+		ContextWindow: config.AI.ContextWindow,
+	}
+}
+
+/*
+StorageOptions converts the attachment settings into options for storage.New.
+
+- Returns:
+	(storage.Options) The storage options.
+*/
+func (config *Config) StorageOptions() storage.Options {
+	return storage.Options{
+		Backend:     config.Attachments.Storage,
+		Dir:         config.Attachments.Dir,
+		S3Endpoint:  config.Attachments.S3.Endpoint,
+		S3Region:    config.Attachments.S3.Region,
+		S3Bucket:    config.Attachments.S3.Bucket,
+		S3AccessKey: config.Attachments.S3.AccessKey,
+		S3SecretKey: config.Attachments.S3.SecretKey,
+	}
+}
+
+/*
+AttachmentLimits converts the attachment settings into the upload limits.
+
+- Returns:
+	(attachment.Limits) The upload limits.
+*/
+func (config *Config) AttachmentLimits() attachment.Limits {
+	return attachment.Limits{
+		MaxFileSize: int64(config.Attachments.MaxFileSize),
+		MaxFiles:    config.Attachments.MaxFiles,
+	}
+}
+
+/*
+Validate checks that the configuration is complete and consistent.
+
+The files only the server reads are checked by ValidateFiles, so commands such as `migrate` run without them.
+
+- Returns:
+	(error) An error describing every problem found, or nil if the configuration is valid.
+*/
+func (config *Config) Validate() error {
+	var problems []error
+
+	if config.Database.DSN == "" {
+		problems = append(problems, errors.New("database.dsn must not be empty"))
+	}
+	if config.Database.TrashRetention < 0 {
+		problems = append(problems, errors.New("database.trash_retention must not be negative"))
+	}
+	if config.Database.Search != "fulltext" && config.Database.Search != "like" {
+		problems = append(problems, fmt.Errorf("database.search must be fulltext or like, not %q", config.Database.Search))
+	}
+	if config.Server.Addr == "" {
+		problems = append(problems, errors.New("server.addr must not be empty"))
+	}
+	if (config.Server.TLSCertFile == "") != (config.Server.TLSKeyFile == "") {
+		problems = append(problems, errors.New("server.tls_cert_file and server.tls_key_file must be set together"))
+	}
+	if config.Server.ShutdownTimeout < 0 {
+		problems = append(problems, errors.New("server.shutdown_timeout must not be negative"))
+	}
+	if config.Session.Secret != "" && len(config.Session.Secret) < minSessionSecretLength {
+		problems = append(problems, fmt.Errorf("session.secret must be at least %d bytes", minSessionSecretLength))
+	}
+	if config.Session.TTL <= 0 {
+		problems = append(problems, errors.New("session.ttl must be positive"))
+	}
+	if _, err := ai.NewProvider(config.ProviderOptions()); err != nil {
+		problems = append(problems, fmt.Errorf("ai.provider: %w", err))
+	}
+	if config.AI.Timeout < 0 {
+		problems = append(problems, errors.New("ai.timeout must not be negative"))
+	}
+	if config.AI.ContextWindow < 0 {
+		problems = append(problems, errors.New("ai.context_window must not be negative"))
+	}
+	if _, err := storage.New(config.StorageOptions()); err != nil {
+		problems = append(problems, fmt.Errorf("attachments.storage: %w", err))
+	}
+	if config.Attachments.MaxFileSize <= 0 {
+		problems = append(problems, errors.New("attachments.max_file_size must be positive"))
+	}
+	if config.Attachments.MaxFiles < 0 {
+		problems = append(problems, errors.New("attachments.max_files must not be negative"))
+	}
+	if config.Auth.BcryptCost < bcrypt.MinCost || config.Auth.BcryptCost > bcrypt.MaxCost {
+		problems = append(problems, fmt.Errorf("auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+
+	return errors.Join(problems...)
+}
+
+/*
+ValidateFiles checks that the TLS files and the frontend directories the server reads exist.
+
+- Returns:
+	(error) An error describing every missing file or directory, or nil if they all exist.
+*/
+func (config *Config) ValidateFiles() error {
+	var problems []error
+
+	for _, file := range []string{config.Server.TLSCertFile, config.Server.TLSKeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Errorf("TLS file: %w", err))
+		}
+	}
+	for name, dir := range map[string]string{"paths.templates": config.Paths.Templates, "paths.static": config.Paths.Static} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("%s: %q is not a directory", name, dir))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+/*
+Print writes the effective configuration as YAML with secrets redacted.
+
+- Args:
+	* `writer` (io.Writer) The destination, usually standard error.
+
+- Returns:
+	(error) An error if the configuration could not be written.
+*/
+func (config *Config) Print(writer io.Writer) error {
+	effective := *config
+	if effective.Session.Secret != "" {
+		effective.Session.Secret = redacted
+	}
+	if effective.AI.APIKey != "" {
+		effective.AI.APIKey = redacted
+	}
+	if effective.Attachments.S3.SecretKey != "" {
+		effective.Attachments.S3.SecretKey = redacted
+	}
+
+	output, err := yaml.Marshal(&effective)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "Effective configuration:\n%s", output)
+	return err
+}
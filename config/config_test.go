@@ -0,0 +1,26 @@
+package config
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// TestLoadWithoutFrontend checks that commands such as `migrate` load a configuration whose frontend directories do
+// not exist, while the server still refuses it.
+func TestLoadWithoutFrontend(t *testing.T) {
+	missing := t.TempDir() + "/missing"
+	config, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-templates", missing, "-static", missing})
+	if err != nil {
+		t.Fatalf("Load with missing frontend directories failed: %v", err)
+	}
+	err = config.ValidateFiles()
+	if err == nil || !strings.Contains(err.Error(), "paths.templates") || !strings.Contains(err.Error(), "paths.static") {
+		t.Errorf("ValidateFiles = %v, want errors for both directories", err)
+	}
+
+	config.Paths.Templates, config.Paths.Static = t.TempDir(), t.TempDir()
+	if err := config.ValidateFiles(); err != nil {
+		t.Errorf("ValidateFiles with existing directories = %v", err)
+	}
+}
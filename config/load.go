@@ -0,0 +1,272 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that is written as a string such as "90s" in configuration files.
+type Duration time.Duration
+
+/*
+UnmarshalText parses a duration string such as "1h30m".
+
+- Args:
+	* `text` ([]byte) The duration string.
+
+- Returns:
+	(error) An error if the string is not a valid duration.
+*/
+func (duration *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+/*
+MarshalText formats the duration as a string such as "1h30m0s".
+
+- Returns:
+	([]byte) The duration string.
+*/
+func (duration Duration) MarshalText() ([]byte, error) {
+	return []byte(duration.String()), nil
+}
+
+/*
+String formats the duration as a string such as "1h30m0s".
+
+- Returns:
+	(string) The duration string.
+*/
+func (duration Duration) String() string {
+	return time.Duration(duration).String()
+}
+
+/*
+Set parses a duration string, allowing Duration to be used as a flag.Value.
+
+- Args:
+	* `value` (string) The duration string.
+
+- Returns:
+	(error) An error if the string is not a valid duration.
+*/
+func (duration *Duration) Set(value string) error {
+	return duration.UnmarshalText([]byte(value))
+}
+
+// setting describes a configuration value that can be set from the environment and the command line.
+type setting struct {
+	flag   string
+	env    string
+	usage  string
+	target any
+}
+
+/*
+settings lists the configuration values that can be set from the environment and the command line.
+
+- Args:
+	* `config` (*Config) The configuration the settings write to.
+
+- Returns:
+	([]setting) The settings.
+*/
+func settings(config *Config) []setting {
+	return []setting{
+		{"db", "GOCHAT_DB_DSN", "database DSN: a postgres:// or mysql:// URL, or a SQLite file", &config.Database.DSN},
+		{"trash-retention", "GOCHAT_TRASH_RETENTION", "how long deleted chats stay in the trash, 0 to keep them until it is emptied", &config.Database.TrashRetention},
+		{"db-search", "GOCHAT_DB_SEARCH", "message search: fulltext, which needs the full-text index, or like", &config.Database.Search},
+		{"addr", "GOCHAT_ADDR", "address to listen on", &config.Server.Addr},
+		{"tls-cert", "GOCHAT_TLS_CERT", "TLS certificate file", &config.Server.TLSCertFile},
+		{"tls-key", "GOCHAT_TLS_KEY", "TLS private key file", &config.Server.TLSKeyFile},
+		{"shutdown-timeout", "GOCHAT_SHUTDOWN_TIMEOUT", "time allowed for in-flight requests on shutdown", &config.Server.ShutdownTimeout},
+		{"session-secret", "GOCHAT_SESSION_SECRET", "secret used to sign session cookies", &config.Session.Secret},
+		{"session-ttl", "GOCHAT_SESSION_TTL", "lifetime of a session", &config.Session.TTL},
+		{"secure-cookies", "GOCHAT_SECURE_COOKIES", "only send session cookies over HTTPS", &config.Session.SecureCookies},
+		{"templates", "GOCHAT_TEMPLATES", "HTML templates directory", &config.Paths.Templates},
+		{"static", "GOCHAT_STATIC", "static files directory", &config.Paths.Static},
+		{"dist", "GOCHAT_DIST", "compiled frontend scripts directory", &config.Paths.Dist},
+		{"ai-provider", "GOCHAT_AI_PROVIDER", "AI provider: mock, openai or ollama", &config.AI.Provider},
+		{"ai-base-url", "GOCHAT_AI_BASE_URL", "base URL of the AI provider API", &config.AI.BaseURL},
+		{"ai-api-key", "GOCHAT_AI_API_KEY", "API key of the AI provider", &config.AI.APIKey},
+		{"ai-model", "GOCHAT_AI_MODEL", "model name passed to the AI provider", &config.AI.Model},
+		{"ai-timeout", "GOCHAT_AI_TIMEOUT", "timeout of a single AI request", &config.AI.Timeout},
+		{"ai-context-window", "GOCHAT_AI_CONTEXT_WINDOW", "context size of the model in tokens, 0 for the built-in size", &config.AI.ContextWindow},
+		{"bcrypt-cost", "GOCHAT_BCRYPT_COST", "bcrypt cost for password hashes", &config.Auth.BcryptCost},
+		{"attachments-storage", "GOCHAT_ATTACHMENTS_STORAGE", "attachment storage: local or s3", &config.Attachments.Storage},
+		{"attachments-dir", "GOCHAT_ATTACHMENTS_DIR", "directory of the local attachment storage", &config.Attachments.Dir},
+		{"attachments-max-file-size", "GOCHAT_ATTACHMENTS_MAX_FILE_SIZE", "largest attachment in bytes", &config.Attachments.MaxFileSize},
+		{"attachments-max-files", "GOCHAT_ATTACHMENTS_MAX_FILES", "most attachments per message, 0 to disable attachments", &config.Attachments.MaxFiles},
+		{"s3-endpoint", "GOCHAT_S3_ENDPOINT", "base URL of the S3-compatible attachment storage", &config.Attachments.S3.Endpoint},
+		{"s3-region", "GOCHAT_S3_REGION", "region of the S3 attachment storage", &config.Attachments.S3.Region},
+		{"s3-bucket", "GOCHAT_S3_BUCKET", "bucket of the S3 attachment storage", &config.Attachments.S3.Bucket},
+		{"s3-access-key", "GOCHAT_S3_ACCESS_KEY", "access key of the S3 attachment storage", &config.Attachments.S3.AccessKey},
+		{"s3-secret-key", "GOCHAT_S3_SECRET_KEY", "secret key of the S3 attachment storage", &config.Attachments.S3.SecretKey},
+	}
+}
+
+/*
+Load builds the configuration from defaults, a configuration file, the environment and command line flags.
+
+The configuration flags are registered on the given flag set, so callers can add their own flags before calling Load.
+The file is given by the `-config` flag or the `GOCHAT_CONFIG` environment variable and is read as TOML if its name
+ends in `.toml` and as YAML otherwise. The result is validated before it is returned. Without a configured session
+secret a random one is generated.
+
+- Args:
+	* `flags` (*flag.FlagSet) The flag set to register the configuration flags on.
+	* `args` ([]string) The command line arguments to parse.
+
+- Returns:
+	(*Config) The configuration, or an error if a source could not be read or the result is invalid.
+*/
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
+	config := Default()
+	configFile := flags.String("config", os.Getenv("GOCHAT_CONFIG"), "YAML or TOML configuration file")
+	for _, setting := range settings(config) {
+		registerFlag(flags, setting)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Flags win over every other source, so remember them and apply them again once the file and environment are read.
+	explicit := map[string]string{}
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = f.Value.String() })
+
+	if *configFile != "" {
+		if err := loadFile(config, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(config); err != nil {
+		return nil, err
+	}
+
+	for name, value := range explicit {
+		if err := flags.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	if config.Session.Secret == "" {
+		secret := make([]byte, minSessionSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		config.Session.Secret = hex.EncodeToString(secret)
+		log.Println("No session secret configured, using a random one; sessions will not survive a restart")
+	}
+	return config, nil
+}
+
+/*
+registerFlag registers a setting on a flag set, using the setting's current value as the default.
+
+- Args:
+	* `flags` (*flag.FlagSet) The flag set.
+	* `setting` (setting) The setting to register.
+*/
+func registerFlag(flags *flag.FlagSet, setting setting) {
+	usage := fmt.Sprintf("%s (env %s)", setting.usage, setting.env)
+	switch target := setting.target.(type) {
+	case *string:
+		flags.StringVar(target, setting.flag, *target, usage)
+	case *bool:
+		flags.BoolVar(target, setting.flag, *target, usage)
+	case *int:
+		flags.IntVar(target, setting.flag, *target, usage)
+	case *Duration:
+		flags.Var(target, setting.flag, usage)
+	}
+}
+
+/*
+loadFile reads a YAML or TOML configuration file into the configuration.
+
+Only the keys present in the file are changed.
+
+- Args:
+	* `config` (*Config) The configuration to update.
+	* `path` (string) The path of the file.
+
+- Returns:
+	(error) An error if the file could not be read or parsed.
+*/
+func loadFile(config *Config, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+/*
+loadEnv applies the `GOCHAT_*` environment variables that are set to the configuration.
+
+- Args:
+	* `config` (*Config) The configuration to update.
+
+- Returns:
+	(error) An error naming the first variable with an invalid value.
+*/
+func loadEnv(config *Config) error {
+	for _, setting := range settings(config) {
+		value, ok := os.LookupEnv(setting.env)
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch target := setting.target.(type) {
+		case *string:
+			*target = value
+		case *bool:
+			*target, err = strconv.ParseBool(value)
+		case *int:
+			*target, err = strconv.Atoi(value)
+		case *Duration:
+			err = target.Set(value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", setting.env, err)
+		}
+	}
+	return nil
+}
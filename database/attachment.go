@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrAttachmentNotFound is returned when an attachment does not exist or its chat is in the trash.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+/*
+GetAttachmentForUser retrieves an attachment if it belongs to a message of a chat of the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `attachmentID` (uint) The ID of the attachment.
+	* `userID` (uint) The ID of the user requesting the attachment.
+
+- Returns:
+	(*models.Attachment) The attachment, ErrAttachmentNotFound if it does not exist or its chat is in the trash,
+	ErrChatForbidden if its chat belongs to another user, or another error if the operation failed.
+*/
+func GetAttachmentForUser(db *gorm.DB, attachmentID, userID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	if err := db.First(&attachment, attachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+
+	var message models.Message
+	if err := db.Select("id", "chat_id").First(&message, attachment.MessageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	if _, err := authorizeChat(db, message.ChatID, userID); err != nil {
+		if errors.Is(err, ErrChatNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+/*
+GetPurgedAttachments retrieves attachments of purged chats whose files have not been removed yet.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `limit` (int) The maximum number of attachments to return.
+
+- Returns:
+	([]models.Attachment) The attachments, oldest first, or an error if the operation failed.
+*/
+func GetPurgedAttachments(db *gorm.DB, limit int) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := db.Unscoped().
+		Select("id", "storage_key", "thumbnail_key").
+		Where("deleted_at IS NOT NULL").
+		Order("id").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+/*
+ForgetAttachments permanently deletes purged attachments once their files have been removed.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `ids` ([]uint) The IDs of the attachments.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func ForgetAttachments(db *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.Attachment{}, ids).Error
+}
+
+/*
+orderAttachments orders preloaded attachments in the order they were uploaded.
+
+- Args:
+	* `db` (*gorm.DB) The query loading the attachments.
+
+- Returns:
+	(*gorm.DB) The ordered query.
+*/
+func orderAttachments(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
@@ -0,0 +1,456 @@
+package database
+
+import (
+	"errors"
+	"slices"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+/*
+GetActivePath retrieves the messages of the active branch of a chat.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+
+- Returns:
+	([]models.Message) The messages from the first message to the active one, ErrChatNotFound if the chat does not
+	exist, or another error if the operation failed.
+*/
+func GetActivePath(db *gorm.DB, chatID uint) ([]models.Message, error) {
+	var chat models.Chat
+	if err := db.First(&chat, chatID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChatNotFound
+		}
+		return nil, err
+	}
+	return activePath(db, &chat)
+}
+
+/*
+GetMessageTree retrieves every message of a chat across all branches.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+
+- Returns:
+	([]models.Message) The messages in the order they were created, or an error if the operation failed.
+*/
+func GetMessageTree(db *gorm.DB, chatID uint) ([]models.Message, error) {
+	return chatMessages(db, chatID)
+}
+
+/*
+GetSiblings retrieves a message together with the other messages that share its parent.
+
+Siblings are the alternative versions created by editing a message or regenerating a reply.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `messageID` (uint) The ID of the message.
+
+- Returns:
+	([]models.Message) The message and its siblings in the order they were created, ErrMessageNotFound if the
+	message does not exist, or another error if the operation failed.
+*/
+func GetSiblings(db *gorm.DB, messageID uint) ([]models.Message, error) {
+	var message models.Message
+	if err := db.First(&message, messageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	query := db.Where("chat_id = ?", message.ChatID)
+	if message.ParentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *message.ParentID)
+	}
+
+	var siblings []models.Message
+	result := query.Order("id ASC").Find(&siblings)
+	return siblings, result.Error
+}
+
+/*
+GetPathSiblings retrieves the sibling IDs of every message on a path with a single query.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `path` ([]models.Message) The messages, usually the active path of the chat.
+
+- Returns:
+	(map[uint][]uint) The IDs of each message and its siblings in the order they were created, keyed by message ID,
+	or an error if the operation failed.
+*/
+func GetPathSiblings(db *gorm.DB, chatID uint, path []models.Message) (map[uint][]uint, error) {
+	var messages []models.Message
+	if err := db.Select("id", "parent_id").Where("chat_id = ?", chatID).Order("id ASC").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	children := childIDs(messages)
+	siblings := make(map[uint][]uint, len(path))
+	for _, message := range path {
+		siblings[message.ID] = children[parentKey(message)]
+	}
+	return siblings, nil
+}
+
+/*
+SelectBranchForUser makes the branch through a message the active branch of a chat that belongs to the given user.
+
+Below the selected message the branch follows the most recently created message at every step. A message that is
+already on the active branch leaves the branch as it is.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user switching branches.
+	* `messageID` (uint) The ID of the message to show.
+
+- Returns:
+	(error) ErrChatNotFound, ErrChatForbidden, ErrMessageNotFound, or another error if the operation failed.
+*/
+func SelectBranchForUser(db *gorm.DB, chatID, userID, messageID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		chat, err := authorizeChat(tx, chatID, userID)
+		if err != nil {
+			return err
+		}
+
+		var message models.Message
+		if err := findMessage(tx, chatID, messageID, &message); err != nil {
+			return err
+		}
+		path, err := activePathIDs(tx, chat)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(path, messageID) {
+			return nil
+		}
+
+		messages, err := chatMessages(tx, chatID)
+		if err != nil {
+			return err
+		}
+		children := childIDs(messages)
+		leaf := messageID
+		for len(children[leaf]) > 0 {
+			leaf = children[leaf][len(children[leaf])-1]
+		}
+		return setActiveMessage(tx, chatID, leaf)
+	})
+}
+
+/*
+EditMessageForUser edits a user message in a chat that belongs to the given user.
+
+The original message is kept: the new text is added as a sibling of it, which becomes the end of the active branch.
+The new version has the same attachments as the original.
+The caller is expected to request an AI reply to the new message.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user editing the message.
+	* `messageID` (uint) The ID of the message to edit.
+	* `content` (string) The new text of the message.
+
+- Returns:
+	* `*models.Message` The new version of the message.
+	* `[]uint` The IDs of the messages that followed the original message and are no longer on the active branch.
+	* `error` ErrChatNotFound, ErrChatForbidden, ErrMessageNotFound, ErrMessageType if the message is not a user
+	message, or another error if the operation failed.
+*/
+func EditMessageForUser(db *gorm.DB, chatID, userID, messageID uint, content string) (*models.Message, []uint, error) {
+	var edited models.Message
+	var hidden []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		chat, err := authorizeChat(tx, chatID, userID)
+		if err != nil {
+			return err
+		}
+
+		var original models.Message
+		if err := findMessage(tx, chatID, messageID, &original); err != nil {
+			return err
+		}
+		if original.MessageType != models.UserMessageType {
+			return ErrMessageType
+		}
+
+		path, err := activePath(tx, chat)
+		if err != nil {
+			return err
+		}
+		hidden = messagesAfter(path, messageID)
+
+		// The new version keeps the files of the original, which share their stored files with it.
+		var attachments []models.Attachment
+		if err := orderAttachments(tx).Where("message_id = ?", original.ID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		for i := range attachments {
+			attachments[i].Model = gorm.Model{}
+			attachments[i].MessageID = 0
+		}
+
+		edited = models.Message{
+			ChatID:      chatID,
+			ParentID:    original.ParentID,
+			UserID:      userID,
+			Message:     content,
+			MessageType: models.UserMessageType,
+			Attachments: attachments,
+		}
+		if err := tx.Create(&edited).Error; err != nil {
+			return err
+		}
+		return setActiveMessage(tx, chatID, edited.ID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &edited, hidden, nil
+}
+
+/*
+RegenerateReplyForUser prepares a new AI reply to the user message that an existing reply answered.
+
+The existing reply is kept as a sibling of the new one. The active branch is moved back to the user message, so the
+next reply saved for it becomes the end of the active branch.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user regenerating the reply.
+	* `replyID` (uint) The ID of the AI message to regenerate.
+
+- Returns:
+	* `*models.Message` The user message the reply answered.
+	* `[]uint` The IDs of the messages, starting with the reply, that are no longer on the active branch.
+	* `error` ErrChatNotFound, ErrChatForbidden, ErrMessageNotFound, ErrMessageType if the message is not an AI reply
+	to a user message, or another error if the operation failed.
+*/
+func RegenerateReplyForUser(db *gorm.DB, chatID, userID, replyID uint) (*models.Message, []uint, error) {
+	var prompt models.Message
+	var hidden []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		chat, err := authorizeChat(tx, chatID, userID)
+		if err != nil {
+			return err
+		}
+
+		var reply models.Message
+		if err := findMessage(tx, chatID, replyID, &reply); err != nil {
+			return err
+		}
+		if reply.MessageType != models.AIMessageType || reply.ParentID == nil {
+			return ErrMessageType
+		}
+		if err := findMessage(tx, chatID, *reply.ParentID, &prompt); err != nil {
+			return err
+		}
+		if prompt.MessageType != models.UserMessageType {
+			return ErrMessageType
+		}
+
+		path, err := activePath(tx, chat)
+		if err != nil {
+			return err
+		}
+		hidden = messagesAfter(path, prompt.ID)
+		return setActiveMessage(tx, chatID, prompt.ID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &prompt, hidden, nil
+}
+
+/*
+activePath walks from the active message of a chat back to the first message.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chat` (*models.Chat) The chat.
+
+- Returns:
+	([]models.Message) The messages of the active branch in order, or an error if the operation failed.
+*/
+func activePath(db *gorm.DB, chat *models.Chat) ([]models.Message, error) {
+	ids, err := activePathIDs(db, chat)
+	if err != nil {
+		return nil, err
+	}
+	return loadMessages(db, ids)
+}
+
+/*
+activePathIDs walks from the active message of a chat back to the first message, loading only the message IDs.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chat` (*models.Chat) The chat.
+
+- Returns:
+	([]uint) The IDs of the messages of the active branch in order, or an error if the operation failed.
+*/
+func activePathIDs(db *gorm.DB, chat *models.Chat) ([]uint, error) {
+	path := []uint{}
+	if chat.ActiveMessageID == nil {
+		return path, nil
+	}
+
+	var links []models.Message
+	if err := db.Model(&models.Message{}).Select("id", "parent_id").Where("chat_id = ?", chat.ID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	parents := make(map[uint]*uint, len(links))
+	for _, link := range links {
+		parents[link.ID] = link.ParentID
+	}
+
+	// The length check stops the walk on a corrupt parent cycle.
+	for id := chat.ActiveMessageID; id != nil && len(path) < len(links); {
+		parent, found := parents[*id]
+		if !found {
+			break
+		}
+		path = append(path, *id)
+		id = parent
+	}
+	slices.Reverse(path)
+	return path, nil
+}
+
+/*
+loadMessages loads messages by ID together with their attachments.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `ids` ([]uint) The IDs of the messages.
+
+- Returns:
+	([]models.Message) The messages in the order of the IDs, or an error if the operation failed.
+*/
+func loadMessages(db *gorm.DB, ids []uint) ([]models.Message, error) {
+	messages := make([]models.Message, 0, len(ids))
+	if len(ids) == 0 {
+		return messages, nil
+	}
+
+	var loaded []models.Message
+	if err := db.Preload("Attachments", orderAttachments).Where("id IN ?", ids).Find(&loaded).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Message, len(loaded))
+	for _, message := range loaded {
+		byID[message.ID] = message
+	}
+	for _, id := range ids {
+		if message, found := byID[id]; found {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+/*
+chatMessages loads every message of a chat, across all branches, in the order they were created.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+
+- Returns:
+	([]models.Message) The messages, or an error if the operation failed.
+*/
+func chatMessages(db *gorm.DB, chatID uint) ([]models.Message, error) {
+	var messages []models.Message
+	result := db.Where("chat_id = ?", chatID).Order("id ASC").Find(&messages)
+	return messages, result.Error
+}
+
+/*
+setActiveMessage sets the end of the active branch of a chat.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `messageID` (uint) The ID of the new active message.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func setActiveMessage(db *gorm.DB, chatID, messageID uint) error {
+	return db.Model(&models.Chat{}).Where("id = ?", chatID).Update("active_message_id", messageID).Error
+}
+
+/*
+messagesAfter returns the IDs of the messages that follow a message on a path.
+
+- Args:
+	* `path` ([]models.Message) The path.
+	* `messageID` (uint) The ID of the message.
+
+- Returns:
+	([]uint) The IDs after the message, or nil if the message is not on the path.
+*/
+func messagesAfter(path []models.Message, messageID uint) []uint {
+	for i, message := range path {
+		if message.ID != messageID {
+			continue
+		}
+		var ids []uint
+		for _, later := range path[i+1:] {
+			ids = append(ids, later.ID)
+		}
+		return ids
+	}
+	return nil
+}
+
+/*
+childIDs groups message IDs by the ID of their parent. Messages that start the chat are grouped under 0, which is
+never a message ID.
+
+- Args:
+	* `messages` ([]models.Message) The messages in the order they were created.
+
+- Returns:
+	(map[uint][]uint) The IDs of the children of each message, in the order they were created.
+*/
+func childIDs(messages []models.Message) map[uint][]uint {
+	children := make(map[uint][]uint)
+	for _, message := range messages {
+		children[parentKey(message)] = append(children[parentKey(message)], message.ID)
+	}
+	return children
+}
+
+/*
+parentKey returns the parent ID of a message, or 0 for a message that starts a chat.
+
+- Args:
+	* `message` (models.Message) The message.
+
+- Returns:
+	(uint) The key used to group siblings.
+*/
+func parentKey(message models.Message) uint {
+	if message.ParentID == nil {
+		return 0
+	}
+	return *message.ParentID
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestSelectBranchForUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		alice, chat := addTestChat(t, db, "alice", "first", "second", "third")
+		path, err := GetActivePath(db, chat.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		edited, _, err := EditMessageForUser(db, chat.ID, alice.ID, path[1].ID, "second, edited")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Switching back to the original version follows it down to the third message.
+		if err := SelectBranchForUser(db, chat.ID, alice.ID, path[1].ID); err != nil {
+			t.Fatal(err)
+		}
+		assertActiveMessage(t, db, chat.ID, path[2].ID)
+
+		// The first message is on the active branch, so selecting it must not move to the newer edit.
+		if err := SelectBranchForUser(db, chat.ID, alice.ID, path[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		assertActiveMessage(t, db, chat.ID, path[2].ID)
+
+		if err := SelectBranchForUser(db, chat.ID, alice.ID, edited.ID); err != nil {
+			t.Fatal(err)
+		}
+		assertActiveMessage(t, db, chat.ID, edited.ID)
+	})
+}
+
+// assertActiveMessage checks the last message of the active branch of a chat.
+func assertActiveMessage(t *testing.T, db *gorm.DB, chatID, want uint) {
+	t.Helper()
+	path, err := GetActivePath(db, chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) == 0 {
+		t.Fatalf("active branch is empty, want it to end at message %d", want)
+	}
+	if last := path[len(path)-1].ID; last != want {
+		t.Errorf("active branch ends at message %d, want %d", last, want)
+	}
+}
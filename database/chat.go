@@ -8,6 +8,17 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrChatNotFound is returned when a chat does not exist.
+	ErrChatNotFound = errors.New("chat not found")
+	// ErrChatForbidden is returned when a chat exists but belongs to another user.
+	ErrChatForbidden = errors.New("chat belongs to another user")
+	// ErrMessageNotFound is returned when a message does not exist in the chat.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrMessageType is returned when a message cannot be used for an action, such as editing an AI reply.
+	ErrMessageType = errors.New("message has the wrong type for this action")
+)
+
 /*
 AddChat adds a new chat to the database.
 
@@ -26,6 +37,8 @@ func AddChat(db *gorm.DB, chat *models.Chat) error {
 /*
 GetChat retrieves a chat by ID from the database.
 
+The chat's messages are those of the active branch, from the first message to the active one.
+
 - Args:
 	* `db` (*gorm.DB) The database connection.
 	* `chatID` (uint) The ID of the chat to retrieve.
@@ -35,13 +48,40 @@ GetChat retrieves a chat by ID from the database.
 */
 func GetChat(db *gorm.DB, chatID uint) (*models.Chat, error) {
 	var chat models.Chat
-	result := db.Preload("Messages").First(&chat, "id = ?", chatID)
+	result := db.Preload("Tags", orderTags).First(&chat, "id = ?", chatID)
 	if result.Error != nil {
 		return nil, result.Error
 	}
+
+	messages, err := activePath(db, &chat)
+	if err != nil {
+		return nil, err
+	}
+	chat.Messages = messages
 	return &chat, nil
 }
 
+/*
+GetChatForUser retrieves a chat by ID from the database if it belongs to the given user.
+
+The chat's messages are those of the active branch, from the first message to the active one.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat to retrieve.
+	* `userID` (uint) The ID of the user requesting the chat.
+
+- Returns:
+	(*models.Chat) The chat if found, ErrChatNotFound if it does not exist, ErrChatForbidden if it belongs to another
+	user, or another error if the operation failed.
+*/
+func GetChatForUser(db *gorm.DB, chatID, userID uint) (*models.Chat, error) {
+	if _, err := authorizeChat(db, chatID, userID); err != nil {
+		return nil, err
+	}
+	return GetChat(db, chatID)
+}
+
 /*
 GetAllChatsForUser retrieves all chats for a given user from the database.
 
@@ -74,9 +114,77 @@ func DeleteChat(db *gorm.DB, chatID uint) error {
 	return result.Error
 }
 
+/*
+DeleteChatForUser moves a chat to the trash if it belongs to the given user.
+
+The chat is soft-deleted; it can be restored with RestoreChatForUser until the trash is emptied or purged.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat to delete.
+	* `userID` (uint) The ID of the user deleting the chat.
+
+- Returns:
+	(error) ErrChatNotFound if the chat does not exist, ErrChatForbidden if it belongs to another user, or another
+	error if the operation failed.
+*/
+func DeleteChatForUser(db *gorm.DB, chatID, userID uint) error {
+	if _, err := authorizeChat(db, chatID, userID); err != nil {
+		return err
+	}
+	return DeleteChat(db, chatID)
+}
+
+/*
+RenameChatForUser sets the title of a chat if it belongs to the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat to rename.
+	* `userID` (uint) The ID of the user renaming the chat.
+	* `title` (string) The new title.
+
+- Returns:
+	(*models.Chat) The renamed chat, ErrChatNotFound if it does not exist, ErrChatForbidden if it belongs to another
+	user, or another error if the operation failed.
+*/
+func RenameChatForUser(db *gorm.DB, chatID, userID uint, title string) (*models.Chat, error) {
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(chat).Update("title", title).Error; err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+/*
+SetChatTitleIfEmpty sets the title of a chat unless it already has one.
+
+It is used for generated titles, which must never overwrite a title the user chose in the meantime.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `title` (string) The generated title.
+
+- Returns:
+	(bool) True if the title was set, or an error if the operation failed.
+*/
+func SetChatTitleIfEmpty(db *gorm.DB, chatID uint, title string) (bool, error) {
+	result := db.Model(&models.Chat{}).
+		Where("id = ? AND (title IS NULL OR title = '')", chatID).
+		Update("title", title)
+	return result.RowsAffected > 0, result.Error
+}
+
 /*
 AddMessage adds a new message to a chat in the database.
 
+Unless its parent is set, the message follows the active message of the chat.
+
 - Args:
 	* `db` (*gorm.DB) The database connection.
 	* `chatID` (uint) The ID of the chat to add the message to.
@@ -88,12 +196,92 @@ AddMessage adds a new message to a chat in the database.
 func AddMessage(db *gorm.DB, chatID uint, message *models.Message) error {
 	var chat models.Chat
 	if err := db.First(&chat, chatID).Error; err != nil {
-		return errors.New("chat not found")
+		return ErrChatNotFound
 	}
+	return addMessage(db, &chat, message)
+}
 
-	message.ChatID = chatID
-	result := db.Create(message)
-	return result.Error
+/*
+AddMessageForUser adds a new message to a chat in the database if the chat belongs to the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat to add the message to.
+	* `userID` (uint) The ID of the user adding the message.
+	* `message` (*models.Message) The message to add.
+
+- Returns:
+	(error) ErrChatNotFound if the chat does not exist, ErrChatForbidden if it belongs to another user, or another
+	error if the operation failed.
+*/
+func AddMessageForUser(db *gorm.DB, chatID, userID uint, message *models.Message) error {
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return err
+	}
+	return addMessage(db, chat, message)
+}
+
+/*
+addMessage adds a message to a chat.
+
+A message without a parent follows the active message. A message that continues the active branch becomes its new
+end, while a late reply to a message of another branch does not switch branches.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chat` (*models.Chat) The chat to add the message to.
+	* `message` (*models.Message) The message to add.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func addMessage(db *gorm.DB, chat *models.Chat, message *models.Message) error {
+	message.ChatID = chat.ID
+	if message.ParentID == nil {
+		message.ParentID = chat.ActiveMessageID
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+
+		query := tx.Model(&models.Chat{}).Where("id = ?", chat.ID)
+		if message.ParentID == nil {
+			query = query.Where("active_message_id IS NULL")
+		} else {
+			query = query.Where("active_message_id = ?", *message.ParentID)
+		}
+		return query.Update("active_message_id", message.ID).Error
+	})
+}
+
+/*
+authorizeChat loads a chat with its tags but without its messages and checks that it belongs to the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user accessing the chat.
+
+- Returns:
+	(*models.Chat) The chat, ErrChatNotFound if it does not exist, ErrChatForbidden if it belongs to another user,
+	or another error if the operation failed.
+*/
+func authorizeChat(db *gorm.DB, chatID, userID uint) (*models.Chat, error) {
+	var chat models.Chat
+	if err := db.Preload("Tags", orderTags).First(&chat, chatID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChatNotFound
+		}
+		return nil, err
+	}
+
+	if chat.UserID != userID {
+		return nil, ErrChatForbidden
+	}
+	return &chat, nil
 }
 
 /*
@@ -114,23 +302,22 @@ func GetAllChats(db *gorm.DB) ([]models.Chat, error) {
 	return chats, nil
 }
 
-// func UpdateMessage(db *gorm.DB, chatID, messageID uint, newMessageContent string) error {
-// 	var chat models.Chat
-// 	if err := db.First(&chat, chatID).Error; err != nil {
-// 		return errors.New("chat not found")
-// 	}
-
-// 	var message models.Message
-// 	result := db.First(&message, messageID)
-// 	if result.Error != nil {
-// 		return result.Error
-// 	}
-
-// 	if message.ChatID != chatID {
-// 		return errors.New("message does not belong to this chat")
-// 	}
-
-// 	message.Message = newMessageContent
-// 	result = db.Save(&message)
-// 	return result.Error
-// }
\ No newline at end of file
+/*
+findMessage loads a message and checks that it is part of the given chat.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `messageID` (uint) The ID of the message.
+	* `message` (*models.Message) Receives the message.
+
+- Returns:
+	(error) ErrMessageNotFound if the message does not exist in the chat, or another error if the operation failed.
+*/
+func findMessage(db *gorm.DB, chatID, messageID uint, message *models.Message) error {
+	err := db.Where("chat_id = ?", chatID).First(message, messageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMessageNotFound
+	}
+	return err
+}
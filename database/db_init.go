@@ -2,53 +2,82 @@ package database
 
 import (
 	"log"
-	"os"
 
 	"gochat/models"
 
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 /*
-InitDB initializes the database connection and sets up the schema.
+Open connects to the database without changing its schema.
 
-If the database file does not exist, it creates the file and sets up the schema.
-If the database file exists, it connects to the existing database and sets up the schema.
+The driver is picked from the scheme of the DSN, see Dialector; a DSN without a scheme is a SQLite database file.
 
 - Args:
-	* `dbFileName` (string) The name of the database file.
+	* `dsn` (string) The database DSN.
+
+- Returns:
+	(*gorm.DB) The database connection, or an error if the connection failed.
+*/
+func Open(dsn string) (*gorm.DB, error) {
+	dialector, err := Dialector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(dialector, &gorm.Config{})
+}
+
+/*
+InitDB initializes the database connection and brings the schema up to date.
+
+Pending migrations are applied, see MigrateUp. No users are created; use the `create-admin` command to bootstrap an
+administrator. It exits the process if the database cannot be reached, a migration fails, the schema was migrated by
+a newer version of gochat or full-text search is required but cannot be set up.
+
+- Args:
+	* `dsn` (string) The database DSN.
+	* `fullText` (bool) Whether message search must use the full-text index of the database rather than LIKE.
 
 - Returns:
 	(*gorm.DB) The database connection.
 */
-func InitDB(dbFileName string) *gorm.DB {
-	var db *gorm.DB
-
-	if _, err := os.Stat(dbFileName); os.IsNotExist(err) {
-		db, err = gorm.Open(sqlite.Open(dbFileName), &gorm.Config{})
-		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
-		}
-
-		// Auto migrate the schema
-		db.AutoMigrate(&models.User{}, &models.Chat{}, &models.Message{})
-
-		// Create default user
-		defaultUser := models.User{
-			Username: "default_user",
-			Password: "password123", // In a real application, make sure to hash the password
-		}
-		db.Create(&defaultUser)
-	} else {
-		db, err = gorm.Open(sqlite.Open(dbFileName), &gorm.Config{})
-		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
-		}
-
-		// Auto migrate the schema
-		db.AutoMigrate(&models.User{}, &models.Chat{}, &models.Message{})
+func InitDB(dsn string, fullText bool) *gorm.DB {
+	db, err := Open(dsn)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	created := !db.Migrator().HasTable(&models.User{})
+	applied, err := MigrateUp(db)
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	for _, migration := range applied {
+		log.Printf("Applied migration %d: %s", migration.Version, migration.Name)
+	}
+	if err := initSearch(db, fullText); err != nil {
+		log.Fatalf("Failed to set up full-text search: %v", err)
 	}
 
+	if created {
+		log.Println("Created a new database without users, run `gochat create-admin` to add an administrator")
+	}
 	return db
-}
\ No newline at end of file
+}
+
+/*
+CloseDB closes the connection pool underlying the database connection.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(error) An error if the connection could not be closed.
+*/
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"errors"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrFolderNotFound is returned when a folder does not exist.
+	ErrFolderNotFound = errors.New("folder not found")
+	// ErrFolderForbidden is returned when a folder exists but belongs to another user.
+	ErrFolderForbidden = errors.New("folder belongs to another user")
+)
+
+/*
+GetFoldersForUser retrieves the folders of a user, ordered by name.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	([]models.Folder) The folders, or an error if the operation failed.
+*/
+func GetFoldersForUser(db *gorm.DB, userID uint) ([]models.Folder, error) {
+	var folders []models.Folder
+	err := db.Where("user_id = ?", userID).Order("name, id").Find(&folders).Error
+	return folders, err
+}
+
+/*
+AddFolder adds a new folder to the database.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `folder` (*models.Folder) The folder to add.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func AddFolder(db *gorm.DB, folder *models.Folder) error {
+	return db.Create(folder).Error
+}
+
+/*
+GetFolderForUser retrieves a folder by ID if it belongs to the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `folderID` (uint) The ID of the folder.
+	* `userID` (uint) The ID of the user accessing the folder.
+
+- Returns:
+	(*models.Folder) The folder, ErrFolderNotFound if it does not exist, ErrFolderForbidden if it belongs to another
+	user, or another error if the operation failed.
+*/
+func GetFolderForUser(db *gorm.DB, folderID, userID uint) (*models.Folder, error) {
+	var folder models.Folder
+	if err := db.First(&folder, folderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFolderNotFound
+		}
+		return nil, err
+	}
+
+	if folder.UserID != userID {
+		return nil, ErrFolderForbidden
+	}
+	return &folder, nil
+}
+
+/*
+RenameFolderForUser sets the name of a folder if it belongs to the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `folderID` (uint) The ID of the folder.
+	* `userID` (uint) The ID of the user renaming the folder.
+	* `name` (string) The new name.
+
+- Returns:
+	(*models.Folder) The renamed folder, ErrFolderNotFound if it does not exist, ErrFolderForbidden if it belongs to
+	another user, or another error if the operation failed.
+*/
+func RenameFolderForUser(db *gorm.DB, folderID, userID uint, name string) (*models.Folder, error) {
+	folder, err := GetFolderForUser(db, folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(folder).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+/*
+DeleteFolderForUser deletes a folder if it belongs to the given user. Its chats, including those in the trash, are
+moved out of it rather than deleted.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `folderID` (uint) The ID of the folder.
+	* `userID` (uint) The ID of the user deleting the folder.
+
+- Returns:
+	(error) ErrFolderNotFound if the folder does not exist, ErrFolderForbidden if it belongs to another user, or
+	another error if the operation failed.
+*/
+func DeleteFolderForUser(db *gorm.DB, folderID, userID uint) error {
+	folder, err := GetFolderForUser(db, folderID, userID)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&models.Chat{}).
+			Where("folder_id = ?", folder.ID).
+			UpdateColumn("folder_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(folder).Error
+	})
+}
+
+/*
+MoveChatForUser files a chat of the given user into one of the user's folders, or takes it out of its folder.
+
+The time of the chat's last change is left alone, so moving a chat does not reorder it.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user moving the chat.
+	* `folderID` (*uint) The ID of the folder, or nil to take the chat out of its folder.
+
+- Returns:
+	* `*models.Chat` The moved chat with its tags.
+	* `*uint` The ID of the folder the chat was in before, or nil if it was in none.
+	* `error` ErrChatNotFound if the chat does not exist, ErrChatForbidden if it belongs to another user,
+	  ErrFolderNotFound or ErrFolderForbidden if the folder is not one of the user's, or another error if the
+	  operation failed.
+*/
+func MoveChatForUser(db *gorm.DB, chatID, userID uint, folderID *uint) (*models.Chat, *uint, error) {
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if folderID != nil {
+		if _, err := GetFolderForUser(db, *folderID, userID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	previous := chat.FolderID
+	if err := db.Model(chat).UpdateColumn("folder_id", folderID).Error; err != nil {
+		return nil, nil, err
+	}
+	chat.FolderID = folderID
+	return chat, previous, nil
+}
+
+/*
+SetChatPinnedForUser pins a chat of the given user to the top of its list, or unpins it.
+
+The time of the chat's last change is left alone, so an unpinned chat returns to its place.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user pinning the chat.
+	* `pinned` (bool) Whether the chat is pinned.
+
+- Returns:
+	(*models.Chat) The chat with its tags, ErrChatNotFound if it does not exist, ErrChatForbidden if it belongs to
+	another user, or another error if the operation failed.
+*/
+func SetChatPinnedForUser(db *gorm.DB, chatID, userID uint, pinned bool) (*models.Chat, error) {
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(chat).UpdateColumn("pinned", pinned).Error; err != nil {
+		return nil, err
+	}
+	chat.Pinned = pinned
+	return chat, nil
+}
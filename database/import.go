@@ -0,0 +1,158 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"gochat/models"
+	"gochat/transcript"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back the import transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+/*
+ImportReport describes the chats an import created, or would create in a dry run.
+
+- Fields:
+	* `DryRun` (bool) True if nothing was saved.
+	* `Chats` ([]ImportedChatReport) The chats in the order they were imported.
+	* `Messages` (int) The total number of messages.
+	* `Skipped` (int) The total number of messages left out.
+*/
+type ImportReport struct {
+	DryRun   bool                 `json:"dry_run"`
+	Chats    []ImportedChatReport `json:"chats"`
+	Messages int                  `json:"messages"`
+	Skipped  int                  `json:"skipped"`
+}
+
+/*
+ImportedChatReport describes a single imported chat.
+
+- Fields:
+	* `ID` (uint) The ID of the new chat, or 0 in a dry run.
+	* `Title` (string) The title of the chat.
+	* `Messages` (int) The number of messages.
+	* `Skipped` (int) The number of messages left out, such as system prompts and tool calls.
+*/
+type ImportedChatReport struct {
+	ID       uint   `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Messages int    `json:"messages"`
+	Skipped  int    `json:"skipped"`
+}
+
+/*
+ImportChatsForUser saves imported chats for the given user.
+
+All chats are saved in a single transaction, so a failing chat leaves nothing behind. The original timestamps of
+chats and messages are kept where the file has them. In a dry run the transaction is rolled back and the report
+describes what would have been created.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user the chats are imported for.
+	* `chats` ([]transcript.ImportedChat) The chats read by transcript.ParseImport.
+	* `dryRun` (bool) True to only report what would be created.
+
+- Returns:
+	(*ImportReport) The report, or an error if the chats could not be saved.
+*/
+func ImportChatsForUser(db *gorm.DB, userID uint, chats []transcript.ImportedChat, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Chats: []ImportedChatReport{}}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, imported := range chats {
+			chatReport, err := importChat(tx, userID, imported)
+			if err != nil {
+				return fmt.Errorf("chat %q: %w", imported.Title, err)
+			}
+			report.Chats = append(report.Chats, chatReport)
+			report.Messages += chatReport.Messages
+			report.Skipped += chatReport.Skipped
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if errors.Is(err, errDryRun) {
+		for i := range report.Chats {
+			report.Chats[i].ID = 0
+		}
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+/*
+importChat saves a single imported chat with its messages.
+
+- Args:
+	* `db` (*gorm.DB) The database connection, inside the import transaction.
+	* `userID` (uint) The ID of the user the chat is imported for.
+	* `imported` (transcript.ImportedChat) The chat.
+
+- Returns:
+	(ImportedChatReport) The report for the chat, or an error if it could not be saved.
+*/
+func importChat(db *gorm.DB, userID uint, imported transcript.ImportedChat) (ImportedChatReport, error) {
+	chat := models.Chat{UserID: userID, Title: imported.Title}
+	chat.CreatedAt = imported.CreatedAt
+	chat.UpdatedAt = imported.UpdatedAt
+	if err := db.Create(&chat).Error; err != nil {
+		return ImportedChatReport{}, err
+	}
+
+	ids := make(map[string]uint, len(imported.Messages))
+	var last uint
+	for _, importedMessage := range imported.Messages {
+		message := models.Message{
+			ChatID:      chat.ID,
+			UserID:      userID,
+			Message:     importedMessage.Message,
+			MessageType: importedMessage.MessageType,
+		}
+		message.CreatedAt = importedMessage.CreatedAt
+		message.UpdatedAt = importedMessage.CreatedAt
+
+		if importedMessage.ParentKey != "" {
+			parentID, found := ids[importedMessage.ParentKey]
+			if !found {
+				return ImportedChatReport{}, fmt.Errorf("message %s follows unknown message %s", importedMessage.Key, importedMessage.ParentKey)
+			}
+			message.ParentID = &parentID
+		}
+
+		if err := db.Create(&message).Error; err != nil {
+			return ImportedChatReport{}, err
+		}
+		ids[importedMessage.Key] = message.ID
+		last = message.ID
+	}
+
+	active := last
+	if id, found := ids[imported.ActiveKey]; found {
+		active = id
+	}
+	if active != 0 {
+		// UpdateColumn keeps the imported updated_at.
+		if err := db.Model(&chat).UpdateColumn("active_message_id", active).Error; err != nil {
+			return ImportedChatReport{}, err
+		}
+	}
+
+	return ImportedChatReport{
+		ID:       chat.ID,
+		Title:    imported.Title,
+		Messages: len(imported.Messages),
+		Skipped:  imported.Skipped,
+	}, nil
+}
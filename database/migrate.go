@@ -0,0 +1,236 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSchemaTooNew is returned when the database has migrations applied that this version of gochat does not know.
+var ErrSchemaTooNew = errors.New("the database schema is newer than this version of gochat")
+
+/*
+Migration is a versioned change to the database schema or its data.
+
+Migrations are applied in order of their version, each in its own transaction together with the record of it in the
+`schema_migrations` table. A migration must not use the types of the models package, nor the functions of this package
+that query through them, because those describe the latest schema rather than the one the migration starts from. It
+uses frozen copies of the types, such as v1Chat, or plain table names instead.
+
+- Fields:
+	* `Version` (int) The version of the migration; versions are never reused.
+	* `Name` (string) A short description of the migration.
+	* `Up` (func(*gorm.DB) error) Applies the migration.
+	* `Down` (func(*gorm.DB) error) Reverts the migration, or nil if there is nothing to revert, as with data fixes
+	  that leave the schema unchanged.
+*/
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// schemaMigration records a migration applied to the database.
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName names the table of the applied migrations.
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+/*
+MigrationStatus describes a migration and whether it has been applied to the database.
+
+- Fields:
+	* `Version` (int) The version of the migration.
+	* `Name` (string) The name of the migration.
+	* `AppliedAt` (*time.Time) When the migration was applied, or nil if it is pending.
+	* `Known` (bool) False if the migration was applied by a newer version of gochat.
+*/
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Known     bool
+}
+
+/*
+appliedMigrations retrieves the migrations applied to the database, creating the table that records them if needed.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	([]schemaMigration) The applied migrations ordered by version, or an error if the operation failed.
+*/
+func appliedMigrations(db *gorm.DB) ([]schemaMigration, error) {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+	var applied []schemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+/*
+checkSchemaVersion makes sure every applied migration is known to this version of gochat.
+
+- Args:
+	* `applied` ([]schemaMigration) The applied migrations.
+
+- Returns:
+	(error) ErrSchemaTooNew naming the unknown migrations, or nil.
+*/
+func checkSchemaVersion(applied []schemaMigration) error {
+	var unknown []int
+	for _, record := range applied {
+		if findMigration(record.Version) == nil {
+			unknown = append(unknown, record.Version)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: unknown migrations %v", ErrSchemaTooNew, unknown)
+	}
+	return nil
+}
+
+/*
+findMigration looks up a migration by its version.
+
+- Args:
+	* `version` (int) The version of the migration.
+
+- Returns:
+	(*Migration) The migration, or nil if there is none with that version.
+*/
+func findMigration(version int) *Migration {
+	index := slices.IndexFunc(migrations, func(migration Migration) bool {
+		return migration.Version == version
+	})
+	if index < 0 {
+		return nil
+	}
+	return &migrations[index]
+}
+
+/*
+MigrationStatuses lists the known migrations and any unknown ones applied to the database.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	([]MigrationStatus) The migrations ordered by version, or an error if the operation failed.
+*/
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		statuses = append(statuses, MigrationStatus{Version: migration.Version, Name: migration.Name, Known: true})
+	}
+	for _, record := range applied {
+		index := slices.IndexFunc(statuses, func(status MigrationStatus) bool { return status.Version == record.Version })
+		if index < 0 {
+			statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name})
+			index = len(statuses) - 1
+		}
+		appliedAt := record.AppliedAt
+		statuses[index].AppliedAt = &appliedAt
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int { return a.Version - b.Version })
+	return statuses, nil
+}
+
+/*
+MigrateUp applies every pending migration in order of version.
+
+It stops at the first migration that fails; the migrations applied before it stay applied.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	([]Migration) The migrations applied, ErrSchemaTooNew if the database has unknown migrations applied, or another
+	error if a migration failed.
+*/
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		isApplied := slices.ContainsFunc(applied, func(record schemaMigration) bool { return record.Version == migration.Version })
+		if isApplied {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+/*
+MigrateDown reverts the most recently applied migrations, newest first.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `steps` (int) The number of migrations to revert.
+
+- Returns:
+	([]Migration) The migrations reverted, ErrSchemaTooNew if the database has unknown migrations applied, or another
+	error if a migration failed.
+*/
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := *findMigration(applied[i].Version)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if migration.Down != nil {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&schemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
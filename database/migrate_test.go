@@ -0,0 +1,185 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+func TestMigrateUpAndDown(t *testing.T) {
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			db := openTestDB(t, backend.dsn(t))
+
+			applied, err := MigrateUp(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(applied) != len(migrations) {
+				t.Fatalf("MigrateUp applied %d migrations, want %d", len(applied), len(migrations))
+			}
+			if applied, err := MigrateUp(db); err != nil || len(applied) != 0 {
+				t.Fatalf("MigrateUp on an up to date database applied %d migrations: %v", len(applied), err)
+			}
+			statuses, err := MigrationStatuses(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, status := range statuses {
+				if status.AppliedAt == nil || !status.Known {
+					t.Errorf("migration %d is not applied after MigrateUp", status.Version)
+				}
+			}
+			addTestChat(t, db, "alice", "hello")
+
+			reverted, err := MigrateDown(db, len(migrations))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reverted) != len(migrations) || reverted[0].Version != migrations[len(migrations)-1].Version {
+				t.Fatalf("MigrateDown reverted %d migrations starting at %d", len(reverted), reverted[0].Version)
+			}
+			for _, table := range []string{"users", "chats", "messages", "folders", "chat_tags", "attachments"} {
+				if db.Migrator().HasTable(table) {
+					t.Errorf("table %s still exists after reverting every migration", table)
+				}
+			}
+
+			if _, err := MigrateUp(db); err != nil {
+				t.Fatalf("MigrateUp after reverting every migration failed: %v", err)
+			}
+			addTestChat(t, db, "bob", "hello again")
+		})
+	}
+}
+
+func TestMigrateDownOneStep(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		latest := migrations[len(migrations)-1]
+		reverted, err := MigrateDown(db, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reverted) != 1 || reverted[0].Version != latest.Version {
+			t.Fatalf("MigrateDown(1) reverted %v, want migration %d", reverted, latest.Version)
+		}
+		statuses, err := MigrationStatuses(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, status := range statuses {
+			if pending := status.AppliedAt == nil; pending != (status.Version == latest.Version) {
+				t.Errorf("migration %d pending = %v after reverting one step", status.Version, pending)
+			}
+		}
+	})
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		future := schemaMigration{Version: migrations[len(migrations)-1].Version + 1, Name: "from the future", AppliedAt: time.Now()}
+		if err := db.Create(&future).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := MigrateUp(db); !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("MigrateUp = %v, want ErrSchemaTooNew", err)
+		}
+		if _, err := MigrateDown(db, 1); !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("MigrateDown = %v, want ErrSchemaTooNew", err)
+		}
+	})
+}
+
+// TestMigrateLegacyDatabase migrates a database created before versioned migrations, which has the tables of schema
+// version 1, the seeded default_user and chats whose messages are not linked into branches.
+func TestMigrateLegacyDatabase(t *testing.T) {
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			db := openTestDB(t, backend.dsn(t))
+			if err := createSchemaV1(db); err != nil {
+				t.Fatal(err)
+			}
+
+			defaultUser := v1User{Username: "default_user", Password: "password123"}
+			keptUser := v1User{Username: "carol", Password: "hash"}
+			if err := db.Create(&defaultUser).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&keptUser).Error; err != nil {
+				t.Fatal(err)
+			}
+			chat := v1Chat{UserID: keptUser.ID, Title: "legacy"}
+			if err := db.Create(&chat).Error; err != nil {
+				t.Fatal(err)
+			}
+			defaultChat := v1Chat{UserID: defaultUser.ID, Title: "written by anyone"}
+			if err := db.Create(&defaultChat).Error; err != nil {
+				t.Fatal(err)
+			}
+			defaultRows := []any{
+				&v1Message{ChatID: defaultChat.ID, UserID: defaultUser.ID, Message: "hi", MessageType: "USER"},
+				&v1ChatSummary{ChatID: defaultChat.ID, Content: "a summary"},
+				&v1APIToken{UserID: defaultUser.ID, Name: "token", TokenHash: "hash"},
+				&v1PromptTemplate{UserID: defaultUser.ID, Name: "template", Body: "body"},
+			}
+			for _, row := range defaultRows {
+				if err := db.Omit("User").Create(row).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var messageIDs []uint
+			for _, text := range []string{"first", "second", "third"} {
+				message := v1Message{ChatID: chat.ID, UserID: keptUser.ID, Message: text, MessageType: "USER"}
+				if err := db.Create(&message).Error; err != nil {
+					t.Fatal(err)
+				}
+				messageIDs = append(messageIDs, message.ID)
+			}
+
+			if _, err := MigrateUp(db); err != nil {
+				t.Fatal(err)
+			}
+
+			var users []string
+			db.Model(&models.User{}).Unscoped().Order("id").Pluck("username", &users)
+			if len(users) != 1 || users[0] != "carol" {
+				t.Errorf("users after migrating = %v, want [carol]", users)
+			}
+			for _, model := range []any{&v1Chat{}, &v1APIToken{}, &v1PromptTemplate{}} {
+				var count int64
+				db.Model(model).Unscoped().Where("user_id = ?", defaultUser.ID).Count(&count)
+				if count != 0 {
+					t.Errorf("%d rows of %T of default_user remain", count, model)
+				}
+			}
+			for _, model := range []any{&v1Message{}, &v1ChatSummary{}} {
+				var count int64
+				db.Model(model).Unscoped().Where("chat_id = ?", defaultChat.ID).Count(&count)
+				if count != 0 {
+					t.Errorf("%d rows of %T in the chat of default_user remain", count, model)
+				}
+			}
+
+			path, err := GetActivePath(db, chat.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(path) != len(messageIDs) {
+				t.Fatalf("active path has %d messages, want %d", len(path), len(messageIDs))
+			}
+			for i, message := range path {
+				if message.ID != messageIDs[i] {
+					t.Errorf("message %d of the active path is %d, want %d", i, message.ID, messageIDs[i])
+				}
+				if i > 0 && (message.ParentID == nil || *message.ParentID != messageIDs[i-1]) {
+					t.Errorf("message %d does not follow message %d", message.ID, messageIDs[i-1])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations lists every migration in order of version. New migrations are appended; applied ones are never changed.
+var migrations = []Migration{
+	{Version: 1, Name: "create schema", Up: createSchemaV1, Down: dropSchemaV1},
+	{Version: 2, Name: "remove insecure default user", Up: removeInsecureDefaultUser},
+	{Version: 3, Name: "link message parents", Up: linkMessageParents},
+	{Version: 4, Name: "add folders, tags and pinning", Up: addChatOrganization, Down: dropChatOrganization},
+	{Version: 5, Name: "add message attachments", Up: addAttachments, Down: dropAttachments},
+}
+
+// v1GenerationSettings is models.GenerationSettings as of schema version 1.
+type v1GenerationSettings struct {
+	SystemPrompt string
+	Model        string
+	Temperature  *float64
+	TopP         *float64
+	MaxTokens    *int
+}
+
+// v1User is models.User as of schema version 1.
+type v1User struct {
+	gorm.Model
+	Username        string `gorm:"unique"`
+	Password        string
+	IsAdmin         bool
+	DefaultSettings v1GenerationSettings `gorm:"embedded;embeddedPrefix:default_"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+// v1APIToken is models.APIToken as of schema version 1.
+type v1APIToken struct {
+	gorm.Model
+	UserID     uint `gorm:"index"`
+	Name       string
+	TokenHash  string `gorm:"size:64;uniqueIndex"`
+	LastUsedAt *time.Time
+}
+
+func (v1APIToken) TableName() string { return "api_tokens" }
+
+// v1Chat is models.Chat as of schema version 1.
+type v1Chat struct {
+	gorm.Model
+	UserID          uint
+	Title           string
+	ActiveMessageID *uint
+	Settings        v1GenerationSettings `gorm:"embedded"`
+	Messages        []v1Message          `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE;"`
+}
+
+func (v1Chat) TableName() string { return "chats" }
+
+// v1Message is models.Message as of schema version 1.
+type v1Message struct {
+	gorm.Model
+	ChatID      uint
+	ParentID    *uint `gorm:"index"`
+	UserID      uint
+	Message     string
+	MessageType string
+}
+
+func (v1Message) TableName() string { return "messages" }
+
+// v1PromptTemplate is models.PromptTemplate as of schema version 1.
+type v1PromptTemplate struct {
+	gorm.Model
+	UserID      uint `gorm:"index"`
+	Name        string
+	Description string
+	Body        string
+	Shared      bool `gorm:"index"`
+	User        v1User
+}
+
+func (v1PromptTemplate) TableName() string { return "prompt_templates" }
+
+// v1ChatSummary is models.ChatSummary as of schema version 1.
+type v1ChatSummary struct {
+	gorm.Model
+	ChatID           uint `gorm:"index"`
+	ThroughMessageID uint
+	Content          string
+}
+
+func (v1ChatSummary) TableName() string { return "chat_summaries" }
+
+// v4Chat holds the columns schema version 4 adds to chats.
+type v4Chat struct {
+	gorm.Model
+	FolderID *uint       `gorm:"index"`
+	Pinned   bool        `gorm:"not null;default:false"`
+	Tags     []v4ChatTag `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE;"`
+}
+
+func (v4Chat) TableName() string { return "chats" }
+
+// v4Folder is models.Folder as of schema version 4.
+type v4Folder struct {
+	gorm.Model
+	UserID uint `gorm:"index"`
+	Name   string
+}
+
+func (v4Folder) TableName() string { return "folders" }
+
+// v4ChatTag is models.ChatTag as of schema version 4.
+type v4ChatTag struct {
+	ID     uint   `gorm:"primaryKey"`
+	ChatID uint   `gorm:"uniqueIndex:idx_chat_tags_chat_name"`
+	Name   string `gorm:"size:32;uniqueIndex:idx_chat_tags_chat_name;index"`
+}
+
+func (v4ChatTag) TableName() string { return "chat_tags" }
+
+// v5Attachment is models.Attachment as of schema version 5. It has no foreign key on its message, since purging a chat
+// deletes the messages while the attachments stay until their files are removed.
+type v5Attachment struct {
+	gorm.Model
+	MessageID    uint `gorm:"index"`
+	Filename     string
+	ContentType  string
+	Size         int64
+	StorageKey   string
+	ThumbnailKey string
+	Text         string
+}
+
+func (v5Attachment) TableName() string { return "attachments" }
+
+/*
+createSchemaV1 creates the tables of schema version 1.
+
+Databases created before versioned migrations already have these tables, possibly without the columns added later;
+the missing columns and indexes are added and existing ones are left alone.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func createSchemaV1(tx *gorm.DB) error {
+	return tx.AutoMigrate(&v1User{}, &v1APIToken{}, &v1Chat{}, &v1Message{}, &v1PromptTemplate{}, &v1ChatSummary{})
+}
+
+/*
+dropSchemaV1 drops the tables of schema version 1, together with the SQLite full-text index over messages.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func dropSchemaV1(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v1ChatSummary{}, &v1PromptTemplate{}, &v1Message{}, &v1Chat{}, &v1APIToken{}, &v1User{}, "messages_fts")
+}
+
+/*
+removeInsecureDefaultUser deletes the `default_user` account seeded by earlier versions, together with its chats, their
+messages and summaries, its API tokens and its prompt templates.
+
+That account was created with a publicly known password stored in plaintext, so anything saved under it may have been
+written by anyone. It is only removed while it still has that password, so an account that was deliberately given a
+real password is left alone.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func removeInsecureDefaultUser(tx *gorm.DB) error {
+	var userIDs []uint
+	err := tx.Model(&v1User{}).Unscoped().
+		Where("username = ? AND password = ?", "default_user", "password123").
+		Pluck("id", &userIDs).Error
+	if err != nil || len(userIDs) == 0 {
+		return err
+	}
+
+	var chatIDs []uint
+	if err := tx.Model(&v1Chat{}).Unscoped().Where("user_id IN ?", userIDs).Pluck("id", &chatIDs).Error; err != nil {
+		return err
+	}
+
+	deletions := []struct {
+		model  any
+		column string
+		ids    []uint
+	}{
+		{&v1ChatSummary{}, "chat_id", chatIDs},
+		{&v1Message{}, "chat_id", chatIDs},
+		{&v1Chat{}, "id", chatIDs},
+		{&v1APIToken{}, "user_id", userIDs},
+		{&v1PromptTemplate{}, "user_id", userIDs},
+		{&v1User{}, "id", userIDs},
+	}
+	for _, deletion := range deletions {
+		if len(deletion.ids) == 0 {
+			continue
+		}
+		if err := tx.Unscoped().Where(deletion.column+" IN ?", deletion.ids).Delete(deletion.model).Error; err != nil {
+			return err
+		}
+	}
+	log.Printf("Removed the insecure default_user account and its %d chats, run `gochat create-admin` to add an administrator", len(chatIDs))
+	return nil
+}
+
+/*
+linkMessageParents turns the flat message lists of chats created before branching was added into a single branch.
+
+Each message becomes the child of the message created before it, and the last message becomes the active one.
+Chats that already have an active message are left alone.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func linkMessageParents(tx *gorm.DB) error {
+	var chatIDs []uint
+	err := tx.Model(&v1Message{}).
+		Where("chat_id IN (?)", tx.Model(&v1Chat{}).Select("id").Where("active_message_id IS NULL")).
+		Distinct().
+		Pluck("chat_id", &chatIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, chatID := range chatIDs {
+		var messages []v1Message
+		if err := tx.Where("chat_id = ?", chatID).Order("id ASC").Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		for i := 1; i < len(messages); i++ {
+			if messages[i].ParentID != nil {
+				continue
+			}
+			if err := tx.Model(&messages[i]).Update("parent_id", messages[i-1].ID).Error; err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&v1Chat{}).Where("id = ?", chatID).Update("active_message_id", messages[len(messages)-1].ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(chatIDs) > 0 {
+		log.Printf("Linked the messages of %d chats into branches", len(chatIDs))
+	}
+	return nil
+}
+
+/*
+addChatOrganization adds folders, tags and the pinned flag of chats. Existing chats start unpinned and outside any
+folder.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func addChatOrganization(tx *gorm.DB) error {
+	return tx.AutoMigrate(&v4Folder{}, &v4Chat{}, &v4ChatTag{})
+}
+
+/*
+dropChatOrganization drops the tables and columns added by addChatOrganization.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func dropChatOrganization(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if err := migrator.DropTable(&v4ChatTag{}, &v4Folder{}); err != nil {
+		return err
+	}
+	if migrator.HasIndex(&v4Chat{}, "FolderID") {
+		if err := migrator.DropIndex(&v4Chat{}, "FolderID"); err != nil {
+			return err
+		}
+	}
+	for _, column := range []string{"FolderID", "Pinned"} {
+		if err := migrator.DropColumn(&v4Chat{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+addAttachments creates the table of the files attached to messages.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func addAttachments(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&v5Attachment{})
+}
+
+/*
+dropAttachments drops the table created by addAttachments. The stored files are left in the storage.
+
+- Args:
+	* `tx` (*gorm.DB) The transaction of the migration.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func dropAttachments(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v5Attachment{})
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+/*
+ChatCursor marks a position in a user's chat list, which lists pinned chats first and then orders by the time of the
+last change and by ID, newest first. A page that starts after the cursor holds the chats that sort after it.
+
+- Fields:
+	* `Pinned` (bool) Whether the last chat of the previous page is pinned.
+	* `UpdatedAt` (time.Time) The time the last chat of the previous page was changed.
+	* `ID` (uint) The ID of the last chat of the previous page.
+*/
+type ChatCursor struct {
+	Pinned    bool
+	UpdatedAt time.Time
+	ID        uint
+}
+
+/*
+ChatFilter narrows a user's chat list down.
+
+- Fields:
+	* `FolderID` (*uint) Only chats in this folder, 0 for chats outside any folder, or nil for chats in any folder.
+	* `Tag` (string) Only chats with this tag, or an empty string for chats with any tags.
+*/
+type ChatFilter struct {
+	FolderID *uint
+	Tag      string
+}
+
+/*
+String encodes the cursor as an opaque, URL safe string.
+
+- Returns:
+	(string) The encoded cursor.
+*/
+func (cursor ChatCursor) String() string {
+	raw := strconv.FormatBool(cursor.Pinned) + "|" + cursor.UpdatedAt.Format(time.RFC3339Nano) + "|" +
+		strconv.FormatUint(uint64(cursor.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+/*
+ParseChatCursor decodes a cursor produced by ChatCursor.String.
+
+- Args:
+	* `value` (string) The encoded cursor.
+
+- Returns:
+	(ChatCursor) The cursor, or ErrInvalidCursor if the value is not a cursor.
+*/
+func ParseChatCursor(value string) (ChatCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return ChatCursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return ChatCursor{}, ErrInvalidCursor
+	}
+	pinned, timestamp, id := parts[0], parts[1], parts[2]
+
+	isPinned, err := strconv.ParseBool(pinned)
+	if err != nil {
+		return ChatCursor{}, ErrInvalidCursor
+	}
+	updatedAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return ChatCursor{}, ErrInvalidCursor
+	}
+	parsedID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return ChatCursor{}, ErrInvalidCursor
+	}
+	return ChatCursor{Pinned: isPinned, UpdatedAt: updatedAt, ID: uint(parsedID)}, nil
+}
+
+/*
+GetChatPageForUser retrieves a page of a user's chats, pinned chats first and then most recently changed first, with
+their tags but without their messages.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+	* `filter` (ChatFilter) The chats to list.
+	* `after` (*ChatCursor) The position of the last chat of the previous page, or nil for the first page.
+	* `limit` (int) The maximum number of chats on the page.
+
+- Returns:
+	* `[]models.Chat` The chats of the page.
+	* `*ChatCursor` The cursor of the next page, or nil if this is the last page.
+	* `error` An error if the operation failed.
+*/
+func GetChatPageForUser(db *gorm.DB, userID uint, filter ChatFilter, after *ChatCursor, limit int) ([]models.Chat, *ChatCursor, error) {
+	query := db.Preload("Tags", orderTags).Where("user_id = ?", userID)
+	switch {
+	case filter.FolderID == nil:
+	case *filter.FolderID == 0:
+		query = query.Where("folder_id IS NULL")
+	default:
+		query = query.Where("folder_id = ?", *filter.FolderID)
+	}
+	if filter.Tag != "" {
+		query = query.Where("id IN (?)", db.Model(&models.ChatTag{}).Select("chat_id").Where("name = ?", filter.Tag))
+	}
+	if after != nil {
+		later := db.Where("updated_at < ? OR (updated_at = ? AND id < ?)", after.UpdatedAt, after.UpdatedAt, after.ID)
+		if after.Pinned {
+			// The unpinned chats all follow the pinned ones.
+			query = query.Where(db.Where("pinned = ?", false).Or(db.Where("pinned = ?", true).Where(later)))
+		} else {
+			query = query.Where("pinned = ?", false).Where(later)
+		}
+	}
+
+	// One more chat than requested tells whether another page follows.
+	var chats []models.Chat
+	if err := query.Order("pinned DESC, updated_at DESC, id DESC").Limit(limit + 1).Find(&chats).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(chats) <= limit {
+		return chats, nil, nil
+	}
+
+	chats = chats[:limit]
+	last := chats[limit-1]
+	return chats, &ChatCursor{Pinned: last.Pinned, UpdatedAt: last.UpdatedAt, ID: last.ID}, nil
+}
+
+/*
+MessagePage selects a page of the active branch of a chat, counted back from its end.
+
+- Fields:
+	* `Before` (uint) The ID of the first message of the previous page; the page ends just before it. Zero for the
+	  page that ends with the active message.
+	* `Limit` (int) The maximum number of messages on the page.
+	* `Through` (uint) The ID of a message the page has to reach back to, even if that exceeds the limit. Zero for
+	  none.
+*/
+type MessagePage struct {
+	Before  uint
+	Limit   int
+	Through uint
+}
+
+/*
+GetChatMessagePageForUser retrieves a chat with a page of the messages of its active branch if it belongs to the given
+user.
+
+Only the messages of the page are loaded in full, so long chats are cheap to open.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user requesting the chat.
+	* `page` (MessagePage) The page to load.
+
+- Returns:
+	* `*models.Chat` The chat, with the messages of the page in chronological order.
+	* `bool` True if older messages precede the page.
+	* `error` ErrChatNotFound if the chat does not exist, ErrChatForbidden if it belongs to another user,
+	  ErrMessageNotFound if Before is not a message of the active branch, or another error if the operation failed.
+*/
+func GetChatMessagePageForUser(db *gorm.DB, chatID, userID uint, page MessagePage) (*models.Chat, bool, error) {
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	path, err := activePathIDs(db, chat)
+	if err != nil {
+		return nil, false, err
+	}
+
+	end := len(path)
+	if page.Before != 0 {
+		if end = slices.Index(path, page.Before); end < 0 {
+			return nil, false, ErrMessageNotFound
+		}
+	}
+	start := max(end-page.Limit, 0)
+	if through := slices.Index(path[:end], page.Through); page.Through != 0 && through >= 0 {
+		start = min(start, through)
+	}
+
+	if chat.Messages, err = loadMessages(db, path[start:end]); err != nil {
+		return nil, false, err
+	}
+	return chat, start > 0, nil
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPromptTemplateNotFound is returned when a prompt template does not exist or is not visible to the user.
+	ErrPromptTemplateNotFound = errors.New("prompt template not found")
+	// ErrPromptTemplateForbidden is returned when a user tries to change a template shared by another user.
+	ErrPromptTemplateForbidden = errors.New("prompt template belongs to another user")
+)
+
+/*
+GetPromptTemplatesForUser retrieves the prompt templates a user can use: their own and those shared by others.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	([]models.PromptTemplate) The templates with their owners, the user's own first, each group sorted by name, or an
+	error if the operation failed.
+*/
+func GetPromptTemplatesForUser(db *gorm.DB, userID uint) ([]models.PromptTemplate, error) {
+	var templates []models.PromptTemplate
+	err := db.Preload("User").
+		Where("user_id = ? OR shared = ?", userID, true).
+		Order("name").
+		Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(templates, func(a, b models.PromptTemplate) int {
+		return cmp.Compare(ownerRank(a, userID), ownerRank(b, userID))
+	})
+	return templates, nil
+}
+
+/*
+GetPromptTemplateForUser retrieves a prompt template the user owns or that is shared with everyone.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `templateID` (uint) The ID of the template.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	(*models.PromptTemplate) The template with its owner, ErrPromptTemplateNotFound if it does not exist or is private
+	to another user, or another error if the operation failed.
+*/
+func GetPromptTemplateForUser(db *gorm.DB, templateID, userID uint) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	err := db.Preload("User").
+		Where("user_id = ? OR shared = ?", userID, true).
+		First(&template, templateID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPromptTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+/*
+AddPromptTemplate adds a prompt template to the library of its owner.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `template` (*models.PromptTemplate) The template, with UserID set to its owner.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func AddPromptTemplate(db *gorm.DB, template *models.PromptTemplate) error {
+	return db.Omit("User").Create(template).Error
+}
+
+/*
+UpdatePromptTemplateForUser changes the name, description, body and sharing of a prompt template.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `templateID` (uint) The ID of the template.
+	* `userID` (uint) The ID of the user changing the template.
+	* `changes` (models.PromptTemplate) The new name, description, body and sharing.
+
+- Returns:
+	(*models.PromptTemplate) The updated template, ErrPromptTemplateNotFound if it is not visible to the user,
+	ErrPromptTemplateForbidden if it is shared by another user, or another error if the operation failed.
+*/
+func UpdatePromptTemplateForUser(db *gorm.DB, templateID, userID uint, changes models.PromptTemplate) (*models.PromptTemplate, error) {
+	template, err := authorizePromptTemplate(db, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Model(template).Select("name", "description", "body", "shared").Updates(&models.PromptTemplate{
+		Name:        changes.Name,
+		Description: changes.Description,
+		Body:        changes.Body,
+		Shared:      changes.Shared,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+/*
+DeletePromptTemplateForUser deletes a prompt template.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `templateID` (uint) The ID of the template.
+	* `userID` (uint) The ID of the user deleting the template.
+
+- Returns:
+	(error) ErrPromptTemplateNotFound if it is not visible to the user, ErrPromptTemplateForbidden if it is shared by
+	another user, or another error if the operation failed.
+*/
+func DeletePromptTemplateForUser(db *gorm.DB, templateID, userID uint) error {
+	template, err := authorizePromptTemplate(db, templateID, userID)
+	if err != nil {
+		return err
+	}
+	return db.Delete(template).Error
+}
+
+/*
+authorizePromptTemplate loads a prompt template and checks that the user may change it.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `templateID` (uint) The ID of the template.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	(*models.PromptTemplate) The template with its owner, ErrPromptTemplateNotFound if it is not visible to the
+	user, ErrPromptTemplateForbidden if it is shared by another user, or another error if the operation failed.
+*/
+func authorizePromptTemplate(db *gorm.DB, templateID, userID uint) (*models.PromptTemplate, error) {
+	template, err := GetPromptTemplateForUser(db, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if template.UserID != userID {
+		return nil, ErrPromptTemplateForbidden
+	}
+	return template, nil
+}
+
+/*
+ownerRank orders a user's own templates before those shared by others.
+
+- Args:
+	* `template` (models.PromptTemplate) The template.
+	* `userID` (uint) The ID of the user listing templates.
+
+- Returns:
+	(int) 0 for the user's own templates and 1 for the others.
+*/
+func ownerRank(template models.PromptTemplate, userID uint) int {
+	if template.UserID == userID {
+		return 0
+	}
+	return 1
+}
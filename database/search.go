@@ -0,0 +1,520 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// highlightStart and highlightEnd mark matches in snippets until they are turned into <mark> elements.
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+	// snippetWords is the number of words in a full-text search snippet.
+	snippetWords = 16
+	// snippetRunes is the number of characters around the first match in a LIKE search snippet.
+	snippetRunes = 60
+)
+
+// searchEngine is the way SearchMessagesForUser looks up messages.
+type searchEngine int
+
+const (
+	// searchEngineLike scans message texts with LIKE.
+	searchEngineLike searchEngine = iota
+	// searchEngineFTS5 queries the SQLite FTS5 index messages_fts.
+	searchEngineFTS5
+	// searchEnginePostgres matches the PostgreSQL text search index messages_message_tsv.
+	searchEnginePostgres
+	// searchEngineMySQL matches the MySQL FULLTEXT index messages_message_fulltext.
+	searchEngineMySQL
+)
+
+// engine is the search engine set up by initSearch.
+var engine = searchEngineLike
+
+// ErrNoFTS5 is returned when full-text search is required but the SQLite driver was built without FTS5.
+var ErrNoFTS5 = errors.New("SQLite was built without FTS5: build gochat with `make build` or `go build -tags sqlite_fts5`, " +
+	"or set database.search to like to search with LIKE")
+
+/*
+SearchHit is a message that matches a search.
+
+- Fields:
+	* `ChatID` (uint) The ID of the chat the message belongs to.
+	* `ChatTitle` (string) The title of the chat, empty if it has none.
+	* `MessageID` (uint) The ID of the message.
+	* `MessageType` (models.MessageType) Whether the user or the AI wrote the message.
+	* `Snippet` (string) An HTML-escaped excerpt of the message with the matches wrapped in <mark> elements.
+	* `Rank` (float64) The relevance of the hit; lower is more relevant. Always 0 without full-text search.
+	* `CreatedAt` (time.Time) When the message was written.
+*/
+type SearchHit struct {
+	ChatID      uint               `json:"chat_id"`
+	ChatTitle   string             `json:"chat_title"`
+	MessageID   uint               `json:"message_id"`
+	MessageType models.MessageType `json:"message_type"`
+	Snippet     string             `json:"snippet"`
+	Rank        float64            `json:"rank"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+/*
+initSearch sets up the full-text index over message texts for the database in use.
+
+Without full-text search SearchMessagesForUser uses a slower LIKE search. That is only done when asked for, so a build
+or database that cannot provide the index is reported instead of silently degrading search.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `fullText` (bool) Whether to set up the full-text index; false selects the LIKE search.
+
+- Returns:
+	(error) An error if the full-text index could not be set up.
+*/
+func initSearch(db *gorm.DB, fullText bool) error {
+	engine = searchEngineLike
+	if !fullText {
+		if db.Dialector.Name() == "sqlite" && !sqliteHasFTS5(db) {
+			dropSQLiteSearchTriggers(db)
+		}
+		log.Println("Full-text search is disabled, search uses LIKE")
+		return nil
+	}
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return initSQLiteSearch(db)
+	case "postgres":
+		return initPostgresSearch(db)
+	case "mysql":
+		return initMySQLSearch(db)
+	}
+	return nil
+}
+
+/*
+sqliteHasFTS5 reports whether the SQLite driver was compiled with FTS5.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(bool) True if FTS5 tables can be created.
+*/
+func sqliteHasFTS5(db *gorm.DB) bool {
+	var available bool
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available).Error
+	return err == nil && available
+}
+
+/*
+dropSQLiteSearchTriggers removes the triggers that keep the FTS5 index in sync, because without FTS5 they would make
+every write to the messages table fail.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+*/
+func dropSQLiteSearchTriggers(db *gorm.DB) {
+	for _, trigger := range []string{"messages_fts_insert", "messages_fts_delete", "messages_fts_update"} {
+		db.Exec("DROP TRIGGER IF EXISTS " + trigger)
+	}
+}
+
+/*
+initSQLiteSearch sets up the full-text index over message texts in SQLite.
+
+The index is an FTS5 table kept in sync with the messages table by triggers, so every insert, edit and hard delete is
+indexed without changes to the code that writes messages. It is rebuilt when it is first created.
+
+FTS5 is only compiled into the SQLite driver when gochat is built with `-tags sqlite_fts5`, which `make build` does.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(error) ErrNoFTS5 if the driver lacks FTS5, or an error if the index could not be created.
+*/
+func initSQLiteSearch(db *gorm.DB) error {
+	if !sqliteHasFTS5(db) {
+		return ErrNoFTS5
+	}
+
+	var indexed int64
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'messages_fts_insert'").Scan(&indexed)
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(message, content='messages', content_rowid='id')`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, message) VALUES (new.id, new.message);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, message) VALUES ('delete', old.id, old.message);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF message ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, message) VALUES ('delete', old.id, old.message);
+			INSERT INTO messages_fts(rowid, message) VALUES (new.id, new.message);
+		END`,
+	}
+	// Messages written while the triggers were missing are only picked up by a rebuild.
+	if indexed == 0 {
+		statements = append(statements, `INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	engine = searchEngineFTS5
+	return nil
+}
+
+/*
+initPostgresSearch sets up the full-text index over message texts in PostgreSQL.
+
+The index is a GIN expression index over the text search vector of each message, so it is kept up to date by
+PostgreSQL itself. The `simple` configuration is used because messages may be written in any language; it lowercases
+words without stemming them, like the FTS5 tokenizer does.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(error) An error if the index could not be created.
+*/
+func initPostgresSearch(db *gorm.DB) error {
+	err := db.Exec(`CREATE INDEX IF NOT EXISTS messages_message_tsv ON messages USING GIN (to_tsvector('simple', message))`).Error
+	if err != nil {
+		return err
+	}
+	engine = searchEnginePostgres
+	return nil
+}
+
+/*
+initMySQLSearch sets up the full-text index over message texts in MySQL.
+
+The index is an InnoDB FULLTEXT index, so it is kept up to date by MySQL itself. InnoDB does not index words shorter
+than `innodb_ft_min_token_size` (3 by default) or stopwords, so such words never match.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(error) An error if the index could not be created.
+*/
+func initMySQLSearch(db *gorm.DB) error {
+	if !db.Migrator().HasIndex(&models.Message{}, "messages_message_fulltext") {
+		err := db.Exec("CREATE FULLTEXT INDEX messages_message_fulltext ON messages (message)").Error
+		if err != nil {
+			return err
+		}
+	}
+	engine = searchEngineMySQL
+	return nil
+}
+
+/*
+SearchMessagesForUser searches the messages of every chat that belongs to the given user.
+
+Every word of the query must occur in a message; the last word also matches as a prefix. With full-text search the
+hits are ranked by relevance, otherwise the newest hits come first.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user searching.
+	* `query` (string) The words to search for.
+	* `limit` (int) The maximum number of hits.
+
+- Returns:
+	([]SearchHit) The hits, or an error if the operation failed.
+*/
+func SearchMessagesForUser(db *gorm.DB, userID uint, query string, limit int) ([]SearchHit, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []SearchHit{}, nil
+	}
+	switch engine {
+	case searchEngineFTS5:
+		return searchFullText(db, userID, terms, limit)
+	case searchEnginePostgres:
+		return searchPostgres(db, userID, terms, limit)
+	case searchEngineMySQL:
+		return searchMySQL(db, userID, terms, limit)
+	}
+	return searchLike(db, userID, terms, limit)
+}
+
+/*
+searchFullText searches the FTS5 index.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user searching.
+	* `terms` ([]string) The words to search for.
+	* `limit` (int) The maximum number of hits.
+
+- Returns:
+	([]SearchHit) The hits ranked by relevance, or an error if the operation failed.
+*/
+func searchFullText(db *gorm.DB, userID uint, terms []string, limit int) ([]SearchHit, error) {
+	// Every word is quoted so characters with a meaning in the FTS5 query syntax are searched for literally.
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	match := strings.Join(quoted, " ") + "*"
+
+	hits := []SearchHit{}
+	err := db.Raw(`
+		SELECT m.chat_id, c.title AS chat_title, m.id AS message_id, m.message_type, m.created_at,
+			snippet(messages_fts, 0, ?, ?, '…', ?) AS snippet, bm25(messages_fts) AS rank
+		FROM messages_fts
+		JOIN messages m ON m.id = messages_fts.rowid
+		JOIN chats c ON c.id = m.chat_id
+		WHERE messages_fts MATCH ? AND c.user_id = ? AND m.deleted_at IS NULL AND c.deleted_at IS NULL
+		ORDER BY rank
+		LIMIT ?`,
+		highlightStart, highlightEnd, snippetWords, match, userID, limit,
+	).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Snippet = highlight(hits[i].Snippet)
+	}
+	return hits, nil
+}
+
+/*
+searchPostgres searches the PostgreSQL text search index.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user searching.
+	* `terms` ([]string) The words to search for.
+	* `limit` (int) The maximum number of hits.
+
+- Returns:
+	([]SearchHit) The hits ranked by relevance, or an error if the operation failed.
+*/
+func searchPostgres(db *gorm.DB, userID uint, terms []string, limit int) ([]SearchHit, error) {
+	// Every word is quoted so characters with a meaning in the tsquery syntax are searched for literally.
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(term) + "'"
+	}
+	match := strings.Join(quoted, " & ") + ":*"
+	options := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=%d, MinWords=%d, MaxFragments=1`,
+		highlightStart, highlightEnd, snippetWords, snippetWords/2)
+
+	// The rank is negated so that, as with FTS5, lower is more relevant.
+	hits := []SearchHit{}
+	err := db.Raw(`
+		SELECT m.chat_id, c.title AS chat_title, m.id AS message_id, m.message_type, m.created_at,
+			ts_headline('simple', m.message, q.query, ?) AS snippet,
+			-ts_rank(to_tsvector('simple', m.message), q.query) AS rank
+		FROM messages m
+		JOIN chats c ON c.id = m.chat_id
+		CROSS JOIN to_tsquery('simple', ?) AS q(query)
+		WHERE to_tsvector('simple', m.message) @@ q.query
+			AND c.user_id = ? AND m.deleted_at IS NULL AND c.deleted_at IS NULL
+		ORDER BY rank, m.id DESC
+		LIMIT ?`,
+		options, match, userID, limit,
+	).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Snippet = highlight(hits[i].Snippet)
+	}
+	return hits, nil
+}
+
+/*
+searchMySQL searches the MySQL FULLTEXT index.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user searching.
+	* `terms` ([]string) The words to search for.
+	* `limit` (int) The maximum number of hits.
+
+- Returns:
+	([]SearchHit) The hits ranked by relevance, or an error if the operation failed.
+*/
+func searchMySQL(db *gorm.DB, userID uint, terms []string, limit int) ([]SearchHit, error) {
+	// Characters with a meaning in the boolean mode syntax are dropped, since they cannot be escaped.
+	required := []string{}
+	for _, term := range terms {
+		if word := strings.Trim(term, `+-<>()~*"@`); word != "" && !strings.ContainsAny(word, `+-<>()~*"@`) {
+			required = append(required, "+"+word)
+		}
+	}
+	if len(required) == 0 {
+		return searchLike(db, userID, terms, limit)
+	}
+	match := strings.Join(required, " ") + "*"
+
+	// The rank is negated so that, as with FTS5, lower is more relevant.
+	hits := []SearchHit{}
+	// RANK is a reserved word in MySQL, so the column is quoted.
+	err := db.Raw(`
+		SELECT m.chat_id, c.title AS chat_title, m.id AS message_id, m.message_type, m.created_at,
+			m.message AS snippet, -MATCH(m.message) AGAINST (? IN BOOLEAN MODE) AS `+"`rank`"+`
+		FROM messages m
+		JOIN chats c ON c.id = m.chat_id
+		WHERE MATCH(m.message) AGAINST (? IN BOOLEAN MODE)
+			AND c.user_id = ? AND m.deleted_at IS NULL AND c.deleted_at IS NULL
+		ORDER BY `+"`rank`"+`, m.id DESC
+		LIMIT ?`,
+		match, match, userID, limit,
+	).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Snippet = highlight(likeSnippet(hits[i].Snippet, terms))
+	}
+	return hits, nil
+}
+
+/*
+searchLike searches message texts with LIKE, for databases without a full-text index.
+
+Both sides are lowercased because PostgreSQL, and MySQL with a binary collation, compare case sensitively with LIKE.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user searching.
+	* `terms` ([]string) The words to search for.
+	* `limit` (int) The maximum number of hits.
+
+- Returns:
+	([]SearchHit) The hits, newest first, or an error if the operation failed.
+*/
+func searchLike(db *gorm.DB, userID uint, terms []string, limit int) ([]SearchHit, error) {
+	query := db.Table("messages AS m").
+		Select("m.chat_id, c.title AS chat_title, m.id AS message_id, m.message_type, m.created_at, m.message AS snippet").
+		Joins("JOIN chats c ON c.id = m.chat_id").
+		Where("c.user_id = ? AND m.deleted_at IS NULL AND c.deleted_at IS NULL", userID)
+	// The escape character is not a backslash because MySQL treats backslashes in string literals as escapes too.
+	for _, term := range terms {
+		escaped := strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(term)
+		query = query.Where("LOWER(m.message) LIKE LOWER(?) ESCAPE '!'", "%"+escaped+"%")
+	}
+
+	hits := []SearchHit{}
+	if err := query.Order("m.id DESC").Limit(limit).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Snippet = highlight(likeSnippet(hits[i].Snippet, terms))
+	}
+	return hits, nil
+}
+
+/*
+likeSnippet cuts an excerpt around the first match out of a message and marks every match in it.
+
+- Args:
+	* `text` (string) The message.
+	* `terms` ([]string) The words searched for.
+
+- Returns:
+	(string) The excerpt, with matches between highlightStart and highlightEnd.
+*/
+func likeSnippet(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	first := 0
+	if len(lower) == len(text) {
+		first = len(text)
+		for _, term := range terms {
+			if i := strings.Index(lower, strings.ToLower(term)); i >= 0 && i < first {
+				first = i
+			}
+		}
+		if first == len(text) {
+			first = 0
+		}
+	}
+
+	// Cut on rune boundaries around the first match.
+	start := first
+	for count := 0; start > 0 && count < snippetRunes; count++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := first
+	for count := 0; end < len(text) && count < 2*snippetRunes; count++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	excerpt := text[start:end]
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(text) {
+		excerpt += "…"
+	}
+
+	// Lowercasing may change byte lengths, so matches are found on the lowercased excerpt only when it lines up.
+	lowerExcerpt := strings.ToLower(excerpt)
+	if len(lowerExcerpt) != len(excerpt) {
+		return excerpt
+	}
+	lowerTerms := make([]string, len(terms))
+	for i, term := range terms {
+		lowerTerms[i] = strings.ToLower(term)
+	}
+	var marked strings.Builder
+	for i := 0; i < len(excerpt); {
+		matched := 0
+		for _, term := range lowerTerms {
+			if len(term) > matched && strings.HasPrefix(lowerExcerpt[i:], term) {
+				matched = len(term)
+			}
+		}
+		if matched == 0 {
+			marked.WriteByte(excerpt[i])
+			i++
+			continue
+		}
+		marked.WriteString(highlightStart + excerpt[i:i+matched] + highlightEnd)
+		i += matched
+	}
+	return marked.String()
+}
+
+/*
+highlight escapes a snippet for HTML and turns its match markers into <mark> elements.
+
+- Args:
+	* `snippet` (string) The snippet with matches between highlightStart and highlightEnd.
+
+- Returns:
+	(string) The snippet as HTML.
+*/
+func highlight(snippet string) string {
+	return strings.NewReplacer(highlightStart, "<mark>", highlightEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// searchFunc is one of the search implementations behind SearchMessagesForUser.
+type searchFunc func(db *gorm.DB, userID uint, terms []string, limit int) ([]SearchHit, error)
+
+/*
+dialectSearch returns the full-text search of the database in use, or nil if it has none.
+
+- Args:
+	* `db` (*gorm.DB) The database connection, after initSearch.
+	* `backend` (testBackend) The backend.
+
+- Returns:
+	(searchFunc) The search.
+*/
+func dialectSearch(db *gorm.DB, backend testBackend) searchFunc {
+	if !backend.fullText {
+		return nil
+	}
+	switch {
+	case engine == searchEngineFTS5:
+		return searchFullText
+	case db.Dialector.Name() == "postgres":
+		return searchPostgres
+	case db.Dialector.Name() == "mysql":
+		return searchMySQL
+	}
+	return nil
+}
+
+// hitIDs returns the IDs of the messages of search hits, in order.
+func hitIDs(hits []SearchHit) []uint {
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.MessageID
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		alice, chat := addTestChat(t, db, "alice",
+			"The quick brown fox jumps over the lazy dog",
+			"Foxes are quick and clever animals",
+			"Nothing to see here",
+			"A quickly written sentence",
+		)
+		addTestChat(t, db, "bob", "The quick brown fox belongs to bob")
+		path, err := GetActivePath(db, chat.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		searches := map[string]searchFunc{"like": searchLike}
+		if search := dialectSearch(db, backend); search != nil {
+			searches["fulltext"] = search
+		} else {
+			t.Log("full-text search is not available, only LIKE is tested")
+		}
+
+		for name, search := range searches {
+			hits, err := search(db, alice.ID, []string{"brown", "quick"}, 10)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			ids := hitIDs(hits)
+			if len(ids) != 1 || ids[0] != path[0].ID {
+				t.Errorf("%s: hits for `brown quick` = %v, want [%d]", name, ids, path[0].ID)
+			}
+			for _, hit := range hits {
+				if hit.ChatID != chat.ID || hit.ChatTitle != chat.Title {
+					t.Errorf("%s: hit in chat %d %q, want %d %q", name, hit.ChatID, hit.ChatTitle, chat.ID, chat.Title)
+				}
+				if !strings.Contains(hit.Snippet, "<mark>") {
+					t.Errorf("%s: snippet %q has no highlighted match", name, hit.Snippet)
+				}
+			}
+
+			// The last word also matches as a prefix.
+			hits, err = search(db, alice.ID, []string{"quick"}, 10)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if len(hits) != 3 {
+				t.Errorf("%s: hits for `quick` = %v, want 3 messages", name, hitIDs(hits))
+			}
+
+			hits, err = search(db, alice.ID, []string{"quick"}, 2)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if len(hits) != 2 {
+				t.Errorf("%s: %d hits with limit 2", name, len(hits))
+			}
+
+			hits, err = search(db, alice.ID, []string{"giraffe"}, 10)
+			if err != nil || len(hits) != 0 {
+				t.Errorf("%s: hits for `giraffe` = %v, %v, want none", name, hitIDs(hits), err)
+			}
+		}
+
+		if err := DeleteChatForUser(db, chat.ID, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+		for name, search := range searches {
+			hits, err := search(db, alice.ID, []string{"quick"}, 10)
+			if err != nil || len(hits) != 0 {
+				t.Errorf("%s: hits in a deleted chat = %v, %v, want none", name, hitIDs(hits), err)
+			}
+		}
+	})
+}
+
+func TestSearchLikeEscapesWildcards(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		alice, chat := addTestChat(t, db, "alice", "100% done", "1000 items", "snake_case name", "snakeXcase name", "wow!")
+		path, err := GetActivePath(db, chat.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			term string
+			want uint
+		}{
+			{"100%", path[0].ID},
+			{"snake_case", path[2].ID},
+			{"wow!", path[4].ID},
+		}
+		for _, test := range tests {
+			hits, err := searchLike(db, alice.ID, []string{test.term}, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := hitIDs(hits); len(ids) != 1 || ids[0] != test.want {
+				t.Errorf("hits for %q = %v, want [%d]", test.term, ids, test.want)
+			}
+		}
+
+		hits, err := searchLike(db, alice.ID, []string{"DONE"}, 10)
+		if err != nil || len(hits) != 1 {
+			t.Errorf("case-insensitive hits for `DONE` = %v, %v, want one", hitIDs(hits), err)
+		}
+	})
+}
+
+func TestSearchMessagesForUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		alice, _ := addTestChat(t, db, "alice", "searching for treasure")
+
+		hits, err := SearchMessagesForUser(db, alice.ID, "   ", 10)
+		if err != nil || hits == nil || len(hits) != 0 {
+			t.Errorf("hits for a blank query = %v, %v, want an empty list", hits, err)
+		}
+		hits, err = SearchMessagesForUser(db, alice.ID, "treasure", 10)
+		if err != nil || len(hits) != 1 {
+			t.Errorf("hits for `treasure` = %v, %v, want one", hitIDs(hits), err)
+		}
+	})
+}
+
+func TestInitSearchWithoutFTS5(t *testing.T) {
+	if sqliteFTS5Built() {
+		t.Skip("SQLite was built with FTS5")
+	}
+	db := openTestDB(t, sqliteDSN(t))
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine = searchEngineLike })
+
+	if err := initSearch(db, true); !errors.Is(err, ErrNoFTS5) {
+		t.Errorf("initSearch requiring full-text search = %v, want ErrNoFTS5", err)
+	}
+	if err := initSearch(db, false); err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := addTestChat(t, db, "alice", "written without FTS5")
+	hits, err := SearchMessagesForUser(db, alice.ID, "without", 10)
+	if err != nil || len(hits) != 1 {
+		t.Errorf("hits for `without` = %v, %v, want one", hitIDs(hits), err)
+	}
+}
+
+func TestSearchMySQLOperatorsOnly(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB, backend testBackend) {
+		if db.Dialector.Name() != "mysql" {
+			t.Skip("MySQL only")
+		}
+		// Terms made only of boolean mode operators cannot be matched, so the LIKE search is used.
+		alice, _ := addTestChat(t, db, "alice", "a + b", "c")
+		hits, err := searchMySQL(db, alice.ID, []string{"+"}, 10)
+		if err != nil || len(hits) != 1 {
+			t.Errorf("hits for `+` = %v, %v, want one", hitIDs(hits), err)
+		}
+	})
+}
+
+func TestLikeSnippet(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 20) + "needle" + strings.Repeat(" dolor sit", 30)
+	snippet := highlight(likeSnippet(long, []string{"needle"}))
+	if !strings.Contains(snippet, "<mark>needle</mark>") {
+		t.Errorf("snippet %q does not highlight the match", snippet)
+	}
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("snippet %q is not cut around the match", snippet)
+	}
+
+	if snippet := highlight(likeSnippet("<b>Tom & Jerry</b>", []string{"jerry"})); snippet != "&lt;b&gt;Tom &amp; <mark>Jerry</mark>&lt;/b&gt;" {
+		t.Errorf("snippet = %q, want escaped HTML", snippet)
+	}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+// settingsColumns are the columns of models.GenerationSettings, which Select lists so that cleared settings are saved
+// as NULL or empty instead of being skipped as zero values.
+var settingsColumns = []string{"system_prompt", "model", "temperature", "top_p", "max_tokens"}
+
+/*
+UpdateChatSettingsForUser replaces the generation settings of a chat.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user changing the settings.
+	* `settings` (models.GenerationSettings) The new settings.
+
+- Returns:
+	(*models.Chat) The updated chat without messages, ErrChatNotFound if it does not exist, ErrChatForbidden if it
+	belongs to another user, or another error if the operation failed.
+*/
+func UpdateChatSettingsForUser(db *gorm.DB, chatID, userID uint, settings models.GenerationSettings) (*models.Chat, error) {
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(chat).Select(settingsColumns).Updates(&models.Chat{Settings: settings}).Error; err != nil {
+		return nil, err
+	}
+	chat.Settings = settings
+	return chat, nil
+}
+
+/*
+UpdateUserDefaultSettings replaces the generation settings new chats of a user start with.
+
+Existing chats keep their settings.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+	* `settings` (models.GenerationSettings) The new defaults.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func UpdateUserDefaultSettings(db *gorm.DB, userID uint, settings models.GenerationSettings) error {
+	columns := make([]string, len(settingsColumns))
+	for i, column := range settingsColumns {
+		columns[i] = "default_" + column
+	}
+	return db.Model(&models.User{}).Where("id = ?", userID).Select(columns).
+		Updates(&models.User{DefaultSettings: settings}).Error
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"errors"
+	"slices"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+/*
+GetChatSummary retrieves the summary that covers the most of a branch of a chat.
+
+Summaries written on other branches are ignored, since they cover messages the branch does not contain.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `path` ([]models.Message) The messages of the branch in chronological order.
+
+- Returns:
+	(*models.ChatSummary) The summary, nil if no summary covers a message of the branch, or an error if the operation
+	failed.
+*/
+func GetChatSummary(db *gorm.DB, chatID uint, path []models.Message) (*models.ChatSummary, error) {
+	messageIDs := make([]uint, len(path))
+	for index, message := range path {
+		messageIDs[index] = message.ID
+	}
+	return branchSummary(db, chatID, messageIDs)
+}
+
+/*
+branchSummary retrieves the summary that covers the most of a branch of a chat, given the IDs of its messages.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `messageIDs` ([]uint) The IDs of the messages of the branch in chronological order.
+
+- Returns:
+	(*models.ChatSummary) The summary, nil if there is none, or an error if the operation failed.
+*/
+func branchSummary(db *gorm.DB, chatID uint, messageIDs []uint) (*models.ChatSummary, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	// Replies are always created after the message they follow, so later messages of a branch have higher IDs.
+	var summary models.ChatSummary
+	err := db.Where("chat_id = ? AND through_message_id IN ?", chatID, messageIDs).
+		Order("through_message_id DESC, id DESC").
+		First(&summary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+/*
+ContextPath is what the next reply of a chat is generated from.
+
+- Fields:
+	* `Summary` (*models.ChatSummary) The summary of the start of the active branch, or nil if there is none.
+	* `Summarized` (int) The number of messages the summary covers.
+	* `Messages` ([]models.Message) The messages of the active branch after the summary, in chronological order.
+*/
+type ContextPath struct {
+	Summary    *models.ChatSummary
+	Summarized int
+	Messages   []models.Message
+}
+
+/*
+GetContextPath retrieves the summary of the active branch of a chat and the messages after it.
+
+Only the messages after the summary are loaded in full.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+
+- Returns:
+	(*ContextPath) The summary and the messages, ErrChatNotFound if the chat does not exist, or another error if the
+	operation failed.
+*/
+func GetContextPath(db *gorm.DB, chatID uint) (*ContextPath, error) {
+	var chat models.Chat
+	if err := db.First(&chat, chatID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChatNotFound
+		}
+		return nil, err
+	}
+
+	ids, err := activePathIDs(db, &chat)
+	if err != nil {
+		return nil, err
+	}
+	context := &ContextPath{}
+	if context.Summary, err = branchSummary(db, chat.ID, ids); err != nil {
+		return nil, err
+	}
+	if context.Summary != nil {
+		context.Summarized = slices.Index(ids, context.Summary.ThroughMessageID) + 1
+	}
+	if context.Messages, err = loadMessages(db, ids[context.Summarized:]); err != nil {
+		return nil, err
+	}
+	return context, nil
+}
+
+/*
+AddChatSummary saves a new summary of a chat.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `summary` (*models.ChatSummary) The summary to save; its ID is set on success.
+
+- Returns:
+	(error) An error if the operation failed.
+*/
+func AddChatSummary(db *gorm.DB, summary *models.ChatSummary) error {
+	return db.Create(summary).Error
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTagLength is the maximum number of characters in a tag.
+	maxTagLength = 32
+	// maxChatTags is the maximum number of tags on a chat.
+	maxChatTags = 20
+)
+
+// ErrInvalidTags is returned when tags are too long or a chat is given too many of them.
+var ErrInvalidTags = errors.New("tags must have at most 32 characters and a chat at most 20 tags")
+
+/*
+TagCount is a tag together with the number of chats that have it.
+
+- Fields:
+	* `Name` (string) The tag.
+	* `Chats` (int) The number of chats with the tag.
+*/
+type TagCount struct {
+	Name  string `json:"name"`
+	Chats int    `json:"chats"`
+}
+
+/*
+NormalizeTag brings a tag into the form it is stored in: trimmed, with inner whitespace collapsed and in lower case.
+
+- Args:
+	* `tag` (string) The tag as entered.
+
+- Returns:
+	(string) The normalized tag, empty if the tag was blank.
+*/
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+/*
+normalizeTags normalizes tags and removes blank and duplicate ones, keeping their order.
+
+- Args:
+	* `tags` ([]string) The tags as entered.
+
+- Returns:
+	([]string) The normalized tags, or ErrInvalidTags if a tag is too long or there are too many.
+*/
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, ErrInvalidTags
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxChatTags {
+		return nil, ErrInvalidTags
+	}
+	return normalized, nil
+}
+
+/*
+SetChatTagsForUser replaces the tags of a chat if it belongs to the given user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user tagging the chat.
+	* `tags` ([]string) The new tags; they are normalized, and blank and duplicate tags are dropped.
+
+- Returns:
+	(*models.Chat) The chat with its new tags, ErrChatNotFound if it does not exist, ErrChatForbidden if it belongs to
+	another user, ErrInvalidTags if the tags are not acceptable, or another error if the operation failed.
+*/
+func SetChatTagsForUser(db *gorm.DB, chatID, userID uint, tags []string) (*models.Chat, error) {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	chat, err := authorizeChat(db, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatTags := make([]models.ChatTag, len(normalized))
+	for i, tag := range normalized {
+		chatTags[i] = models.ChatTag{ChatID: chat.ID, Name: tag}
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_id = ?", chat.ID).Delete(&models.ChatTag{}).Error; err != nil {
+			return err
+		}
+		if len(chatTags) == 0 {
+			return nil
+		}
+		return tx.Create(&chatTags).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chat.Tags = chatTags
+	slices.SortFunc(chat.Tags, func(a, b models.ChatTag) int { return strings.Compare(a.Name, b.Name) })
+	return chat, nil
+}
+
+/*
+GetTagsForUser retrieves the tags on the chats of a user that are not in the trash, ordered by name.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	([]TagCount) The tags with the number of chats that have them, or an error if the operation failed.
+*/
+func GetTagsForUser(db *gorm.DB, userID uint) ([]TagCount, error) {
+	var tags []TagCount
+	err := db.Model(&models.ChatTag{}).
+		Select("chat_tags.name AS name, COUNT(*) AS chats").
+		Joins("JOIN chats ON chats.id = chat_tags.chat_id").
+		Where("chats.user_id = ? AND chats.deleted_at IS NULL", userID).
+		Group("chat_tags.name").
+		Order("chat_tags.name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+/*
+orderTags orders preloaded tags by name.
+
+- Args:
+	* `db` (*gorm.DB) The query loading the tags.
+
+- Returns:
+	(*gorm.DB) The ordered query.
+*/
+func orderTags(db *gorm.DB) *gorm.DB {
+	return db.Order("name")
+}
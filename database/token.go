@@ -0,0 +1,123 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+// apiTokenPrefix marks gochat API tokens so they are easy to recognise in configuration files and logs.
+const apiTokenPrefix = "gct_"
+
+/*
+CreateAPIToken creates a new API token for a user.
+
+Only a SHA-256 hash of the token is stored, so the plaintext token is returned once and cannot be recovered later.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user the token authenticates.
+	* `name` (string) A label that helps the user recognise the token.
+
+- Returns:
+	* `string` The plaintext token.
+	* `*models.APIToken` The stored token.
+	* `error` An error if the operation failed.
+*/
+func CreateAPIToken(db *gorm.DB, userID uint, name string) (string, *models.APIToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	plaintext := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	token := &models.APIToken{UserID: userID, Name: name, TokenHash: hashAPIToken(plaintext)}
+	if err := db.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return plaintext, token, nil
+}
+
+/*
+GetAPITokensForUser retrieves the API tokens of a user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	([]models.APIToken) The user's tokens, newest first, or an error if the operation failed.
+*/
+func GetAPITokensForUser(db *gorm.DB, userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	result := db.Where("user_id = ?", userID).Order("id DESC").Find(&tokens)
+	return tokens, result.Error
+}
+
+/*
+DeleteAPIToken revokes one of a user's API tokens.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user owning the token.
+	* `tokenID` (uint) The ID of the token.
+
+- Returns:
+	(error) An error if the token does not exist for the user or the operation failed.
+*/
+func DeleteAPIToken(db *gorm.DB, userID, tokenID uint) error {
+	result := db.Where("user_id = ?", userID).Delete(&models.APIToken{}, tokenID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("token not found")
+	}
+	return nil
+}
+
+/*
+GetUserByAPIToken resolves the user a plaintext API token belongs to and records when the token was used.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `plaintext` (string) The token presented by the client.
+
+- Returns:
+	(*models.User) The user, or an error if the token is unknown.
+*/
+func GetUserByAPIToken(db *gorm.DB, plaintext string) (*models.User, error) {
+	var token models.APIToken
+	if err := db.Where("token_hash = ?", hashAPIToken(plaintext)).First(&token).Error; err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	user, err := GetUser(db, token.UserID)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	db.Model(&token).UpdateColumn("last_used_at", time.Now())
+	return user, nil
+}
+
+/*
+hashAPIToken returns the hex encoded SHA-256 hash under which a token is stored.
+
+- Args:
+	* `plaintext` (string) The plaintext token.
+
+- Returns:
+	(string) The token hash.
+*/
+func hashAPIToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gochat/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrChatNotInTrash is returned when a chat that has not been deleted is restored.
+var ErrChatNotInTrash = errors.New("chat is not in the trash")
+
+// purgeBatchSize is the number of chats purged per transaction, so a large trash does not lock the database for long.
+const purgeBatchSize = 100
+
+/*
+GetTrashedChatsForUser retrieves the deleted chats of a user, most recently deleted first, without their messages.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	([]models.Chat) The deleted chats, or an error if the operation failed.
+*/
+func GetTrashedChatsForUser(db *gorm.DB, userID uint) ([]models.Chat, error) {
+	var chats []models.Chat
+	err := db.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC, id DESC").
+		Find(&chats).Error
+	return chats, err
+}
+
+/*
+RestoreChatForUser moves a deleted chat of a user out of the trash.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the user restoring the chat.
+
+- Returns:
+	(*models.Chat) The restored chat with its tags but without its messages, ErrChatNotFound if it does not exist or
+	was purged, ErrChatForbidden if it belongs to another user, ErrChatNotInTrash if it was not deleted, or another
+	error if the operation failed.
+*/
+func RestoreChatForUser(db *gorm.DB, chatID, userID uint) (*models.Chat, error) {
+	var chat models.Chat
+	if err := db.Unscoped().Preload("Tags", orderTags).First(&chat, chatID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChatNotFound
+		}
+		return nil, err
+	}
+	if chat.UserID != userID {
+		return nil, ErrChatForbidden
+	}
+	if !chat.DeletedAt.Valid {
+		return nil, ErrChatNotInTrash
+	}
+
+	if err := db.Unscoped().Model(&chat).Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	chat.DeletedAt = gorm.DeletedAt{}
+	return &chat, nil
+}
+
+/*
+EmptyTrashForUser permanently deletes the deleted chats of a user together with their messages, summaries, tags and
+attachments.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user.
+
+- Returns:
+	(int) The number of chats deleted, or an error if the operation failed.
+*/
+func EmptyTrashForUser(db *gorm.DB, userID uint) (int, error) {
+	return purgeTrashedChats(db, "user_id = ?", userID)
+}
+
+/*
+PurgeTrash permanently deletes the chats of every user that were deleted before a point in time, together with their
+messages, summaries, tags and attachments.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `before` (time.Time) Chats deleted before this time are purged.
+
+- Returns:
+	(int) The number of chats deleted, or an error if the operation failed.
+*/
+func PurgeTrash(db *gorm.DB, before time.Time) (int, error) {
+	return purgeTrashedChats(db, "deleted_at < ?", before)
+}
+
+/*
+purgeTrashedChats permanently deletes the deleted chats that match a condition, in batches of purgeBatchSize.
+
+Messages, summaries and tags are deleted explicitly, since the cascade of the chats' foreign key is not enforced by SQLite.
+Attachments are only soft-deleted; they are deleted once attachment.RemovePurged has removed their files.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `condition` (string) The SQL condition the chats have to match.
+	* `args` (...interface{}) The arguments of the condition.
+
+- Returns:
+	(int) The number of chats deleted, or an error if the operation failed. Batches deleted before the error stay
+	deleted.
+*/
+func purgeTrashedChats(db *gorm.DB, condition string, args ...interface{}) (int, error) {
+	purged := 0
+	for {
+		var chatIDs []uint
+		err := db.Unscoped().Model(&models.Chat{}).
+			Where("deleted_at IS NOT NULL").
+			Where(condition, args...).
+			Order("id").
+			Limit(purgeBatchSize).
+			Pluck("id", &chatIDs).Error
+		if err != nil || len(chatIDs) == 0 {
+			return purged, err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("chat_id IN ?", chatIDs).Delete(&models.ChatSummary{}).Error; err != nil {
+				return err
+			}
+			messageIDs := tx.Unscoped().Model(&models.Message{}).Select("id").Where("chat_id IN ?", chatIDs)
+			if err := tx.Where("message_id IN (?)", messageIDs).Delete(&models.Attachment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("chat_id IN ?", chatIDs).Delete(&models.Message{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("chat_id IN ?", chatIDs).Delete(&models.ChatTag{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&models.Chat{}, chatIDs).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += len(chatIDs)
+		if len(chatIDs) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
@@ -1,22 +1,26 @@
 package database
 
 import (
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"log"
 
 	"gochat/models"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 /*
-AddChat adds a new chat to the database.
+RegisterUser adds a new user to the database with a hashed password.
 
 - Args:
 	* `db` (*gorm.DB) The database connection.
-	* `chat` (*models.Chat) The chat to add.
+	* `user` (*models.User) The user to add, with the plaintext password.
 
 - Returns:
-	(error) An error if the operation failed.
+	(error) An error if the user already exists or the operation failed.
 */
 func RegisterUser(db *gorm.DB, user *models.User) error {
 	var existingUser models.User
@@ -24,8 +28,11 @@ func RegisterUser(db *gorm.DB, user *models.User) error {
 		return errors.New("user already exists")
 	}
 
-	// Hash the password before saving (simplified for demonstration)
-	user.Password = hashPassword(user.Password)
+	hashed, err := hashPassword(user.Password)
+	if err != nil {
+		return errors.New("failed to register user")
+	}
+	user.Password = hashed
 
 	if err := db.Create(user).Error; err != nil {
 		return errors.New("failed to register user")
@@ -37,6 +44,8 @@ func RegisterUser(db *gorm.DB, user *models.User) error {
 /*
 LoginUser checks the user credentials and logs the user in.
 
+If the stored hash uses the legacy scheme or an outdated cost, it is replaced with a fresh hash of the password.
+
 - Args:
 	* `db` (*gorm.DB) The database connection.
 	* `user` (*models.User) The user to log in.
@@ -50,45 +59,160 @@ func LoginUser(db *gorm.DB, user *models.User) error {
         return errors.New("invalid credentials")
     }
 
-    if !checkPassword(user.Password, existingUser.Password) {
+    matches, needsRehash := checkPassword(user.Password, existingUser.Password)
+    if !matches {
         return errors.New("invalid credentials")
     }
 
+    if needsRehash {
+        if hashed, err := hashPassword(user.Password); err == nil {
+            if err := db.Model(&existingUser).Update("password", hashed).Error; err != nil {
+                log.Printf("Failed to rehash password for user %s: %v", existingUser.Username, err)
+            }
+        }
+    }
+
     user.ID = existingUser.ID
     user.Username = existingUser.Username
+    user.IsAdmin = existingUser.IsAdmin
 
     return nil
 }
 
 /*
-hashPassword hashes the given password.
+GetUser retrieves a user by ID from the database.
 
-This is a simplified implementation for demonstration purposes and will need to use a secure hashing algorithm in a 
-real application.
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the user to retrieve.
+
+- Returns:
+	(*models.User) The user if found, or an error if the operation failed.
+*/
+func GetUser(db *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+/*
+GetUserByUsername retrieves a user by username from the database.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `username` (string) The username of the user to retrieve.
+
+- Returns:
+	(*models.User) The user if found, or an error if the operation failed.
+*/
+func GetUserByUsername(db *gorm.DB, username string) (*models.User, error) {
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+/*
+CreateAdmin creates an administrator account, or promotes and resets the password of an existing user.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `username` (string) The username of the administrator.
+	* `password` (string) The plaintext password.
+
+- Returns:
+	(*models.User) The administrator, or an error if the operation failed.
+*/
+func CreateAdmin(db *gorm.DB, username, password string) (*models.User, error) {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		user = models.User{Username: username}
+	}
+	user.Password = hashed
+	user.IsAdmin = true
+
+	if err := db.Save(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+/*
+SetPasswordCost sets the bcrypt cost used for new password hashes.
+
+Existing hashes with a different cost are rehashed the next time their user logs in.
+
+- Args:
+	* `cost` (int) The bcrypt cost, between bcrypt.MinCost and bcrypt.MaxCost.
+
+- Returns:
+	(error) An error if the cost is out of range.
+*/
+func SetPasswordCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	passwordCost = cost
+	return nil
+}
+
+// passwordCost is the bcrypt cost used by hashPassword.
+var passwordCost = bcrypt.DefaultCost
+
+// legacyPasswordSuffix is appended to plaintext passwords by the hashing scheme used before bcrypt.
+const legacyPasswordSuffix = "hashed"
+
+/*
+hashPassword hashes the given password with bcrypt, which salts every hash individually.
 
 - Args:
 	* `password` (string) The password to hash.
 
 - Returns:
-	(string) The hashed password.
+	(string) The hashed password, or an error if hashing failed.
 */
-func hashPassword(password string) string {
-	return password + "hashed"
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
 }
 
 /*
-checkPassword compares a password with a hashed password.
+checkPassword compares a password with a stored password hash.
 
-This is a simplified implementation for demonstration purposes and will need to use a secure hashing algorithm in a
-real application.
+Hashes created before bcrypt was introduced are still accepted so their users can log in once, and are reported as
+needing a rehash along with bcrypt hashes created with a different cost.
 
 - Args:
 	* `password` (string) The password to check.
-	* `hashedPassword` (string) The hashed password to compare against.
+	* `hashedPassword` (string) The stored hash to compare against.
 
 - Returns:
-	(bool) True if the password matches the hashed password, false otherwise.
+	* `bool` True if the password matches the hash.
+	* `bool` True if the hash should be replaced with one created by hashPassword.
 */
-func checkPassword(password, hashedPassword string) bool {
-	return hashPassword(password) == hashedPassword
-}
\ No newline at end of file
+func checkPassword(password, hashedPassword string) (bool, bool) {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		legacy := []byte(password + legacyPasswordSuffix)
+		return subtle.ConstantTimeCompare(legacy, []byte(hashedPassword)) == 1, true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) != nil {
+		return false, false
+	}
+	return true, cost != passwordCost
+}
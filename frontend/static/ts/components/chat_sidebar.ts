@@ -0,0 +1,64 @@
+declare const htmx: any;
+
+// Sidebar section a chat is dragged over, highlighted as the drop target.
+let dropSection: HTMLElement | null = null;
+
+function sectionAt(target: EventTarget | null): HTMLElement | null {
+	return target instanceof Element ? (target.closest('.chat-section') as HTMLElement | null) : null;
+}
+
+function highlightDropSection(section: HTMLElement | null): void {
+	if (dropSection === section) {
+		return;
+	}
+	dropSection?.classList.remove('drop-target');
+	section?.classList.add('drop-target');
+	dropSection = section;
+}
+
+// Event delegation, so chats and sections swapped in later can be dragged and dropped too.
+document.addEventListener('dragstart', function (event: DragEvent) {
+	const item = event.target instanceof Element ? event.target.closest('.chat-list-item') : null;
+	if (!(item instanceof HTMLElement) || !event.dataTransfer) {
+		return;
+	}
+	event.dataTransfer.setData('application/x-gochat-chat', item.dataset.chatId ?? '');
+	event.dataTransfer.effectAllowed = 'move';
+});
+
+document.addEventListener('dragover', function (event: DragEvent) {
+	const section = sectionAt(event.target);
+	if (!section || !event.dataTransfer?.types.includes('application/x-gochat-chat')) {
+		highlightDropSection(null);
+		return;
+	}
+	event.preventDefault();
+	event.dataTransfer.dropEffect = 'move';
+	highlightDropSection(section);
+});
+
+document.addEventListener('dragend', function () {
+	highlightDropSection(null);
+});
+
+document.addEventListener('drop', function (event: DragEvent) {
+	const section = sectionAt(event.target);
+	const chatId = event.dataTransfer?.getData('application/x-gochat-chat');
+	highlightDropSection(null);
+	if (!section || !chatId) {
+		return;
+	}
+	event.preventDefault();
+
+	// Dropping a chat on the section it is already in changes nothing.
+	if (section.querySelector(`.chat-list-item[data-chat-id="${chatId}"]`)) {
+		return;
+	}
+	const tag = document.getElementById('chat-filter-tag') as HTMLInputElement | null;
+	// The response swaps the sections the chat left and joined out of band.
+	htmx.ajax('PUT', `/chat/${chatId}/folder`, {
+		source: section,
+		swap: 'none',
+		values: { folder_id: section.dataset.folderId ?? '', tag: tag?.value ?? '' },
+	});
+});
@@ -31,6 +31,19 @@ document.addEventListener('input', function (event: Event) {
 	}
 });
 
+// Show the names of the files picked for the next message
+document.addEventListener('change', function (event: Event) {
+	const target = event.target as HTMLInputElement;
+	if (target && target.id === 'attachment-input') {
+		const names = Array.from(target.files || []).map((file) => file.name);
+		const label = target.form?.querySelector('.attachment-names');
+		if (label) {
+			label.textContent = names.join(', ');
+			label.setAttribute('title', names.join('\n'));
+		}
+	}
+});
+
 document.addEventListener('keydown', function (event: KeyboardEvent) {
 	const target = event.target as HTMLElement;
 	if (target && target.id === 'message-input') {
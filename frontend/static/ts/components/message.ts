@@ -3,8 +3,45 @@ function scrollToBottom(element: HTMLElement): void {
 	element.scrollTop = element.scrollHeight;
 }
 
+// Scrolls to the message a search result points to, if any.
+function scrollToFocusedMessage(): boolean {
+	const focus = document.getElementById('focus-message-id') as HTMLInputElement | null;
+	const message = focus && document.getElementById('message-' + focus.value);
+	if (!focus || !message) {
+		return false;
+	}
+	focus.remove();
+	message.classList.add('search-focus');
+	message.scrollIntoView({ block: 'center' });
+	return true;
+}
+
+// Distance of the view from the end of the chat while a page of older messages is inserted above it.
+let olderPageOffset: number | null = null;
+
+document.addEventListener('htmx:beforeSwap', function (event: Event) {
+	const target = (event as CustomEvent).detail.target;
+	const messagesDiv = document.getElementById('messages');
+	olderPageOffset = null;
+	if (target instanceof HTMLElement && target.classList.contains('load-older') && messagesDiv) {
+		olderPageOffset = messagesDiv.scrollHeight - messagesDiv.scrollTop;
+	}
+});
+
 document.addEventListener('htmx:afterSettle', function (event: Event) {
+	// Further pages of the chat list leave the messages alone.
+	if (event.target instanceof Element && event.target.closest('#chat-list')) {
+		return;
+	}
+	if (scrollToFocusedMessage()) {
+		return;
+	}
 	const messagesDiv = document.getElementById('messages');
+	// Older messages keep the messages that were shown in place.
+	if (olderPageOffset !== null && messagesDiv instanceof HTMLElement) {
+		messagesDiv.scrollTop = messagesDiv.scrollHeight - olderPageOffset;
+		return;
+	}
 	console.log('Message Div!');
 	if (messagesDiv instanceof HTMLElement) {
 		scrollToBottom(messagesDiv);
@@ -1,34 +1,78 @@
 package main
 
 import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gochat/ai"
+	"gochat/attachment"
 	"gochat/database"
 	"gochat/routes"
+	"gochat/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-    db := database.InitDB("test.db")
+    if len(os.Args) > 1 {
+        switch os.Args[1] {
+        case "create-admin":
+            runCreateAdmin(os.Args[2:])
+            return
+        case "import":
+            runImport(os.Args[2:])
+            return
+        case "openapi":
+            runOpenAPI(os.Args[2:])
+            return
+        case "migrate":
+            runMigrate(os.Args[2:])
+            return
+        }
+    }
 
-    router := routes.SetupRouter(db)
+    cfg := loadConfig(flag.NewFlagSet("gochat", flag.ExitOnError), os.Args[1:])
+    if err := cfg.ValidateFiles(); err != nil {
+        log.Fatalf("invalid configuration:\n%v", err)
+    }
+    if err := cfg.Print(os.Stderr); err != nil {
+        log.Printf("Failed to print configuration: %v", err)
+    }
 
-    // Load HTML templates
-    router.LoadHTMLGlob("frontend/templates/**/*")
+    db := database.InitDB(cfg.Database.DSN, cfg.Database.Search == "fulltext")
+
+    provider, err := ai.NewProvider(cfg.ProviderOptions())
+    if err != nil {
+        log.Fatalf("Failed to configure AI provider: %v", err)
+    }
 
-    // // Serve static files
-    // router.Static("/static", "./frontend/static")
+    backend, err := storage.New(cfg.StorageOptions())
+    if err != nil {
+        log.Fatalf("Failed to configure attachment storage: %v", err)
+    }
+    files := attachment.NewStore(backend, cfg.AttachmentLimits())
+
+    router := routes.SetupRouter(db, provider, files, cfg)
+    warnUndocumentedRoutes(router)
+
+    // Load HTML templates
+    router.LoadHTMLGlob(filepath.Join(cfg.Paths.Templates, "**", "*"))
 
     // Serve static files
-    router.Static("/static", "./frontend/static") // Serve CSS and other static files
-    router.Static("/dist", "./frontend/dist")     // Serve JS files
+    router.Static("/static", cfg.Paths.Static) // Serve CSS and other static files
+    router.Static("/dist", cfg.Paths.Dist)     // Serve JS files
 
     // Serve index.html as the main entry point
-    router.GET("/", func(context *gin.Context) {
+    router.GET("/", routes.RequireAuth(db), func(context *gin.Context) {
         context.HTML(200, "index", gin.H{
             "title": "GoChat",
         })
     })
 
-    // Start the server on port 8080
-    router.Run(":8080")
+    // Start the server on the configured address and shut it down gracefully on SIGINT or SIGTERM
+    if err := runServer(cfg, router, db, files); err != nil {
+        log.Fatalf("Server stopped: %v", err)
+    }
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -11,25 +13,109 @@ const (
 	AIMessageType   MessageType = "AI"
 )
 
+// GenerationSettings are the parameters AI replies are generated with.
+// Empty fields leave the choice to the provider, which uses the model it was configured with and its own defaults.
+type GenerationSettings struct {
+	SystemPrompt string   `json:"system_prompt"`
+	Model        string   `json:"model"`
+	Temperature  *float64 `json:"temperature"`
+	TopP         *float64 `json:"top_p"`
+	MaxTokens    *int     `json:"max_tokens"`
+}
+
 // User represents a user in the database
+// DefaultSettings are copied into the chats the user creates.
 type User struct {
 	gorm.Model
-	Username string `json:"username" gorm:"unique"`
-	Password string `json:"password"`
+	Username        string             `json:"username" gorm:"unique"`
+	Password        string             `json:"-"`
+	IsAdmin         bool               `json:"is_admin"`
+	DefaultSettings GenerationSettings `json:"default_settings" gorm:"embedded;embeddedPrefix:default_"`
+}
+
+// APIToken represents a token that authenticates API requests on behalf of a user
+type APIToken struct {
+	gorm.Model
+	UserID     uint       `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-" gorm:"size:64;uniqueIndex"`
+	LastUsedAt *time.Time `json:"last_used_at"`
 }
 
-// Chat represents a chat between users
+// Chat represents a chat between users.
+// Its messages form a tree; ActiveMessageID is the last message of the branch that is shown.
+// Settings are passed to the AI provider whenever a reply is generated in the chat.
+// FolderID is the folder the chat is filed in, nil if it is in none; pinned chats are listed first.
 type Chat struct {
 	gorm.Model
-	UserID   uint      `json:"user_id"`
-	Messages []Message `json:"messages" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID          uint               `json:"user_id"`
+	Title           string             `json:"title"`
+	ActiveMessageID *uint              `json:"active_message_id"`
+	FolderID        *uint              `json:"folder_id" gorm:"index"`
+	Pinned          bool               `json:"pinned" gorm:"not null;default:false"`
+	Settings        GenerationSettings `json:"settings" gorm:"embedded"`
+	Messages        []Message          `json:"messages" gorm:"constraint:OnDelete:CASCADE;"`
+	Tags            []ChatTag          `json:"tags" gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// Folder represents a user-defined group of chats in the sidebar.
+type Folder struct {
+	gorm.Model
+	UserID uint   `json:"user_id" gorm:"index"`
+	Name   string `json:"name"`
+}
+
+// ChatTag represents a free-form label on a chat.
+// Names are stored trimmed and in lower case, so a chat has each tag at most once regardless of spelling.
+type ChatTag struct {
+	ID     uint   `json:"-" gorm:"primaryKey"`
+	ChatID uint   `json:"-" gorm:"uniqueIndex:idx_chat_tags_chat_name"`
+	Name   string `json:"name" gorm:"size:32;uniqueIndex:idx_chat_tags_chat_name;index"`
 }
 
-// Message represents a message in a chat
+// Message represents a message in a chat.
+// ParentID is the message it follows; messages that share a parent are alternative branches of the conversation.
 type Message struct {
 	gorm.Model
-	ChatID      uint       `json:"chat_id"`
-	UserID      uint       `json:"user_id"`
-	Message     string     `json:"message"`
-	MessageType MessageType `json:"message_type"`
-}
\ No newline at end of file
+	ChatID      uint         `json:"chat_id"`
+	ParentID    *uint        `json:"parent_id" gorm:"index"`
+	UserID      uint         `json:"user_id"`
+	Message     string       `json:"message"`
+	MessageType MessageType  `json:"message_type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment represents a file attached to a message.
+// The file is kept by the attachment storage under StorageKey, and the thumbnail of an image under ThumbnailKey.
+// Text holds the content of a text file, which is sent to the AI provider with the message.
+// The attachments of purged chats stay soft-deleted until their files have been removed from the storage.
+type Attachment struct {
+	gorm.Model
+	MessageID    uint   `json:"message_id" gorm:"index"`
+	Filename     string `json:"filename"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	Text         string `json:"-"`
+}
+// PromptTemplate represents a reusable prompt in a user's library, written in Go text/template syntax.
+// Shared templates can be used by every user but only changed by their owner.
+type PromptTemplate struct {
+	gorm.Model
+	UserID      uint   `json:"user_id" gorm:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Body        string `json:"body"`
+	Shared      bool   `json:"shared" gorm:"index"`
+	User        User   `json:"-"`
+}
+
+// ChatSummary is a rolling summary of a chat's messages up to and including ThroughMessageID.
+// It is sent to the AI provider in place of those messages once the conversation no longer fits the context window.
+type ChatSummary struct {
+	gorm.Model
+	ChatID           uint   `json:"chat_id" gorm:"index"`
+	ThroughMessageID uint   `json:"through_message_id"`
+	Content          string `json:"content"`
+}
@@ -0,0 +1,313 @@
+// Package prompt expands prompt templates, the reusable preambles and personas users keep in their library.
+//
+// Templates use Go text/template syntax. Their variables are the fields of the data they are executed with, so the
+// template "Review this {{ .language }} code" has the variable "language". Because templates are shared between
+// users, only the parts of the syntax that run in time proportional to the template are allowed: loops, nested
+// templates and printf are rejected.
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	// maxOutput bounds the size of an expanded template, so a template cannot expand into an enormous prompt.
+	maxOutput = 256 * 1024
+	// maxRenderTime bounds how long expanding a template may take.
+	maxRenderTime = time.Second
+)
+
+var (
+	// ErrTooLong is returned when an expanded template exceeds the size limit.
+	ErrTooLong = errors.New("the expanded template is too long")
+	// ErrTimeout is returned when expanding a template takes longer than maxRenderTime.
+	ErrTimeout = errors.New("expanding the template took too long")
+)
+
+// allowedFuncs are the built-in template functions templates may call. printf is left out because a width such as
+// %0999999999d allocates its output before the size limit can stop it.
+var allowedFuncs = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"len": true, "index": true, "slice": true,
+	"print": true, "println": true, "html": true, "js": true, "urlquery": true,
+}
+
+/*
+Parse checks a template and returns its variables.
+
+- Args:
+	* `body` (string) The template text.
+
+- Returns:
+	* `[]string` The names of the variables in the order they first appear.
+	* `error` An error describing the syntax error, if the template cannot be parsed.
+*/
+func Parse(body string) ([]string, error) {
+	tmpl, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []string
+	if tmpl.Tree != nil {
+		collectFields(tmpl.Tree.Root, &variables)
+	}
+	return variables, nil
+}
+
+/*
+Render expands a template with the given variable values.
+
+Variables without a value expand to an empty string. Templates saved before loops were rejected are checked again,
+and expanding is stopped after maxRenderTime.
+
+- Args:
+	* `body` (string) The template text.
+	* `values` (map[string]string) The values of the variables.
+
+- Returns:
+	* `string` The expanded text with surrounding whitespace removed.
+	* `error` An error if the template cannot be parsed or executed, ErrTooLong or ErrTimeout.
+*/
+func Render(body string, values map[string]string) (string, error) {
+	tmpl, err := parseTemplate(body)
+	if err != nil {
+		return "", err
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+
+	// The output is only read once Execute has returned; after a timeout it is abandoned, and the next write
+	// stops the execution.
+	output := &limitedBuffer{limit: maxOutput, deadline: time.Now().Add(maxRenderTime)}
+	done := make(chan error, 1)
+	go func() { done <- tmpl.Execute(output, values) }()
+
+	timer := time.NewTimer(maxRenderTime)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		switch {
+		case errors.Is(err, ErrTooLong):
+			return "", ErrTooLong
+		case errors.Is(err, ErrTimeout):
+			return "", ErrTimeout
+		case err != nil:
+			return "", cleanError(err)
+		}
+		return strings.TrimSpace(output.String()), nil
+	case <-timer.C:
+		return "", ErrTimeout
+	}
+}
+
+/*
+parseTemplate parses a template and checks that it only uses the allowed syntax.
+
+- Args:
+	* `body` (string) The template text.
+
+- Returns:
+	(*template.Template) The template, or an error describing the syntax error or the first disallowed construct.
+*/
+func parseTemplate(body string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return nil, cleanError(err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("define and block are not allowed in prompt templates")
+	}
+	if tmpl.Tree != nil {
+		if err := checkNode(tmpl.Tree, tmpl.Tree.Root); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+/*
+checkNode rejects the parts of a parse tree whose run time is not bounded by the size of the template: range loops,
+calls of other templates and functions outside allowedFuncs.
+
+- Args:
+	* `tree` (*parse.Tree) The parse tree, used to locate errors.
+	* `node` (parse.Node) The node to check.
+
+- Returns:
+	(error) An error naming the first disallowed construct and its position.
+*/
+func checkNode(tree *parse.Tree, node parse.Node) error {
+	disallowed := ""
+	switch node := node.(type) {
+	case nil:
+		return nil
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			if err := checkNode(tree, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.ActionNode:
+		return checkNode(tree, node.Pipe)
+	case *parse.PipeNode:
+		if node == nil {
+			return nil
+		}
+		for _, command := range node.Cmds {
+			if err := checkNode(tree, command); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.CommandNode:
+		for _, argument := range node.Args {
+			if err := checkNode(tree, argument); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.ChainNode:
+		return checkNode(tree, node.Node)
+	case *parse.IfNode:
+		return checkBranches(tree, node.Pipe, node.List, node.ElseList)
+	case *parse.WithNode:
+		return checkBranches(tree, node.Pipe, node.List, node.ElseList)
+	case *parse.IdentifierNode:
+		if allowedFuncs[node.Ident] {
+			return nil
+		}
+		disallowed = "the function " + node.Ident
+	case *parse.RangeNode:
+		disallowed = "range"
+	case *parse.TemplateNode:
+		disallowed = "template"
+	case *parse.TextNode, *parse.CommentNode, *parse.FieldNode, *parse.VariableNode, *parse.DotNode,
+		*parse.StringNode, *parse.NumberNode, *parse.BoolNode, *parse.NilNode:
+		return nil
+	default:
+		disallowed = node.String()
+	}
+
+	location, _ := tree.ErrorContext(node)
+	location = strings.TrimPrefix(location, "prompt:")
+	return fmt.Errorf("line %s: %s is not allowed in prompt templates", location, disallowed)
+}
+
+/*
+checkBranches checks the condition and both branches of an if or with action.
+
+- Args:
+	* `tree` (*parse.Tree) The parse tree, used to locate errors.
+	* `pipe` (*parse.PipeNode) The condition.
+	* `list` (*parse.ListNode) The branch run when the condition holds.
+	* `elseList` (*parse.ListNode) The else branch, or nil.
+
+- Returns:
+	(error) An error naming the first disallowed construct and its position.
+*/
+func checkBranches(tree *parse.Tree, pipe *parse.PipeNode, list, elseList *parse.ListNode) error {
+	for _, node := range []parse.Node{pipe, list, elseList} {
+		if err := checkNode(tree, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+collectFields appends the names of the fields a parse tree reads from its data, such as `language` for
+`{{ .language }}`.
+
+- Args:
+	* `node` (parse.Node) The node to walk.
+	* `names` (*[]string) The names found so far; new names are appended.
+*/
+func collectFields(node parse.Node, names *[]string) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			collectFields(child, names)
+		}
+	case *parse.ActionNode:
+		collectFields(node.Pipe, names)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, command := range node.Cmds {
+			collectFields(command, names)
+		}
+	case *parse.CommandNode:
+		for _, argument := range node.Args {
+			collectFields(argument, names)
+		}
+	case *parse.FieldNode:
+		if len(node.Ident) > 0 && !slices.Contains(*names, node.Ident[0]) {
+			*names = append(*names, node.Ident[0])
+		}
+	case *parse.IfNode:
+		collectFields(node.Pipe, names)
+		collectFields(node.List, names)
+		collectFields(node.ElseList, names)
+	case *parse.RangeNode:
+		collectFields(node.Pipe, names)
+		collectFields(node.ElseList, names)
+	case *parse.WithNode:
+		collectFields(node.Pipe, names)
+		collectFields(node.ElseList, names)
+	}
+}
+
+/*
+cleanError replaces the internal template name in template errors with "line", so "template: prompt:1: unclosed
+action" becomes "line 1: unclosed action".
+
+- Args:
+	* `err` (error) The error returned by text/template.
+
+- Returns:
+	(error) The error with a readable message.
+*/
+func cleanError(err error) error {
+	message := strings.TrimPrefix(err.Error(), "template: ")
+	if rest, found := strings.CutPrefix(message, "prompt:"); found {
+		message = "line " + rest
+	}
+	return errors.New(message)
+}
+
+// limitedBuffer is a bytes.Buffer that fails with ErrTooLong instead of growing past its limit, and with ErrTimeout
+// once its deadline has passed.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	deadline time.Time
+}
+
+// Write implements io.Writer.
+func (buffer *limitedBuffer) Write(data []byte) (int, error) {
+	if time.Now().After(buffer.deadline) {
+		return 0, ErrTimeout
+	}
+	if buffer.Len()+len(data) > buffer.limit {
+		return 0, ErrTooLong
+	}
+	return buffer.Buffer.Write(data)
+}
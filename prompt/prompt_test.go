@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseVariables(t *testing.T) {
+	variables, err := Parse(`Review this {{ .language }} code{{ if .focus }}, focusing on {{ .focus }}{{ end }}. {{ .language }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"language", "focus"}; !slices.Equal(variables, want) {
+		t.Errorf("variables = %v, want %v", variables, want)
+	}
+}
+
+func TestParseRejectsUnboundedTemplates(t *testing.T) {
+	bodies := map[string]string{
+		"range over an integer": `{{ range 2000000000 }}{{ end }}`,
+		"range over a field":    `{{ range .items }}x{{ end }}`,
+		"nested range":          `{{ if true }}{{ range 10 }}{{ end }}{{ end }}`,
+		"define":                `{{ define "x" }}{{ template "x" . }}{{ template "x" . }}{{ end }}{{ template "x" . }}`,
+		"block":                 `{{ block "x" . }}hi{{ end }}`,
+		"template":              `{{ template "missing" }}`,
+		"printf":                `{{ printf "%0999999999d" 1 }}`,
+		"printf in a with":      `{{ with .name }}{{ . | printf "%s" }}{{ end }}`,
+	}
+	for name, body := range bodies {
+		if _, err := Parse(body); err == nil {
+			t.Errorf("%s: Parse(%q) accepted the template", name, body)
+		}
+		if _, err := Render(body, nil); err == nil {
+			t.Errorf("%s: Render(%q) expanded the template", name, body)
+		}
+	}
+}
+
+func TestParseReportsPosition(t *testing.T) {
+	_, err := Parse("first line\n{{ range 5 }}{{ end }}")
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") || !strings.Contains(err.Error(), "range") {
+		t.Errorf("error = %v, want one naming range on line 2", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	text, err := Render(`  Hello {{ .name }}{{ if eq .tone "formal" }}, pleased to meet you{{ else }}!{{ end }} {{ len .name }}  `,
+		map[string]string{"name": "Ada", "tone": "formal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello Ada, pleased to meet you 3"; text != want {
+		t.Errorf("Render = %q, want %q", text, want)
+	}
+
+	if text, err := Render(`[{{ .missing }}]`, nil); err != nil || text != "[]" {
+		t.Errorf("Render with a missing variable = %q, %v, want []", text, err)
+	}
+}
+
+func TestRenderLimits(t *testing.T) {
+	long := strings.Repeat("x", maxOutput/2)
+	if _, err := Render(`{{ .v }}{{ .v }}{{ .v }}`, map[string]string{"v": long}); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Render of an oversized expansion = %v, want ErrTooLong", err)
+	}
+
+	output := &limitedBuffer{limit: maxOutput, deadline: time.Now().Add(-time.Second)}
+	if _, err := output.Write([]byte("late")); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Write after the deadline = %v, want ErrTimeout", err)
+	}
+}
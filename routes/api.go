@@ -0,0 +1,1267 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gochat/ai"
+	"gochat/attachment"
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// apiPrefix is the path prefix of the versioned JSON API.
+const apiPrefix = "/api/"
+
+// apiUser is the JSON representation of a user in the API.
+type apiUser struct {
+	ID              uint                      `json:"id"`
+	Username        string                    `json:"username"`
+	IsAdmin         bool                      `json:"is_admin"`
+	DefaultSettings models.GenerationSettings `json:"default_settings"`
+	CreatedAt       time.Time                 `json:"created_at"`
+}
+
+// apiChat is the JSON representation of a chat in the API. Messages holds the active branch and is only included
+// when a single chat is requested. NextBefore is set when the messages are a page with older messages before it.
+type apiChat struct {
+	ID              uint                      `json:"id"`
+	Title           string                    `json:"title"`
+	DisplayTitle    string                    `json:"display_title"`
+	ActiveMessageID *uint                     `json:"active_message_id"`
+	FolderID        *uint                     `json:"folder_id"`
+	Pinned          bool                      `json:"pinned"`
+	Tags            []string                  `json:"tags"`
+	Settings        models.GenerationSettings `json:"settings"`
+	CreatedAt       time.Time                 `json:"created_at"`
+	UpdatedAt       time.Time                 `json:"updated_at"`
+	Messages        []apiMessage              `json:"messages,omitempty"`
+	NextBefore      *uint                     `json:"next_before,omitempty"`
+}
+
+// apiTrashedChat is the JSON representation of a chat in the trash. PurgeAt is when the chat will be deleted
+// permanently, null if deleted chats are kept until the trash is emptied.
+type apiTrashedChat struct {
+	ID           uint       `json:"id"`
+	Title        string     `json:"title"`
+	DisplayTitle string     `json:"display_title"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    time.Time  `json:"deleted_at"`
+	PurgeAt      *time.Time `json:"purge_at"`
+}
+
+// apiFolder is the JSON representation of a folder in the API.
+type apiFolder struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// apiMessage is the JSON representation of a message in the API.
+type apiMessage struct {
+	ID          uint               `json:"id"`
+	ChatID      uint               `json:"chat_id"`
+	ParentID    *uint              `json:"parent_id"`
+	MessageType models.MessageType `json:"message_type"`
+	Message     string             `json:"message"`
+	Attachments []apiAttachment    `json:"attachments"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// apiAttachment is the JSON representation of a file attached to a message. ThumbnailURL is only set for images.
+type apiAttachment struct {
+	ID           uint    `json:"id"`
+	Filename     string  `json:"filename"`
+	ContentType  string  `json:"content_type"`
+	Size         int64   `json:"size"`
+	URL          string  `json:"url"`
+	ThumbnailURL *string `json:"thumbnail_url"`
+}
+
+// apiContextUsage is the JSON representation of how much of the provider's context window a chat uses. Summary is
+// the rolling summary sent in place of the oldest messages of the active branch, if there is one.
+type apiContextUsage struct {
+	Tokens             int    `json:"tokens"`
+	Budget             int    `json:"budget"`
+	Window             int    `json:"window"`
+	Percent            int    `json:"percent"`
+	SummarizedMessages int    `json:"summarized_messages"`
+	Summary            string `json:"summary"`
+}
+
+// apiError is the body of every JSON error response, wrapped as {"error": {...}}.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiMessageInput is the request body for sending and editing messages. Messages with attachments are sent as
+// multipart forms with the text in a `message` field and the files in `files` fields.
+type apiMessageInput struct {
+	Message string `json:"message" form:"message"`
+}
+
+// apiFolderInput is the request body for filing a chat into a folder. A null or zero folder takes the chat out of its
+// folder.
+type apiFolderInput struct {
+	FolderID *uint `json:"folder_id"`
+}
+
+// apiTagsInput is the request body for replacing the tags of a chat.
+type apiTagsInput struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// apiChatInput is the request body for creating and renaming chats. Settings replace the user's defaults for a new
+// chat and are ignored when renaming.
+type apiChatInput struct {
+	Title    string                     `json:"title" binding:"max=120"`
+	Settings *models.GenerationSettings `json:"settings"`
+}
+
+/*
+AddAPIRoutes adds the versioned JSON API to the Gin router.
+
+The API mirrors the HTMX endpoints with stable JSON schemas. Clients authenticate with an API token from
+`/user/tokens` in an `Authorization: Bearer <token>` header, or with the session cookie. Errors are always returned as
+`{"error": {"code": "...", "message": "..."}}`.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider used to answer messages.
+	* `files` (*attachment.Store) The store of the files attached to messages.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged, 0 if they are kept until
+	  the trash is emptied.
+*/
+func AddAPIRoutes(router *gin.Engine, db *gorm.DB, provider ai.Provider, files *attachment.Store, retention time.Duration) {
+	api := router.Group("/api/v1", RequireAuth(db))
+	api.GET("/me", func(context *gin.Context) { apiGetMe(context) })
+	api.GET("/me/settings", func(context *gin.Context) { apiGetDefaultSettings(context) })
+	api.PUT("/me/settings", func(context *gin.Context) { apiUpdateDefaultSettings(context, db) })
+	api.GET("/chats", func(context *gin.Context) { apiListChats(context, db) })
+	api.POST("/chats", func(context *gin.Context) { apiCreateChat(context, db) })
+	api.GET("/chats/:chat_id", func(context *gin.Context) { apiGetChat(context, db) })
+	api.PATCH("/chats/:chat_id", func(context *gin.Context) { apiRenameChat(context, db) })
+	api.DELETE("/chats/:chat_id", func(context *gin.Context) { apiDeleteChat(context, db) })
+	api.PUT("/chats/:chat_id/folder", func(context *gin.Context) { apiMoveChat(context, db) })
+	api.PUT("/chats/:chat_id/pin", func(context *gin.Context) { apiPinChat(context, db, true) })
+	api.DELETE("/chats/:chat_id/pin", func(context *gin.Context) { apiPinChat(context, db, false) })
+	api.PUT("/chats/:chat_id/tags", func(context *gin.Context) { apiTagChat(context, db) })
+	api.GET("/chats/:chat_id/settings", func(context *gin.Context) { apiGetChatSettings(context, db) })
+	api.PUT("/chats/:chat_id/settings", func(context *gin.Context) { apiUpdateChatSettings(context, db) })
+	api.GET("/chats/:chat_id/context", func(context *gin.Context) { apiGetChatContext(context, db, provider) })
+	api.GET("/chats/:chat_id/messages", func(context *gin.Context) { apiListMessages(context, db) })
+	api.POST("/chats/:chat_id/messages", func(context *gin.Context) { apiSendMessage(context, db, provider, files) })
+	api.PUT("/chats/:chat_id/messages/:message_id", func(context *gin.Context) { apiEditMessage(context, db, provider) })
+	api.POST("/chats/:chat_id/messages/:message_id/regenerate", func(context *gin.Context) {
+		apiRegenerateMessage(context, db, provider)
+	})
+	api.GET("/attachments/:attachment_id", func(context *gin.Context) { serveAttachment(context, db, files, false) })
+	api.GET("/attachments/:attachment_id/thumbnail", func(context *gin.Context) {
+		serveAttachment(context, db, files, true)
+	})
+	api.GET("/folders", func(context *gin.Context) { apiListFolders(context, db) })
+	api.POST("/folders", func(context *gin.Context) { apiCreateFolder(context, db) })
+	api.PATCH("/folders/:folder_id", func(context *gin.Context) { apiRenameFolder(context, db) })
+	api.DELETE("/folders/:folder_id", func(context *gin.Context) { apiDeleteFolder(context, db) })
+	api.GET("/tags", func(context *gin.Context) { apiListTags(context, db) })
+	api.GET("/search", func(context *gin.Context) { apiSearch(context, db) })
+	api.GET("/trash", func(context *gin.Context) { apiListTrash(context, db, retention) })
+	api.DELETE("/trash", func(context *gin.Context) { apiEmptyTrash(context, db, files) })
+	api.POST("/trash/:chat_id/restore", func(context *gin.Context) { apiRestoreChat(context, db) })
+}
+
+/*
+apiGetMe returns the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `JSON` The user.
+*/
+func apiGetMe(context *gin.Context) {
+	context.JSON(http.StatusOK, newAPIUser(utils.CurrentUser(context)))
+}
+
+/*
+apiListChats returns a page of the chats of the current user, pinned first and then most recently changed first,
+without their messages.
+
+The `limit` query parameter sets the page size and `before` takes the `next_cursor` of the previous page. The
+`folder` query parameter limits the list to a folder, or with "none" to the chats outside any folder, and `tag` to the
+chats with a tag.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"chats": [...], "next_cursor": "..."}, without `next_cursor` on the last page.
+*/
+func apiListChats(context *gin.Context, db *gorm.DB) {
+	cursor, limit, err := chatPage(context)
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, invalidPageMessage)
+		return
+	}
+	filter, err := chatFilter(context)
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, invalidFolderMessage)
+		return
+	}
+
+	chats, next, err := database.GetChatPageForUser(db, utils.CurrentUserID(context), filter, cursor, limit)
+	if err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to retrieve chats")
+		return
+	}
+
+	response := gin.H{"chats": newAPIChats(chats)}
+	if next != nil {
+		response["next_cursor"] = next.String()
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+/*
+apiGetDefaultSettings returns the generation settings new chats of the current user start with.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `JSON` The settings.
+*/
+func apiGetDefaultSettings(context *gin.Context) {
+	context.JSON(http.StatusOK, utils.CurrentUser(context).DefaultSettings)
+}
+
+/*
+apiUpdateDefaultSettings replaces the generation settings new chats of the current user start with.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The saved settings.
+*/
+func apiUpdateDefaultSettings(context *gin.Context, db *gorm.DB) {
+	settings, ok := apiBindSettings(context)
+	if !ok {
+		return
+	}
+
+	if err := database.UpdateUserDefaultSettings(db, utils.CurrentUserID(context), settings); err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to save settings")
+		return
+	}
+	context.JSON(http.StatusOK, settings)
+}
+
+/*
+apiCreateChat creates a chat for the current user, with an optional title.
+
+The chat starts with the settings given in the request, or with the user's default settings.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The new chat with status 201.
+*/
+func apiCreateChat(context *gin.Context, db *gorm.DB) {
+	var input apiChatInput
+	if context.Request.ContentLength != 0 {
+		if err := context.ShouldBindJSON(&input); err != nil {
+			abortAPI(context, http.StatusBadRequest, "Titles may have at most 120 characters")
+			return
+		}
+	}
+
+	user := utils.CurrentUser(context)
+	chat := models.Chat{UserID: user.ID, Title: strings.TrimSpace(input.Title), Settings: user.DefaultSettings}
+	if input.Settings != nil {
+		input.Settings.Model = strings.TrimSpace(input.Settings.Model)
+		if err := validateSettings(*input.Settings); err != nil {
+			abortAPI(context, http.StatusBadRequest, err.Error())
+			return
+		}
+		chat.Settings = *input.Settings
+	}
+	if err := database.AddChat(db, &chat); err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to create chat")
+		return
+	}
+	context.JSON(http.StatusCreated, newAPIChat(&chat))
+}
+
+/*
+apiGetChat returns a chat of the current user with the messages of its active branch.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The chat.
+*/
+func apiGetChat(context *gin.Context, db *gorm.DB) {
+	chat, ok := apiLoadChat(context, db)
+	if !ok {
+		return
+	}
+
+	response := newAPIChat(chat)
+	response.Messages = newAPIMessages(chat.Messages)
+	context.JSON(http.StatusOK, response)
+}
+
+/*
+apiRenameChat sets the title of a chat of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The renamed chat.
+*/
+func apiRenameChat(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	var input apiChatInput
+	if err := context.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Title) == "" {
+		abortAPI(context, http.StatusBadRequest, "Titles need 1 to 120 characters")
+		return
+	}
+
+	chat, err := database.RenameChatForUser(db, chatID, utils.CurrentUserID(context), strings.TrimSpace(input.Title))
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	chat.Title = strings.TrimSpace(input.Title)
+	context.JSON(http.StatusOK, newAPIChat(chat))
+}
+
+/*
+apiDeleteChat moves a chat of the current user to the trash.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `204` No content.
+*/
+func apiDeleteChat(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteChatForUser(db, chatID, utils.CurrentUserID(context)); err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+/*
+apiMoveChat files a chat of the current user into one of the user's folders, or takes it out of its folder.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The moved chat.
+*/
+func apiMoveChat(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	var input apiFolderInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		abortAPI(context, http.StatusBadRequest, "folder_id must be a folder ID or null")
+		return
+	}
+	if input.FolderID != nil && *input.FolderID == 0 {
+		input.FolderID = nil
+	}
+
+	chat, _, err := database.MoveChatForUser(db, chatID, utils.CurrentUserID(context), input.FolderID)
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, newAPIChat(chat))
+}
+
+/*
+apiPinChat pins a chat of the current user to the top of the chat list, or unpins it.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `pinned` (bool) Whether the chat is pinned.
+
+- Returns:
+	* `JSON` The chat.
+*/
+func apiPinChat(context *gin.Context, db *gorm.DB, pinned bool) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	chat, err := database.SetChatPinnedForUser(db, chatID, utils.CurrentUserID(context), pinned)
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, newAPIChat(chat))
+}
+
+/*
+apiTagChat replaces the tags of a chat of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The chat with its new tags.
+*/
+func apiTagChat(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	var input apiTagsInput
+	if err := context.ShouldBindJSON(&input); err != nil {
+		abortAPI(context, http.StatusBadRequest, "tags must be a list of strings")
+		return
+	}
+
+	chat, err := database.SetChatTagsForUser(db, chatID, utils.CurrentUserID(context), input.Tags)
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, newAPIChat(chat))
+}
+
+/*
+apiListFolders returns the folders of the current user, ordered by name.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"folders": [...]}.
+*/
+func apiListFolders(context *gin.Context, db *gorm.DB) {
+	folders, err := database.GetFoldersForUser(db, utils.CurrentUserID(context))
+	if err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to retrieve folders")
+		return
+	}
+
+	converted := make([]apiFolder, len(folders))
+	for i := range folders {
+		converted[i] = newAPIFolder(&folders[i])
+	}
+	context.JSON(http.StatusOK, gin.H{"folders": converted})
+}
+
+/*
+apiCreateFolder creates a folder for the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The new folder with status 201.
+*/
+func apiCreateFolder(context *gin.Context, db *gorm.DB) {
+	var input folderInput
+	if err := context.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		abortAPI(context, http.StatusBadRequest, "Folder names need 1 to 60 characters")
+		return
+	}
+
+	folder := models.Folder{UserID: utils.CurrentUserID(context), Name: strings.TrimSpace(input.Name)}
+	if err := database.AddFolder(db, &folder); err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to create the folder")
+		return
+	}
+	context.JSON(http.StatusCreated, newAPIFolder(&folder))
+}
+
+/*
+apiRenameFolder sets the name of a folder of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The renamed folder.
+*/
+func apiRenameFolder(context *gin.Context, db *gorm.DB) {
+	folderID, ok := apiParamID(context, "folder_id")
+	if !ok {
+		return
+	}
+
+	var input folderInput
+	if err := context.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		abortAPI(context, http.StatusBadRequest, "Folder names need 1 to 60 characters")
+		return
+	}
+
+	folder, err := database.RenameFolderForUser(db, folderID, utils.CurrentUserID(context), strings.TrimSpace(input.Name))
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, newAPIFolder(folder))
+}
+
+/*
+apiDeleteFolder deletes a folder of the current user. Its chats are kept and moved out of the folder.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `204` No content.
+*/
+func apiDeleteFolder(context *gin.Context, db *gorm.DB) {
+	folderID, ok := apiParamID(context, "folder_id")
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteFolderForUser(db, folderID, utils.CurrentUserID(context)); err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+/*
+apiListTags returns the tags on the chats of the current user, ordered by name, with the number of chats that have
+them. Chats in the trash are not counted.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"tags": [{"name": "...", "chats": n}, ...]}.
+*/
+func apiListTags(context *gin.Context, db *gorm.DB) {
+	tags, err := database.GetTagsForUser(db, utils.CurrentUserID(context))
+	if err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to retrieve tags")
+		return
+	}
+	if tags == nil {
+		tags = []database.TagCount{}
+	}
+	context.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+/*
+apiListTrash returns the deleted chats of the current user, most recently deleted first.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+
+- Returns:
+	* `JSON` {"chats": [...]}.
+*/
+func apiListTrash(context *gin.Context, db *gorm.DB, retention time.Duration) {
+	chats, err := database.GetTrashedChatsForUser(db, utils.CurrentUserID(context))
+	if err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to retrieve the trash")
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"chats": newAPITrashedChats(chats, retention)})
+}
+
+/*
+apiRestoreChat moves a deleted chat of the current user out of the trash.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The restored chat.
+*/
+func apiRestoreChat(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	chat, err := database.RestoreChatForUser(db, chatID, utils.CurrentUserID(context))
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, newAPIChat(chat))
+}
+
+/*
+apiEmptyTrash permanently deletes the deleted chats of the current user together with their messages and attached
+files.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The store the files of purged attachments are removed from.
+
+- Returns:
+	* `JSON` {"purged": n}, the number of chats deleted.
+*/
+func apiEmptyTrash(context *gin.Context, db *gorm.DB, files *attachment.Store) {
+	purged, err := database.EmptyTrashForUser(db, utils.CurrentUserID(context))
+	if err != nil {
+		log.Println("error", err, "apiEmptyTrash: purge, error block 1")
+		abortAPI(context, http.StatusInternalServerError, "Failed to empty the trash")
+		return
+	}
+	removePurgedFiles(context, db, files)
+	context.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+/*
+apiGetChatSettings returns the generation settings of a chat of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The settings.
+*/
+func apiGetChatSettings(context *gin.Context, db *gorm.DB) {
+	chat, ok := apiLoadChat(context, db)
+	if !ok {
+		return
+	}
+	context.JSON(http.StatusOK, chat.Settings)
+}
+
+/*
+apiUpdateChatSettings replaces the generation settings of a chat of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The saved settings.
+*/
+func apiUpdateChatSettings(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+	settings, ok := apiBindSettings(context)
+	if !ok {
+		return
+	}
+
+	chat, err := database.UpdateChatSettingsForUser(db, chatID, utils.CurrentUserID(context), settings)
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, chat.Settings)
+}
+
+/*
+apiGetChatContext returns how much of the provider's context window the next reply in a chat of the current user takes.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+
+- Returns:
+	* `JSON` The context usage.
+*/
+func apiGetChatContext(context *gin.Context, db *gorm.DB, provider ai.Provider) {
+	chat, ok := apiLoadChat(context, db)
+	if !ok {
+		return
+	}
+
+	usage, summary := chatContextUsage(db, provider, chat)
+	context.JSON(http.StatusOK, apiContextUsage{
+		Tokens:             usage.Tokens,
+		Budget:             usage.Budget,
+		Window:             usage.Window,
+		Percent:            usage.Percent(),
+		SummarizedMessages: usage.Summarized,
+		Summary:            summary,
+	})
+}
+
+/*
+apiListMessages returns a page of the messages of the active branch of a chat of the current user.
+
+Pages are counted back from the newest message. The `limit` query parameter sets the page size and `before` takes the
+`next_before` of the previous page.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"messages": [...], "next_before": 123} in chronological order, without `next_before` on the page that
+	  starts the chat.
+*/
+func apiListMessages(context *gin.Context, db *gorm.DB) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+	page, err := messagePage(context)
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, invalidPageMessage)
+		return
+	}
+
+	chat, older, err := database.GetChatMessagePageForUser(db, chatID, utils.CurrentUserID(context), page)
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+
+	response := gin.H{"messages": newAPIMessages(chat.Messages)}
+	if older {
+		response["next_before"] = chat.Messages[0].ID
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+/*
+apiSendMessage adds a user message to a chat of the current user and waits for the AI reply.
+
+Clients that want the reply as it is produced can send the message through the HTMX endpoint and follow the event
+stream instead. Files are attached by sending a multipart form; a message with files may have no text.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+	* `files` (*attachment.Store) The attachment store.
+
+- Returns:
+	* `JSON` {"message": {...}, "reply": {...}} with status 201.
+*/
+func apiSendMessage(context *gin.Context, db *gorm.DB, provider ai.Provider, files *attachment.Store) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+
+	limitRequestSize(context, files)
+	var input apiMessageInput
+	if err := context.ShouldBind(&input); err != nil {
+		status, message := attachmentErrorResponse(err, files.Limits())
+		if status == http.StatusInternalServerError {
+			status, message = http.StatusBadRequest, "Invalid input"
+		}
+		abortAPI(context, status, message)
+		return
+	}
+
+	userID := utils.CurrentUserID(context)
+	message := models.Message{UserID: userID, Message: input.Message, MessageType: models.UserMessageType}
+	attachments, err := saveAttachments(context, files)
+	if err != nil {
+		status, text := attachmentErrorResponse(err, files.Limits())
+		abortAPI(context, status, text)
+		log.Println("error", err, "apiSendMessage: save attachments, error block 1")
+		return
+	}
+	if strings.TrimSpace(input.Message) == "" && len(attachments) == 0 {
+		abortAPI(context, http.StatusBadRequest, "The message must not be empty")
+		return
+	}
+	message.Attachments = attachments
+
+	if err := database.AddMessageForUser(db, chatID, userID, &message); err != nil {
+		if err := files.Remove(context.Request.Context(), attachments); err != nil {
+			log.Println("error", err, "apiSendMessage: remove attachments, error block 2")
+		}
+		abortAPIChatError(context, err)
+		return
+	}
+
+	apiRespondWithReply(context, db, provider, &message)
+}
+
+/*
+apiEditMessage edits a user message of a chat of the current user and waits for the AI reply to the new version.
+
+The new version is added as a sibling of the original message, like an edit in the web interface.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+
+- Returns:
+	* `JSON` {"message": {...}, "reply": {...}} with status 201.
+*/
+func apiEditMessage(context *gin.Context, db *gorm.DB, provider ai.Provider) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := apiParamID(context, "message_id")
+	if !ok {
+		return
+	}
+
+	var input apiMessageInput
+	if err := context.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Message) == "" {
+		abortAPI(context, http.StatusBadRequest, "The message must not be empty")
+		return
+	}
+
+	userID := utils.CurrentUserID(context)
+	release, status, message := claimChat(db, chatID, userID)
+	if status != http.StatusOK {
+		abortAPI(context, status, message)
+		return
+	}
+
+	edited, _, err := database.EditMessageForUser(db, chatID, userID, messageID, input.Message)
+	release()
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+
+	apiRespondWithReply(context, db, provider, edited)
+}
+
+/*
+apiRegenerateMessage generates another reply to the user message an AI reply answered and waits for it.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+
+- Returns:
+	* `JSON` {"message": {...}, "reply": {...}} with status 201, where message is the user message.
+*/
+func apiRegenerateMessage(context *gin.Context, db *gorm.DB, provider ai.Provider) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return
+	}
+	replyID, ok := apiParamID(context, "message_id")
+	if !ok {
+		return
+	}
+
+	userID := utils.CurrentUserID(context)
+	release, status, message := claimChat(db, chatID, userID)
+	if status != http.StatusOK {
+		abortAPI(context, status, message)
+		return
+	}
+
+	prompt, _, err := database.RegenerateReplyForUser(db, chatID, userID, replyID)
+	release()
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+
+	apiRespondWithReply(context, db, provider, prompt)
+}
+
+/*
+apiSearch searches the messages of all chats of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"query": "...", "hits": [...]}.
+*/
+func apiSearch(context *gin.Context, db *gorm.DB) {
+	limit := defaultSearchLimit
+	if value := context.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			abortAPI(context, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+
+	query := strings.TrimSpace(context.Query("q"))
+	hits, err := database.SearchMessagesForUser(db, utils.CurrentUserID(context), query, limit)
+	if err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Search failed")
+		log.Println("error", err, "apiSearch: search, error block 1")
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"query": query, "hits": hits})
+}
+
+/*
+apiRespondWithReply generates the AI reply to a user message and responds with both.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+	* `prompt` (*models.Message) The saved user message.
+*/
+func apiRespondWithReply(context *gin.Context, db *gorm.DB, provider ai.Provider, prompt *models.Message) {
+	ctx := context.Request.Context()
+	userID := utils.CurrentUserID(context)
+
+	finish, generating := startReply(ctx, prompt.ChatID, prompt.ID)
+	if generating {
+		defer finish()
+	}
+
+	chat, err := database.GetChatForUser(db, prompt.ChatID, userID)
+	if err != nil {
+		abortAPIChatError(context, err)
+		return
+	}
+	history, reply, err := utils.SplitHistoryAt(chat.Messages, prompt.ID)
+	if err != nil {
+		abortAPI(context, http.StatusConflict, "The message is no longer on the active branch")
+		return
+	}
+
+	if reply == nil && generating {
+		reply, _, err = generateReply(ctx, db, provider, chat, history, func(string) error { return ctx.Err() })
+	}
+	switch {
+	case errors.Is(err, errNoReply) || (err == nil && reply == nil):
+		abortAPI(context, http.StatusBadGateway, "The message was saved, but the AI provider did not reply")
+		return
+	case err != nil:
+		abortAPI(context, http.StatusInternalServerError, "Failed to save the AI response")
+		log.Println("error", err, "apiRespondWithReply: generate reply, error block 1")
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": newAPIMessage(*prompt), "reply": newAPIMessage(*reply)})
+}
+
+/*
+apiBindSettings reads generation settings from the JSON request body, responding with an error if they are invalid.
+
+Missing or null properties are not set, so the provider's defaults apply.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `models.GenerationSettings` The settings.
+	* `bool` False if an error response was sent.
+*/
+func apiBindSettings(context *gin.Context) (models.GenerationSettings, bool) {
+	var settings models.GenerationSettings
+	if err := context.ShouldBindJSON(&settings); err != nil {
+		abortAPI(context, http.StatusBadRequest, "Invalid settings")
+		return settings, false
+	}
+
+	settings.Model = strings.TrimSpace(settings.Model)
+	if err := validateSettings(settings); err != nil {
+		abortAPI(context, http.StatusBadRequest, err.Error())
+		return settings, false
+	}
+	return settings, true
+}
+
+/*
+apiLoadChat loads the chat named by the `chat_id` URL parameter for the current user, responding with an error if
+it cannot.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `*models.Chat` The chat with the messages of its active branch.
+	* `bool` False if an error response was sent.
+*/
+func apiLoadChat(context *gin.Context, db *gorm.DB) (*models.Chat, bool) {
+	chatID, ok := apiParamID(context, "chat_id")
+	if !ok {
+		return nil, false
+	}
+
+	chat, err := database.GetChatForUser(db, chatID, utils.CurrentUserID(context))
+	if err != nil {
+		abortAPIChatError(context, err)
+		return nil, false
+	}
+	return chat, true
+}
+
+/*
+apiParamID parses a numeric ID from a URL parameter, responding with an error if it is invalid.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `name` (string) The name of the URL parameter.
+
+- Returns:
+	* `uint` The ID.
+	* `bool` False if an error response was sent.
+*/
+func apiParamID(context *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(context.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		abortAPI(context, http.StatusBadRequest, "Invalid "+strings.ReplaceAll(name, "_", " "))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+/*
+abortAPI ends a request with an API error envelope.
+
+Every JSON error response goes through it, from /api/v1 and from the routes that answer JSON clients, so clients parse
+one error shape. The error code is derived from the HTTP status, so clients can branch on it without parsing messages.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message.
+*/
+func abortAPI(context *gin.Context, status int, message string) {
+	code := map[int]string{
+		http.StatusBadRequest:            "bad_request",
+		http.StatusUnauthorized:          "unauthorized",
+		http.StatusForbidden:             "forbidden",
+		http.StatusNotFound:              "not_found",
+		http.StatusConflict:              "conflict",
+		http.StatusRequestEntityTooLarge: "too_large",
+		http.StatusUnprocessableEntity:   "unprocessable",
+		http.StatusBadGateway:            "ai_unavailable",
+	}[status]
+	if code == "" {
+		code = "internal"
+	}
+	context.AbortWithStatusJSON(status, gin.H{"error": apiError{Code: code, Message: message}})
+}
+
+/*
+newAPIUser converts a user into its API representation.
+
+- Args:
+	* `user` (*models.User) The user.
+
+- Returns:
+	(apiUser) The API representation.
+*/
+func newAPIUser(user *models.User) apiUser {
+	return apiUser{
+		ID:              user.ID,
+		Username:        user.Username,
+		IsAdmin:         user.IsAdmin,
+		DefaultSettings: user.DefaultSettings,
+		CreatedAt:       user.CreatedAt,
+	}
+}
+
+/*
+newAPIChat converts a chat into its API representation, without messages.
+
+- Args:
+	* `chat` (*models.Chat) The chat.
+
+- Returns:
+	(apiChat) The API representation.
+*/
+func newAPIChat(chat *models.Chat) apiChat {
+	tags := make([]string, len(chat.Tags))
+	for i, tag := range chat.Tags {
+		tags[i] = tag.Name
+	}
+	return apiChat{
+		ID:              chat.ID,
+		Title:           chat.Title,
+		DisplayTitle:    utils.ChatTitle(chat),
+		ActiveMessageID: chat.ActiveMessageID,
+		FolderID:        chat.FolderID,
+		Pinned:          chat.Pinned,
+		Tags:            tags,
+		Settings:        chat.Settings,
+		CreatedAt:       chat.CreatedAt,
+		UpdatedAt:       chat.UpdatedAt,
+	}
+}
+
+/*
+newAPIFolder converts a folder into its API representation.
+
+- Args:
+	* `folder` (*models.Folder) The folder.
+
+- Returns:
+	(apiFolder) The API representation.
+*/
+func newAPIFolder(folder *models.Folder) apiFolder {
+	return apiFolder{ID: folder.ID, Name: folder.Name, CreatedAt: folder.CreatedAt, UpdatedAt: folder.UpdatedAt}
+}
+
+/*
+newAPIChats converts chats into their API representation.
+
+- Args:
+	* `chats` ([]models.Chat) The chats.
+
+- Returns:
+	([]apiChat) The API representations, never nil.
+*/
+func newAPIChats(chats []models.Chat) []apiChat {
+	converted := make([]apiChat, len(chats))
+	for i := range chats {
+		converted[i] = newAPIChat(&chats[i])
+	}
+	return converted
+}
+
+/*
+newAPITrashedChats converts deleted chats into their API representation.
+
+- Args:
+	* `chats` ([]models.Chat) The deleted chats.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+
+- Returns:
+	([]apiTrashedChat) The API representations, never nil.
+*/
+func newAPITrashedChats(chats []models.Chat, retention time.Duration) []apiTrashedChat {
+	converted := make([]apiTrashedChat, len(chats))
+	for i := range chats {
+		converted[i] = apiTrashedChat{
+			ID:           chats[i].ID,
+			Title:        chats[i].Title,
+			DisplayTitle: utils.ChatTitle(&chats[i]),
+			CreatedAt:    chats[i].CreatedAt,
+			UpdatedAt:    chats[i].UpdatedAt,
+			DeletedAt:    chats[i].DeletedAt.Time,
+			PurgeAt:      trashPurgeTime(&chats[i], retention),
+		}
+	}
+	return converted
+}
+
+/*
+newAPIMessage converts a message into its API representation.
+
+- Args:
+	* `message` (models.Message) The message.
+
+- Returns:
+	(apiMessage) The API representation.
+*/
+func newAPIMessage(message models.Message) apiMessage {
+	attachments := make([]apiAttachment, len(message.Attachments))
+	for i, file := range message.Attachments {
+		url := apiPrefix + "v1/attachments/" + strconv.FormatUint(uint64(file.ID), 10)
+		attachments[i] = apiAttachment{
+			ID:          file.ID,
+			Filename:    file.Filename,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+			URL:         url,
+		}
+		if file.ThumbnailKey != "" {
+			thumbnailURL := url + "/thumbnail"
+			attachments[i].ThumbnailURL = &thumbnailURL
+		}
+	}
+
+	return apiMessage{
+		ID:          message.ID,
+		ChatID:      message.ChatID,
+		ParentID:    message.ParentID,
+		MessageType: message.MessageType,
+		Message:     message.Message,
+		Attachments: attachments,
+		CreatedAt:   message.CreatedAt,
+		UpdatedAt:   message.UpdatedAt,
+	}
+}
+
+/*
+newAPIMessages converts messages into their API representation.
+
+- Args:
+	* `messages` ([]models.Message) The messages.
+
+- Returns:
+	([]apiMessage) The API representations, never nil.
+*/
+func newAPIMessages(messages []models.Message) []apiMessage {
+	converted := make([]apiMessage, len(messages))
+	for i, message := range messages {
+		converted[i] = newAPIMessage(message)
+	}
+	return converted
+}
+
+/*
+abortAPIChatError ends a request with the API error envelope for an error of the chat and message database functions.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `err` (error) The error.
+*/
+func abortAPIChatError(context *gin.Context, err error) {
+	status, message := chatErrorResponse(err)
+	abortAPI(context, status, message)
+}
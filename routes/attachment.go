@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gochat/attachment"
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+	"gochat/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// attachmentField is the name of the multipart form field files are attached with.
+const attachmentField = "files"
+
+/*
+AddAttachmentRoutes adds the routes that download the files attached to messages.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The attachment store.
+*/
+func AddAttachmentRoutes(router *gin.Engine, db *gorm.DB, files *attachment.Store) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/attachments/:attachment_id", func(context *gin.Context) {
+		serveAttachment(context, db, files, false)
+	})
+	authorized.GET("/attachments/:attachment_id/thumbnail", func(context *gin.Context) {
+		serveAttachment(context, db, files, true)
+	})
+}
+
+/*
+serveAttachment sends an attached file, or the thumbnail of an attached image, of a chat of the current user.
+
+Images are shown inline; every other file is offered as a download, so text files are never rendered as HTML.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The attachment store.
+	* `thumbnail` (bool) Whether to send the thumbnail instead of the file.
+
+- Returns:
+	* `file` The file.
+	* `error` An error if the attachment does not exist, belongs to another user or has no thumbnail.
+*/
+func serveAttachment(context *gin.Context, db *gorm.DB, files *attachment.Store, thumbnail bool) {
+	status, message := sendAttachment(context, db, files, thumbnail)
+	if status == http.StatusOK {
+		return
+	}
+	if strings.HasPrefix(context.Request.URL.Path, apiPrefix) || utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	context.HTML(status, "error_template", gin.H{"error": message})
+}
+
+/*
+sendAttachment writes an attached file or its thumbnail to the response.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The attachment store.
+	* `thumbnail` (bool) Whether to send the thumbnail instead of the file.
+
+- Returns:
+	* `status` (int) 200 if the file was sent, otherwise the status of the error response to send.
+	* `message` (string) The error message shown to the client.
+*/
+func sendAttachment(context *gin.Context, db *gorm.DB, files *attachment.Store, thumbnail bool) (int, string) {
+	attachmentID, err := strconv.ParseUint(context.Param("attachment_id"), 10, 0)
+	if err != nil || attachmentID == 0 {
+		return http.StatusBadRequest, "Invalid attachment ID"
+	}
+
+	found, err := database.GetAttachmentForUser(db, uint(attachmentID), utils.CurrentUserID(context))
+	if err != nil {
+		return chatErrorResponse(err)
+	}
+
+	key, contentType, disposition := found.StorageKey, found.ContentType, "attachment"
+	if attachment.IsImage(found.ContentType) {
+		disposition = "inline"
+	}
+	if thumbnail {
+		if found.ThumbnailKey == "" {
+			return http.StatusNotFound, "The attachment has no thumbnail"
+		}
+		key, contentType, disposition = found.ThumbnailKey, "image/png", "inline"
+	}
+
+	content, err := files.Open(context.Request.Context(), key)
+	if err != nil {
+		log.Println("error", err, "sendAttachment: open file, error block 1")
+		if errors.Is(err, storage.ErrNotFound) {
+			return http.StatusNotFound, "The file of the attachment is missing"
+		}
+		return http.StatusInternalServerError, "Failed to read the attachment"
+	}
+	defer content.Close()
+
+	context.Header("Content-Type", contentType)
+	context.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": found.Filename}))
+	context.Header("X-Content-Type-Options", "nosniff")
+	context.Header("Content-Security-Policy", "sandbox")
+	context.Header("Cache-Control", "private, max-age=86400")
+	if !thumbnail {
+		context.Header("Content-Length", strconv.FormatInt(found.Size, 10))
+	}
+	context.Status(http.StatusOK)
+	if _, err := io.Copy(context.Writer, content); err != nil {
+		log.Println("error", err, "sendAttachment: send file, error block 2")
+	}
+	return http.StatusOK, ""
+}
+
+/*
+limitRequestSize bounds the body of a request that may carry attachments to what the upload limits allow.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `files` (*attachment.Store) The attachment store.
+*/
+func limitRequestSize(context *gin.Context, files *attachment.Store) {
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, files.Limits().RequestSize())
+}
+
+/*
+saveAttachments stores the files uploaded in the `files` field of a multipart request.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `files` (*attachment.Store) The attachment store.
+
+- Returns:
+	([]models.Attachment) The attachments to save with the message, none for a request without files, or an error if
+	the files are not acceptable or could not be stored.
+*/
+func saveAttachments(context *gin.Context, files *attachment.Store) ([]models.Attachment, error) {
+	form, err := context.MultipartForm()
+	if errors.Is(err, http.ErrNotMultipart) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	uploads := form.File[attachmentField]
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+	return files.Save(context.Request.Context(), utils.CurrentUserID(context), uploads)
+}
+
+/*
+attachmentErrorResponse maps an error from reading the request or saving the attachments onto an HTTP status and a
+message for the client.
+
+- Args:
+	* `err` (error) The error.
+	* `limits` (attachment.Limits) The upload limits.
+
+- Returns:
+	* `status` (int) 413 for files or requests that are too large, 400 for other unacceptable files and 500 if the
+	  files could not be stored.
+	* `message` (string) The error message shown to the client.
+*/
+func attachmentErrorResponse(err error, limits attachment.Limits) (int, string) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf(
+			"The files are too large, attach at most %d files of up to %s each",
+			limits.MaxFiles, attachment.FormatSize(limits.MaxFileSize),
+		)
+	case errors.Is(err, attachment.ErrTooManyFiles) && limits.MaxFiles == 0:
+		return http.StatusBadRequest, "Attachments are disabled"
+	case errors.Is(err, attachment.ErrTooManyFiles):
+		return http.StatusBadRequest, fmt.Sprintf("Too many files, attach at most %d", limits.MaxFiles)
+	case errors.Is(err, attachment.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, attachment.ErrUnsupportedType):
+		return http.StatusBadRequest, err.Error()
+	default:
+		return http.StatusInternalServerError, "Failed to save the attachments"
+	}
+}
@@ -1,12 +1,15 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
-	"sort"
 	"strconv"
+	"strings"
 
+	"gochat/ai"
 	"gochat/database"
 	"gochat/models"
+	"gochat/routes/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -18,23 +21,27 @@ AddChatRoutes adds chat-related routes to the Gin router.
 - Args:
     * `router` (*gin.Engine) The Gin router.
     * `db` (*gorm.DB) The database connection.
+    * `provider` (ai.Provider) The AI provider, whose context window the chat window reports on.
 */
-func AddChatRoutes(router *gin.Engine, db *gorm.DB) {
-    router.POST("/chat", func(context *gin.Context) { createChat(context, db) })
-    router.GET("/chat/:chat_id", func(context *gin.Context) { getChatHistory(context, db) })
-    router.DELETE("/chat/:chat_id", func(context *gin.Context) { deleteChat(context, db) })
-    router.GET("/user/chats", func(context *gin.Context) { getAllChatsForUser(context, db) })
+func AddChatRoutes(router *gin.Engine, db *gorm.DB, provider ai.Provider) {
+    authorized := router.Group("", RequireAuth(db))
+    authorized.POST("/chat", func(context *gin.Context) { createChat(context, db) })
+    authorized.GET("/chat/:chat_id", func(context *gin.Context) { getChatHistory(context, db, provider) })
+    authorized.PATCH("/chat/:chat_id", func(context *gin.Context) { renameChat(context, db) })
+    authorized.DELETE("/chat/:chat_id", func(context *gin.Context) { deleteChat(context, db) })
+    authorized.GET("/user/chats", func(context *gin.Context) { getAllChatsForUser(context, db) })
 
     // HTMX routes
     // router.POST("/chat/htmx", func(context *gin.Context) { createChatHtmx(context, db) })
 }
 
 /*
-createChatHtmx creates a new chat in the database and returns the chat item as HTML.
+createChatHtmx creates a new chat in the database and returns the sidebar section it appears in as HTML.
 
 It is used for HTMX requests to create a chat without a full page reload.
-The chat is associated with the current user (hardcoded to user ID 1 for now).
-If the chat is created successfully, it returns the chat item as HTML.
+The chat is associated with the current user and starts with the user's default generation settings.
+New chats are outside any folder, so if the chat is created successfully, it returns the section of the chats outside
+any folder, filtered by the `tag` the sidebar sends along.
 If there is an error, it returns an error message.
 
 - Args:
@@ -42,18 +49,15 @@ If there is an error, it returns an error message.
     * `db` (*gorm.DB) The database connection.
 */
 func createChat(context *gin.Context, db *gorm.DB) {
-	var chat models.Chat
-	chat.UserID = 1 // TODO: Replace with actual user authentication
+	user := utils.CurrentUser(context)
+	chat := models.Chat{UserID: user.ID, Settings: user.DefaultSettings}
 
 	if err := database.AddChat(db, &chat); err != nil {
 		context.HTML(http.StatusInternalServerError, "error_template", gin.H{"error": "Failed to create chat"})
 		return
 	}
 
-	context.HTML(http.StatusOK, "chat_list_item", gin.H{
-		"id":    chat.ID,
-		"title": "Chat " + strconv.Itoa(int(chat.ID)),
-	})
+	respondSection(context, db, nil, false)
 }
 
 /*
@@ -62,52 +66,99 @@ getChatHistory retrieves the chat history for a given chat ID.
 It will conditionally return the chat history as JSON or HTML based on the Accept header.
 
 It expects the chat ID as a URL parameter.
-If the chat is found, it returns the chat history.
-If there is an error, it returns an appropriate HTTP status code and error message.
+The history is paginated from the newest message back: the `limit` query parameter sets the page size and `before`
+the ID of the oldest message the client already shows. The chat window loads older pages as the user scrolls up.
+The optional `message` query parameter names a message to scroll to. It only widens the first page of the active
+branch far enough to include it; it never switches branches, which goes through
+`POST /chat/:chat_id/message/:message_id/select`.
+If the chat is found and belongs to the current user, it returns the chat history, as JSON in the API schema
+when the client asks for it. The HTML chat window shows how much of the provider's context window the chat uses.
+If the chat does not exist it responds with 404, and if it belongs to another user with 403.
 
 - Args:
     * `context` (*gin.Context) The Gin context for the current HTTP request.
     * `db` (*gorm.DB) The database connection.
+    * `provider` (ai.Provider) The AI provider.
 
 - Returns:
-    * `messages` ([]gin.H) A list of messages in the chat.
+    * `messages` ([]gin.H) A page of messages in the chat.
 */
-func getChatHistory(context *gin.Context, db *gorm.DB) {
+func getChatHistory(context *gin.Context, db *gorm.DB, provider ai.Provider) {
     chatID, err := strconv.Atoi(context.Param("chat_id"))
     if err != nil {
-        context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+        abortAPI(context, http.StatusBadRequest, "Invalid chat ID")
+        return
+    }
+
+    page, err := messagePage(context)
+    if err != nil {
+        abortAPI(context, http.StatusBadRequest, invalidPageMessage)
         return
     }
 
-    chat, err := database.GetChat(db, uint(chatID))
+    userID := utils.CurrentUserID(context)
+    focusID, _ := strconv.Atoi(context.Query("message"))
+    if focusID > 0 && page.Before == 0 {
+        page.Through = uint(focusID)
+    }
+
+    chat, older, err := database.GetChatMessagePageForUser(db, uint(chatID), userID, page)
     if err != nil {
-        context.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+        status, message := chatErrorResponse(err)
+        abortAPI(context, status, message)
+        return
+    }
+
+    // The oldest message of this page is where the next, older page ends.
+    var nextBefore *uint
+    if older {
+        nextBefore = &chat.Messages[0].ID
+    }
+
+    if utils.WantsJSON(context) {
+        response := newAPIChat(chat)
+        response.Messages = newAPIMessages(chat.Messages)
+        response.NextBefore = nextBefore
+        context.JSON(http.StatusOK, response)
         return
     }
 
-    var messages []gin.H
-    for _, msg := range chat.Messages {
-        messages = append(messages, gin.H{
-            "id":          msg.ID,
-            "message":     msg.Message,
-            "messageType": msg.MessageType,
-        })
+    messages, err := utils.ChatHistoryData(db, chat)
+    if err != nil {
+        abortAPI(context, http.StatusInternalServerError, "Failed to access chat")
+        return
     }
 
+    olderURL := ""
+    if nextBefore != nil {
+        olderURL = pageURL(context.Request.URL.Path, nil, strconv.FormatUint(uint64(*nextBefore), 10), page.Limit)
+    }
+    if page.Before != 0 {
+        context.HTML(http.StatusOK, "message_page", gin.H{"messages": messages, "olderURL": olderURL})
+        return
+    }
+
+    usage, _ := chatContextUsage(db, provider, chat)
     context.HTML(http.StatusOK, "chat_window", gin.H{
         "messages": messages,
         "chatID":   chatID,
+        "focusID":  focusID,
+        "olderURL": olderURL,
+        "context":  contextUsageData(usage, false),
     })
     context.HTML(http.StatusOK, "input_form", gin.H{"chatID": chatID})
     // context.HTML(http.StatusOK, "chat_list", gin.H{"id": chatID, "selected": true})
 }
 
 /*
-getAllChatsForUser retrieves all chats associated with the current user.
+getAllChatsForUser retrieves the chats associated with the current user, a page at a time.
 
 It will conditionally return the chat list as JSON or HTML based on the Accept header.
 
-The user ID is hardcoded to 1 for now.
+Chats are listed pinned first and then most recently changed first. The `limit` query parameter sets the page size
+and `before` takes the cursor returned with the previous page; the HTML list ends in an element that loads the next
+page when it scrolls into view. The `folder` query parameter limits the list to a folder, or with "none" to the chats
+outside any folder, and `tag` to the chats with a tag.
 If the chats are found, it returns the chat list.
 If there is an error, it returns an appropriate HTTP status code and error message.
 
@@ -116,39 +167,113 @@ If there is an error, it returns an appropriate HTTP status code and error messa
     * `db` (*gorm.DB) The database connection.
 
 - Returns:
-    * `chats` ([]gin.H) A list of chats associated with the user.
+    * `chats` ([]gin.H) A page of chats associated with the user.
 */
 func getAllChatsForUser(context *gin.Context, db *gorm.DB) {
-    userID := 1
-    chats, err := database.GetAllChatsForUser(db, uint(userID))
+    cursor, limit, err := chatPage(context)
+    if err != nil {
+        if utils.WantsJSON(context) {
+            abortAPI(context, http.StatusBadRequest, invalidPageMessage)
+            return
+        }
+        context.HTML(http.StatusBadRequest, "chat_list", gin.H{"error": invalidPageMessage})
+        return
+    }
+    filter, err := chatFilter(context)
     if err != nil {
-        context.HTML(http.StatusInternalServerError, "partials/chat_list.html", gin.H{"error": "Failed to retrieve chats"})
+        if utils.WantsJSON(context) {
+            abortAPI(context, http.StatusBadRequest, invalidFolderMessage)
+            return
+        }
+        context.HTML(http.StatusBadRequest, "chat_list", gin.H{"error": invalidFolderMessage})
         return
     }
 
-    // Sort chats in descending order of ID
-    sort.Slice(chats, func(i, j int) bool {
-        return chats[i].ID > chats[j].ID
-    })
+    userID := utils.CurrentUserID(context)
+    chats, next, err := database.GetChatPageForUser(db, userID, filter, cursor, limit)
+    if err != nil {
+        if utils.WantsJSON(context) {
+            abortAPI(context, http.StatusInternalServerError, "Failed to retrieve chats")
+            return
+        }
+        context.HTML(http.StatusInternalServerError, "chat_list", gin.H{"error": "Failed to retrieve chats"})
+        return
+    }
+
+    nextCursor := ""
+    if next != nil {
+        nextCursor = next.String()
+    }
 
-    var chatList []gin.H
-    for _, chat := range chats {
-        chatList = append(chatList, gin.H{
-            "id":    chat.ID,
-            "title": "Chat " + strconv.Itoa(int(chat.ID)),
-        })
+    if utils.WantsJSON(context) {
+        response := gin.H{"chats": newAPIChats(chats)}
+        if nextCursor != "" {
+            response["next_cursor"] = nextCursor
+        }
+        context.JSON(http.StatusOK, response)
+        return
     }
 
     context.HTML(http.StatusOK, "chat_list", gin.H{
-        "chats": chatList,
+        "chats":   chatListItems(chats),
+        "first":   cursor == nil,
+        "moreURL": pageURL(context.Request.URL.Path, chatFilterQuery(filter), nextCursor, limit),
     })
 }
 
 /*
-deleteChat deletes a chat from the database.
+renameChat sets the title of a chat.
+
+It expects the chat ID as a URL parameter and the title as a form field or JSON property named `title`.
+If the chat belongs to the current user, it returns the renamed chat as JSON for API clients and as the chat list item
+otherwise.
+If the chat does not exist it responds with 404, and if it belongs to another user with 403.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `db` (*gorm.DB) The database connection.
+
+- Returns:
+    * `chat` (gin.H) The chat ID and its new title.
+*/
+func renameChat(context *gin.Context, db *gorm.DB) {
+    chatID, err := strconv.Atoi(context.Param("chat_id"))
+    if err != nil {
+        abortAPI(context, http.StatusBadRequest, "Invalid chat ID")
+        return
+    }
+
+    var input struct {
+        Title string `form:"title" json:"title" binding:"required,max=120"`
+    }
+    if err := context.ShouldBind(&input); err != nil || strings.TrimSpace(input.Title) == "" {
+        abortAPI(context, http.StatusBadRequest, "Titles need 1 to 120 characters")
+        return
+    }
+
+    chat, err := database.RenameChatForUser(db, uint(chatID), utils.CurrentUserID(context), strings.TrimSpace(input.Title))
+    if err != nil {
+        status, message := chatErrorResponse(err)
+        abortAPI(context, status, message)
+        return
+    }
+
+    if utils.WantsJSON(context) {
+        context.JSON(http.StatusOK, gin.H{"id": chat.ID, "title": chat.Title})
+        return
+    }
+    item := chatListItem(chat)
+    item["selected"] = true
+    context.HTML(http.StatusOK, "chat_list_item", item)
+}
+
+/*
+deleteChat moves a chat to the trash.
 
 It expects the chat ID as a URL parameter.
-If the chat is deleted successfully, it returns a success message.
+If the chat belongs to the current user and is deleted successfully, it responds with 200 and a `chatTrashed` event,
+which reloads the trash.
+If the chat does not exist it responds with 404, and if it belongs to another user with 403.
 
 - Args:
     * `context` (*gin.Context) The Gin context for the current HTTP request.
@@ -160,15 +285,53 @@ If the chat is deleted successfully, it returns a success message.
 func deleteChat(context *gin.Context, db *gorm.DB) {
     chatID, err := strconv.Atoi(context.Param("chat_id"))
     if err != nil {
-        context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+        abortAPI(context, http.StatusBadRequest, "Invalid chat ID")
         return
     }
 
-    if err := database.DeleteChat(db, uint(chatID)); err != nil {
-        context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chat"})
+    if err := database.DeleteChatForUser(db, uint(chatID), utils.CurrentUserID(context)); err != nil {
+        status, message := chatErrorResponse(err)
+        abortAPI(context, status, message)
         return
     }
 
+    context.Header("HX-Trigger", "chatTrashed")
     context.Status(http.StatusOK)
 }
 
+/*
+chatErrorResponse maps an error returned by the owner-scoped chat functions onto an HTTP status and error message.
+
+- Args:
+    * `err` (error) The error returned by the database package.
+
+- Returns:
+    * `status` (int) 404 for a missing chat, message, folder or attachment, 403 for a chat or folder owned by another
+      user, 400 for a message that does not allow the action or unacceptable tags, 409 for restoring a chat that is not
+      in the trash and 500 otherwise.
+    * `message` (string) The error message shown to the client.
+*/
+func chatErrorResponse(err error) (int, string) {
+    switch {
+    case errors.Is(err, database.ErrChatNotFound):
+        return http.StatusNotFound, "Chat not found"
+    case errors.Is(err, database.ErrChatForbidden):
+        return http.StatusForbidden, "You do not have access to this chat"
+    case errors.Is(err, database.ErrMessageNotFound):
+        return http.StatusNotFound, "Message not found"
+    case errors.Is(err, database.ErrMessageType):
+        return http.StatusBadRequest, "This action is not available for this message"
+    case errors.Is(err, database.ErrChatNotInTrash):
+        return http.StatusConflict, "The chat is not in the trash"
+    case errors.Is(err, database.ErrFolderNotFound):
+        return http.StatusNotFound, "Folder not found"
+    case errors.Is(err, database.ErrFolderForbidden):
+        return http.StatusForbidden, "You do not have access to this folder"
+    case errors.Is(err, database.ErrInvalidTags):
+        return http.StatusBadRequest, "Tags need at most 32 characters, and a chat at most 20 tags"
+    case errors.Is(err, database.ErrAttachmentNotFound):
+        return http.StatusNotFound, "Attachment not found"
+    default:
+        return http.StatusInternalServerError, "Failed to access chat"
+    }
+}
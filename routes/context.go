@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"context"
+	"log"
+
+	"gochat/ai"
+	"gochat/attachment"
+	"gochat/database"
+	"gochat/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+fitChatContext fits the history of a chat into the context window of the provider before a reply is generated.
+
+A summary written on the way is saved, so later replies start from it. Failures to load, write or save the summary are
+logged; the reply is then generated from whatever fits.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+	* `chat` (*models.Chat) The chat.
+	* `history` ([]models.Message) The history ending with the user message to answer.
+
+- Returns:
+	(ai.ContextPlan) The messages and settings to send to the provider.
+*/
+func fitChatContext(ctx context.Context, db *gorm.DB, provider ai.Provider, chat *models.Chat, history []models.Message) ai.ContextPlan {
+	plan, err := ai.FitContext(ctx, provider, history, chat.Settings, chatSummary(db, chat.ID, history))
+	if err != nil {
+		log.Println("error", err, "fitChatContext: summarise history, error block 1")
+	}
+
+	if plan.Updated {
+		summary := models.ChatSummary{ChatID: chat.ID, ThroughMessageID: plan.Summary.ThroughID, Content: plan.Summary.Text}
+		if err := database.AddChatSummary(db, &summary); err != nil {
+			log.Println("error", err, "fitChatContext: save summary, error block 2")
+		}
+	}
+	return plan
+}
+
+/*
+chatContextUsage measures how much of the context window the next reply in a chat takes, including the text of
+attached files.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+	* `chat` (*models.Chat) The chat; only its ID and settings are used.
+
+- Returns:
+	* `ai.ContextUsage` The usage.
+	* `string` The summary sent in place of the oldest messages, if there is one.
+*/
+func chatContextUsage(db *gorm.DB, provider ai.Provider, chat *models.Chat) (ai.ContextUsage, string) {
+	path, err := database.GetContextPath(db, chat.ID)
+	if err != nil {
+		log.Println("error", err, "chatContextUsage: load context path")
+		return ai.MeasureContext(provider, nil, chat.Settings, ai.Summary{}), ""
+	}
+
+	summary := ai.Summary{}
+	if path.Summary != nil {
+		summary = ai.Summary{Text: path.Summary.Content, ThroughID: path.Summary.ThroughMessageID}
+	}
+	usage := ai.MeasureContext(provider, attachment.WithText(path.Messages), chat.Settings, summary)
+	usage.Summarized = path.Summarized
+	return usage, summary.Text
+}
+
+/*
+chatSummary loads the summary of a branch of a chat.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `path` ([]models.Message) The messages of the branch in chronological order.
+
+- Returns:
+	(ai.Summary) The summary, or an empty one if there is none or it could not be loaded.
+*/
+func chatSummary(db *gorm.DB, chatID uint, path []models.Message) ai.Summary {
+	summary, err := database.GetChatSummary(db, chatID, path)
+	if err != nil {
+		log.Println("error", err, "chatSummary: load summary")
+	}
+	if summary == nil {
+		return ai.Summary{}
+	}
+	return ai.Summary{Text: summary.Content, ThroughID: summary.ThroughMessageID}
+}
+
+/*
+contextUsageData prepares the context usage for the `context_usage` template.
+
+- Args:
+	* `usage` (ai.ContextUsage) The usage.
+	* `oob` (bool) Whether the indicator replaces the one on the page out of band.
+
+- Returns:
+	(gin.H) The template data.
+*/
+func contextUsageData(usage ai.ContextUsage, oob bool) gin.H {
+	return gin.H{
+		"tokens":     usage.Tokens,
+		"budget":     usage.Budget,
+		"window":     usage.Window,
+		"percent":    usage.Percent(),
+		"full":       usage.Tokens > usage.Budget,
+		"summarized": usage.Summarized,
+		"dropped":    usage.Dropped,
+		"oob":        oob,
+	}
+}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestJSONErrorsUseEnvelope checks that the routes shared with the web interface answer JSON clients with the same
+// error envelope as /api/v1.
+func TestJSONErrorsUseEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	helpers := map[string]func(context *gin.Context, status int, message string){
+		"settingsError":       settingsError,
+		"searchError":         searchError,
+		"promptTemplateError": promptTemplateError,
+		"sidebarError":        sidebarError,
+		"importError":         importError,
+		"authError": func(context *gin.Context, status int, message string) {
+			authError(context, status, "login", message)
+		},
+	}
+	for name, helper := range helpers {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		context.Request.Header.Set("Accept", "application/json")
+
+		helper(context, http.StatusNotFound, "Chat not found")
+
+		var body struct {
+			Error apiError `json:"error"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: body %q is not an error envelope: %v", name, recorder.Body, err)
+			continue
+		}
+		if recorder.Code != http.StatusNotFound || body.Error.Code != "not_found" || body.Error.Message != "Chat not found" {
+			t.Errorf("%s: %d %+v, want 404 not_found", name, recorder.Code, body.Error)
+		}
+	}
+}
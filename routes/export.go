@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gochat/database"
+	"gochat/routes/utils"
+	"gochat/transcript"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportStyles is the Prism theme, relative to the static directory, inlined into HTML exports.
+const exportStyles = "css/prism-atom-dark.css"
+
+/*
+AddExportRoutes adds chat export routes to the Gin router.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+	* `staticDir` (string) The directory of the static files, which holds the styles inlined into HTML exports.
+*/
+func AddExportRoutes(router *gin.Engine, db *gorm.DB, staticDir string) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/chat/:chat_id/export", func(context *gin.Context) { exportChat(context, db, staticDir) })
+}
+
+/*
+exportChat downloads a chat as a file.
+
+The `format` query parameter selects Markdown ("md", the default), JSON ("json") or HTML ("html"). Markdown and HTML
+hold the active branch of the chat; JSON holds every branch.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `staticDir` (string) The directory of the static files.
+
+- Returns:
+	* `file` The exported chat as an attachment.
+	* `error` An error if the format is unknown or the chat cannot be read.
+*/
+func exportChat(context *gin.Context, db *gorm.DB, staticDir string) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	format, err := transcript.ParseFormat(context.DefaultQuery("format", transcript.MarkdownFormat.Name))
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, "Unknown format, use md, json or html")
+		return
+	}
+
+	chat, err := database.GetChatForUser(db, uint(chatID), utils.CurrentUserID(context))
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		abortAPI(context, status, message)
+		return
+	}
+
+	export := &transcript.Transcript{Title: utils.ChatTitle(chat), Chat: chat, ExportedAt: time.Now()}
+	if format == transcript.JSONFormat {
+		if export.Messages, err = database.GetMessageTree(db, chat.ID); err != nil {
+			abortAPI(context, http.StatusInternalServerError, "Failed to read chat")
+			log.Println("error", err, "exportChat: get message tree, error block 1")
+			return
+		}
+	}
+
+	styles := ""
+	if format == transcript.HTMLFormat {
+		// Without the theme the export still works, only code is not colored.
+		if css, err := os.ReadFile(filepath.Join(staticDir, exportStyles)); err == nil {
+			styles = string(css)
+		} else {
+			log.Println("error", err, "exportChat: read styles, error block 2")
+		}
+	}
+
+	file, err := export.Render(format, styles)
+	if err != nil {
+		abortAPI(context, http.StatusInternalServerError, "Failed to export chat")
+		log.Println("error", err, "exportChat: render, error block 3")
+		return
+	}
+
+	context.Header("Content-Disposition", `attachment; filename="`+export.Filename(format)+`"`)
+	context.Data(http.StatusOK, format.ContentType, file)
+}
@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"gochat/database"
+	"gochat/routes/utils"
+	"gochat/transcript"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportSize is the largest export file accepted for import, in bytes.
+const maxImportSize = 100 << 20
+
+/*
+AddImportRoutes adds chat import routes to the Gin router.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+*/
+func AddImportRoutes(router *gin.Engine, db *gorm.DB) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.POST("/chat/import", func(context *gin.Context) { importChats(context, db) })
+}
+
+/*
+importChats imports chats for the current user from a ChatGPT `conversations.json` or a gochat JSON export.
+
+The file is read from the `file` field of a multipart form, or from the request body otherwise. If `dry_run` is set
+in the query or the form, nothing is saved and the report describes what would be created. After a real import HTMX
+clients are sent a `chatsImported` event, which reloads the chat list.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The import report, for JSON clients.
+	* `HTML` The import report as HTML, otherwise.
+	* `error` An error if the file is missing, too large, in an unknown format or could not be saved.
+*/
+func importChats(context *gin.Context, db *gorm.DB) {
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxImportSize)
+
+	data, err := readImportFile(context)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			importError(context, http.StatusRequestEntityTooLarge, "The file is larger than 100 MB")
+			return
+		}
+		importError(context, http.StatusBadRequest, "Choose a file to import")
+		return
+	}
+
+	chats, err := transcript.ParseImport(data)
+	if err != nil {
+		importError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dryRun := isChecked(context.Query("dry_run")) || isChecked(context.PostForm("dry_run"))
+	report, err := database.ImportChatsForUser(db, utils.CurrentUserID(context), chats, dryRun)
+	if err != nil {
+		importError(context, http.StatusUnprocessableEntity, "Import failed: "+err.Error())
+		log.Println("error", err, "importChats: import, error block 1")
+		return
+	}
+
+	if !dryRun && len(report.Chats) > 0 {
+		context.Header("HX-Trigger", "chatsImported")
+	}
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, report)
+		return
+	}
+	context.HTML(http.StatusOK, "import_report", report)
+}
+
+/*
+readImportFile reads the uploaded export file.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	([]byte) The contents of the file, or an error if there is none.
+*/
+func readImportFile(context *gin.Context) ([]byte, error) {
+	if !strings.HasPrefix(context.ContentType(), "multipart/") {
+		data, err := io.ReadAll(context.Request.Body)
+		if err == nil && len(data) == 0 {
+			err = errors.New("empty request body")
+		}
+		return data, err
+	}
+
+	header, err := context.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+/*
+isChecked reports whether a form or query value switches an option on.
+
+- Args:
+	* `value` (string) The value, such as "true", "1" or "on" for a checked checkbox.
+
+- Returns:
+	(bool) True if the option is on.
+*/
+func isChecked(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "on", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+importError responds to a failed import as JSON or with the error template.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message shown to the user.
+*/
+func importError(context *gin.Context, status int, message string) {
+	if utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	context.HTML(status, "error_template", gin.H{"error": message})
+}
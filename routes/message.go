@@ -1,10 +1,17 @@
 package routes
 
 import (
+	"context"
+	"errors"
+	"html"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 
+	"gochat/ai"
+	"gochat/attachment"
 	"gochat/database"
 	"gochat/models"
 	"gochat/routes/utils"
@@ -19,28 +26,52 @@ AddMessageRoutes adds message-related routes to the Gin router.
 - Args:
 	* `router` (*gin.Engine) The Gin router.
 	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider used to answer messages.
+	* `files` (*attachment.Store) The store of the files attached to messages.
 */
-func AddMessageRoutes(router *gin.Engine, db *gorm.DB) {
-	router.POST("/chat/:chat_id/message", func(context *gin.Context) { sendMessage(context, db) })
+func AddMessageRoutes(router *gin.Engine, db *gorm.DB, provider ai.Provider, files *attachment.Store) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.POST("/chat/:chat_id/message", func(context *gin.Context) { sendMessage(context, db, files) })
+	authorized.PUT("/chat/:chat_id/message/:message_id", func(context *gin.Context) { editMessage(context, db) })
+	authorized.POST("/chat/:chat_id/message/:message_id/regenerate", func(context *gin.Context) {
+		regenerateMessage(context, db)
+	})
+	authorized.POST("/chat/:chat_id/message/:message_id/select", func(context *gin.Context) {
+		selectBranch(context, db, provider)
+	})
+	authorized.GET("/chat/:chat_id/message/:message_id/stream", func(context *gin.Context) {
+		streamMessage(context, router, db, provider)
+	})
+}
+
+// messageInput is the form a message is sent or edited with. Everything else about the message, such as the message it
+// follows, is set by the server.
+type messageInput struct {
+	Message string `form:"Message"`
 }
 
 /*
 sendMessage sends a message to the chat with the given chat ID.
 
 It parses the chat ID from the request URL and the message from the request body.
-It adds the message to the database and returns the updated chat history as HTML.
+Files can be attached by sending the form as multipart/form-data with them in the `files` field; they are checked
+against the upload limits and stored before the message is saved.
+A message needs text or at least one file. It follows the end of the active branch.
+It adds the message to the database and returns the user message together with a placeholder for the AI reply as HTML.
+The placeholder connects to the stream endpoint, which generates the reply.
 If there is an error, it returns an error message.
 
 - Args:
 	* `context` (*gin.Context) The Gin context for the current HTTP request.
 	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The store of the files attached to messages.
 
 - Returns:
-	* `HTML` The user and ai message as HTML.
-	* `error` An error if the chat ID is not a valid integer.
+	* `HTML` The user message and the streaming AI message placeholder as HTML.
+	* `error` An error if the chat ID is not a valid integer or the files are not acceptable.
 */
-func sendMessage(context *gin.Context, db *gorm.DB) {
-	userID := 1 // Default user ID
+func sendMessage(context *gin.Context, db *gorm.DB, files *attachment.Store) {
+	userID := utils.CurrentUserID(context)
 	chatID, err := strconv.Atoi(context.Param("chat_id"))
 
 	if err != nil {
@@ -49,78 +80,643 @@ func sendMessage(context *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	var userMessage models.Message
-	if err := context.ShouldBind(&userMessage); err != nil {
-		context.HTML(http.StatusBadRequest, "error_template", gin.H{"error": "Invalid input"})
-		log.Println("error", err, "sendMessage: bind userMessage, error block 2")
+	limitRequestSize(context, files)
+	var input messageInput
+	if err := context.ShouldBind(&input); err != nil {
+		status, message := attachmentErrorResponse(err, files.Limits())
+		if status == http.StatusInternalServerError {
+			status, message = http.StatusBadRequest, "Invalid input"
+		}
+		context.HTML(status, "error_template", gin.H{"error": message})
+		log.Println("error", err, "sendMessage: bind input, error block 2")
+		return
+	}
+	userMessage := models.Message{ChatID: uint(chatID), UserID: userID, Message: input.Message, MessageType: models.UserMessageType}
+
+	userMessage.Attachments, err = saveAttachments(context, files)
+	if err != nil {
+		status, message := attachmentErrorResponse(err, files.Limits())
+		context.HTML(status, "error_template", gin.H{"error": message})
+		log.Println("error", err, "sendMessage: save attachments, error block 3")
+		return
+	}
+	if strings.TrimSpace(input.Message) == "" && len(userMessage.Attachments) == 0 {
+		context.HTML(http.StatusBadRequest, "error_template", gin.H{"error": "The message must not be empty"})
+		return
+	}
+
+	if err := database.AddMessageForUser(db, uint(chatID), userID, &userMessage); err != nil {
+		if err := files.Remove(context.Request.Context(), userMessage.Attachments); err != nil {
+			log.Println("error", err, "sendMessage: remove attachments, error block 4")
+		}
+		status, message := chatErrorResponse(err)
+		context.HTML(status, "error_template", gin.H{"error": message})
+		log.Println("error", err, "sendMessage: add message, error block 5")
+		return
+	}
+
+	context.HTML(http.StatusOK, "message", utils.MessageData(userMessage, chatID, utils.SiblingIDs(db, userMessage.ID)))
+	context.HTML(http.StatusOK, "message_stream", gin.H{
+				"id":     userMessage.ID,
+				"chatID": chatID,
+			})
+}
+
+/*
+replyTracker tracks the AI replies being generated, keyed by the ID of the user message they answer, so only one
+request generates each reply and shutdown can wait for the replies to be saved. It also lets an edit, a regeneration
+or a branch switch claim a chat, so no reply starts while its branch is being replaced.
+
+- Fields:
+	* `mu` (sync.Mutex) Guards the other fields.
+	* `pending` (map[uint]chan struct{}) A channel for every reply being generated, closed when generation ends.
+	* `chats` (map[uint]int) The number of replies being generated in every chat.
+	* `changing` (map[uint]chan struct{}) A channel for every chat claimed by change, closed when the claim is released.
+	* `closing` (bool) Set by wait; no new generation is started afterwards.
+	* `idle` (chan struct{}) Closed when the last pending reply ends while closing, nil until wait needs it.
+*/
+type replyTracker struct {
+	mu       sync.Mutex
+	pending  map[uint]chan struct{}
+	chats    map[uint]int
+	changing map[uint]chan struct{}
+	closing  bool
+	idle     chan struct{}
+}
+
+/*
+newReplyTracker creates an empty reply tracker.
+
+- Returns:
+	(*replyTracker) The tracker.
+*/
+func newReplyTracker() *replyTracker {
+	return &replyTracker{
+		pending:  make(map[uint]chan struct{}),
+		chats:    make(map[uint]int),
+		changing: make(map[uint]chan struct{}),
+	}
+}
+
+// replies tracks the AI replies of the running server.
+var replies = newReplyTracker()
+
+/*
+start claims the generation of the AI reply to a user message, see startReply.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `chatID` (uint) The ID of the chat of the message.
+	* `messageID` (uint) The ID of the user message.
+
+- Returns:
+	* `func()` Releases the claim. Nil if the claim failed.
+	* `bool` True if the caller should generate the reply.
+*/
+func (tracker *replyTracker) start(ctx context.Context, chatID, messageID uint) (func(), bool) {
+	tracker.mu.Lock()
+	// A reply waits for a change of its chat, after which the caller finds out whether the message is still shown.
+	for changing, busy := tracker.changing[chatID]; busy; changing, busy = tracker.changing[chatID] {
+		tracker.mu.Unlock()
+		select {
+		case <-changing:
+		case <-ctx.Done():
+			return nil, false
+		}
+		tracker.mu.Lock()
+	}
+	if tracker.closing {
+		tracker.mu.Unlock()
+		return nil, false
+	}
+	if pending, busy := tracker.pending[messageID]; busy {
+		tracker.mu.Unlock()
+		select {
+		case <-pending:
+		case <-ctx.Done():
+		}
+		return nil, false
+	}
+	generating := make(chan struct{})
+	tracker.pending[messageID] = generating
+	tracker.chats[chatID]++
+	tracker.mu.Unlock()
+
+	return func() {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		delete(tracker.pending, messageID)
+		if tracker.chats[chatID]--; tracker.chats[chatID] == 0 {
+			delete(tracker.chats, chatID)
+		}
+		close(generating)
+		if tracker.idle != nil && len(tracker.pending) == 0 {
+			close(tracker.idle)
+			tracker.idle = nil
+		}
+	}, true
+}
+
+/*
+change claims a chat for a change of its active branch.
+
+The claim is refused while a reply is being generated in the chat or another change holds it. Replies that are
+started while the claim is held wait until it is released.
+
+- Args:
+	* `chatID` (uint) The ID of the chat.
+
+- Returns:
+	* `func()` Releases the claim. Nil if the claim failed.
+	* `bool` True if the chat was claimed.
+*/
+func (tracker *replyTracker) change(chatID uint) (func(), bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if _, busy := tracker.changing[chatID]; busy || tracker.chats[chatID] > 0 || tracker.closing {
+		return nil, false
+	}
+	changing := make(chan struct{})
+	tracker.changing[chatID] = changing
+
+	return func() {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		delete(tracker.changing, chatID)
+		close(changing)
+	}, true
+}
+
+/*
+wait stops new generations and waits until the pending ones have ended.
+
+- Args:
+	* `ctx` (context.Context) Bounds how long to wait.
+
+- Returns:
+	(error) The context error if the replies did not end in time.
+*/
+func (tracker *replyTracker) wait(ctx context.Context) error {
+	tracker.mu.Lock()
+	tracker.closing = true
+	if len(tracker.pending) == 0 {
+		tracker.mu.Unlock()
+		return nil
+	}
+	if tracker.idle == nil {
+		tracker.idle = make(chan struct{})
+	}
+	idle := tracker.idle
+	tracker.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+WaitForReplies waits until every AI reply that is being generated has been saved.
+
+It is used during shutdown, after in-flight requests have been cancelled, so the database is not closed while a
+partial reply is still being written. No reply is generated after it has been called.
+
+- Args:
+	* `ctx` (context.Context) Bounds how long to wait.
+
+- Returns:
+	(error) The context error if the replies did not finish in time.
+*/
+func WaitForReplies(ctx context.Context) error {
+	return replies.wait(ctx)
+}
+
+/*
+streamMessage streams the AI reply to a user message as server-sent events.
+
+It loads the chat history up to the user message given by the `message_id` URL parameter and passes it to the AI provider.
+Each chunk of the reply is sent as a `chunk` event holding escaped text. When the reply is complete, or the client
+disconnects, the text produced so far is saved through SaveAIResponse and a `done` event carries the rendered message.
+After the first reply of an untitled chat a title is generated, and the `done` event also updates the chat in the
+sidebar.
+If the reply was already saved, the `done` event is sent straight away.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `router` (*gin.Engine) The Gin router, used to render the final message.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider used to answer the message.
+
+- Returns:
+	* `SSE` A stream of `chunk` events followed by a `done` event.
+	* `error` An error if the chat or message cannot be found.
+*/
+func streamMessage(context *gin.Context, router *gin.Engine, db *gorm.DB, provider ai.Provider) {
+	userID := utils.CurrentUserID(context)
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	messageID, err := strconv.Atoi(context.Param("message_id"))
+	if err != nil {
+		abortAPI(context, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	// Ownership is checked before the reply is claimed, so another user cannot hold the slot of the owner's reply.
+	if _, err := database.GetChatForUser(db, uint(chatID), userID); err != nil {
+		status, message := chatErrorResponse(err)
+		abortAPI(context, status, message)
+		return
+	}
+
+	// Only one connection generates a reply; a reconnecting client waits for it and then receives the saved reply.
+	finish, generating := startReply(context.Request.Context(), uint(chatID), uint(messageID))
+	if generating {
+		defer finish()
+	} else if context.Request.Context().Err() != nil {
 		return
 	}
-	userMessage.ChatID = uint(chatID)
-	userMessage.UserID = uint(userID)// TODO: Replace with actual user authentication
-	userMessage.MessageType = models.UserMessageType
 
-	if err := database.AddMessage(db, uint(chatID), &userMessage); err != nil {
-		context.HTML(http.StatusInternalServerError, "error_template", gin.H{"error": err.Error()})
-		log.Println("error", err, "sendMessage: add message, error block 3")
+	// The chat is loaded after the claim, so it holds a reply saved while this connection waited.
+	chat, err := database.GetChatForUser(db, uint(chatID), userID)
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		abortAPI(context, status, message)
 		return
 	}
 
-	aiResponse := utils.GetAIResponse()
-	aiMessage, err := utils.SaveAIResponse(db, chatID, userID, aiResponse)
+	history, reply, err := utils.SplitHistoryAt(chat.Messages, uint(messageID))
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		log.Println("error", err, "sendMessage: save AI response, error block 4")
+		abortAPI(context, http.StatusNotFound, err.Error())
+		return
+	}
+
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("X-Accel-Buffering", "no")
+
+	if reply == nil && !generating {
+		sendStreamError(context, router, "Failed to get a response from the AI provider")
 		return
 	}
 
-	context.HTML(http.StatusOK, "message", 
-		gin.H{
-				"id":          userMessage.ID,
-				"message":     userMessage.Message,
-				"messageType": userMessage.MessageType,
-		
+	titled := false
+	if reply == nil {
+		reply, titled, err = generateReply(context.Request.Context(), db, provider, chat, history, func(chunk string) error {
+			context.SSEvent("chunk", streamData(html.EscapeString(chunk)))
+			context.Writer.Flush()
+			return context.Request.Context().Err()
 		})
-	context.HTML(http.StatusOK, "message", gin.H{
-				"id":          aiMessage.ID,
-				"message":     aiMessage.Message,
-				"messageType": aiMessage.MessageType,
-			})
+		if errors.Is(err, errNoReply) {
+			sendStreamError(context, router, "Failed to get a response from the AI provider")
+			return
+		}
+		if err != nil {
+			log.Println("error", err, "streamMessage: save AI response, error block 1")
+			sendStreamError(context, router, "Failed to save the AI response")
+			return
+		}
+	}
+
+	rendered, err := utils.RenderTemplate(router, "message", utils.MessageData(*reply, chatID, utils.SiblingIDs(db, reply.ID)))
+	if err != nil {
+		log.Println("error", err, "streamMessage: render message, error block 2")
+		return
+	}
+
+	// The context indicator now includes the reply.
+	usage, _ := chatContextUsage(db, provider, chat)
+	if indicator, err := utils.RenderTemplate(router, "context_usage", contextUsageData(usage, true)); err == nil {
+		rendered += indicator
+	}
+
+	// A freshly generated title is swapped into the sidebar out of band.
+	if titled {
+		item := chatListItem(chat)
+		item["selected"] = true
+		item["oob"] = true
+		if html, err := utils.RenderTemplate(router, "chat_list_item", item); err == nil {
+			rendered += html
+		}
+	}
+	context.SSEvent("done", streamData(rendered))
+	context.Writer.Flush()
 }
 
+// errNoReply is returned by generateReply when the AI provider produced no text at all.
+var errNoReply = errors.New("the AI provider did not reply")
+
+/*
+startReply claims the generation of the AI reply to a user message.
+
+Only one request generates a reply at a time. A request that finds the reply already being generated waits until
+that generation ends, or until its own context is done, and is not allowed to generate. A reply in a chat claimed by
+claimChat waits for the claim to be released. Once the server is shutting down no request is allowed to generate.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `chatID` (uint) The ID of the chat of the message.
+	* `messageID` (uint) The ID of the user message.
 
-// func editMessage(context *gin.Context, db *gorm.DB) {
-// 	messageID, err := strconv.Atoi(context.Param("message_id"))
-// 	if err != nil {
-// 		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
-// 		return
-// 	}
-
-// 	chatID, err := strconv.Atoi(context.Param("chat_id"))
-// 	if err != nil {
-// 		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
-// 		return
-// 	}
-
-// 	newMessage, err := bindMessage(context)
-// 	if err != nil {
-// 		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-// 		return
-// 	}
-
-// 	if err := database.UpdateMessage(db, uint(chatID), uint(messageID), newMessage.Message); err != nil {
-// 		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-// 		return
-// 	}
-
-// 	context.JSON(http.StatusOK, gin.H{
-// 		"status":     "message edited",
-// 		"messageID":  messageID,
-// 		"newMessage": newMessage.Message,
-// 	})
-// }
-
-// func bindMessage(context *gin.Context) (models.Message, error) {
-// 	var message models.Message
-// 	err := context.BindJSON(&message)
-// 	return message, err
-// }
\ No newline at end of file
+- Returns:
+	* `func()` Releases the claim; it must be called when the reply has been saved. Nil if the claim failed.
+	* `bool` True if the caller should generate the reply.
+*/
+func startReply(ctx context.Context, chatID, messageID uint) (func(), bool) {
+	return replies.start(ctx, chatID, messageID)
+}
+
+/*
+generateReply asks the AI provider for a reply to the last message of a history and saves it.
+
+The text of attached files is added to their messages and the history is fitted into the context window of the
+provider, which may summarise older messages. If the context ends while the reply is produced, the text received so
+far is saved. After the first reply of an untitled chat a title is generated for the chat.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+	* `chat` (*models.Chat) The chat; its title is updated if one is generated.
+	* `history` ([]models.Message) The history ending with the user message to answer.
+	* `emit` (func(chunk string) error) Receives the reply as it is produced; an error stops the provider.
+
+- Returns:
+	* `*models.Message` The saved reply.
+	* `bool` True if a title was generated for the chat.
+	* `error` errNoReply if the provider produced nothing, or an error if the reply could not be saved.
+*/
+func generateReply(ctx context.Context, db *gorm.DB, provider ai.Provider, chat *models.Chat, history []models.Message, emit func(chunk string) error) (*models.Message, bool, error) {
+	prompt := history[len(history)-1]
+	plan := fitChatContext(ctx, db, provider, chat, attachment.WithText(history))
+	aiResponse, err := ai.Stream(ctx, provider, plan.History, plan.Settings, emit)
+	if err != nil {
+		log.Println("error", err, "generateReply: AI provider")
+	}
+	if aiResponse == "" {
+		return nil, false, errNoReply
+	}
+
+	reply, err := utils.SaveAIResponse(db, int(chat.ID), chat.UserID, prompt.ID, aiResponse)
+	if err != nil {
+		return nil, false, err
+	}
+
+	titled := false
+	if chat.Title == "" && len(history) == 1 {
+		chat.Title = ai.GenerateTitle(ctx, provider, history[0].Message)
+		if titled, err = database.SetChatTitleIfEmpty(db, chat.ID, chat.Title); err != nil {
+			log.Println("error", err, "generateReply: save chat title")
+		}
+	}
+	return reply, titled, nil
+}
+
+/*
+streamData prepares HTML for use as the data of a server-sent event.
+
+A space at the start of a line is written as a character reference because event stream parsers strip one leading
+space from every data line, which would otherwise eat indentation and the spaces between streamed words.
+
+- Args:
+	* `fragment` (string) The HTML fragment.
+
+- Returns:
+	(string) The fragment with leading spaces protected.
+*/
+func streamData(fragment string) string {
+	fragment = strings.ReplaceAll(fragment, "\n ", "\n&#32;")
+	if strings.HasPrefix(fragment, " ") {
+		fragment = "&#32;" + fragment[1:]
+	}
+	return fragment
+}
+
+/*
+sendStreamError ends a reply stream with a `done` event carrying a rendered error message.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `router` (*gin.Engine) The Gin router, used to render the error.
+	* `message` (string) The error message shown to the user.
+*/
+func sendStreamError(context *gin.Context, router *gin.Engine, message string) {
+	rendered, err := utils.RenderTemplate(router, "error_template", gin.H{"error": message})
+	if err != nil {
+		log.Println("error", err, "sendStreamError: render error")
+		return
+	}
+	context.SSEvent("done", streamData(rendered))
+	context.Writer.Flush()
+}
+
+
+/*
+editMessage changes the text of a user message and requests a new AI reply to it.
+
+The new text is saved as a sibling of the original message, which keeps the original branch. The response replaces
+the edited message with the new version followed by a placeholder that streams the new reply, and removes the
+messages of the original branch out of band.
+Editing is refused while a reply in the chat is still being generated.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `HTML` The edited message, the streaming AI message placeholder and the removals as HTML.
+	* `error` An error if the input is invalid or the message cannot be edited.
+*/
+func editMessage(context *gin.Context, db *gorm.DB) {
+	userID := utils.CurrentUserID(context)
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		messageError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	messageID, err := strconv.Atoi(context.Param("message_id"))
+	if err != nil {
+		messageError(context, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var input messageInput
+	if err := context.ShouldBind(&input); err != nil || strings.TrimSpace(input.Message) == "" {
+		messageError(context, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	release, status, message := claimChat(db, uint(chatID), userID)
+	if status != http.StatusOK {
+		messageError(context, status, message)
+		return
+	}
+
+	edited, removed, err := database.EditMessageForUser(db, uint(chatID), userID, uint(messageID), input.Message)
+	release()
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		messageError(context, status, message)
+		log.Println("error", err, "editMessage: update message, error block 1")
+		return
+	}
+
+	context.HTML(http.StatusOK, "message", utils.MessageData(*edited, chatID, utils.SiblingIDs(db, edited.ID)))
+	context.HTML(http.StatusOK, "message_stream", gin.H{
+		"id":     edited.ID,
+		"chatID": chatID,
+	})
+	for _, id := range removed {
+		context.HTML(http.StatusOK, "message_removed", gin.H{"id": id})
+	}
+}
+
+/*
+regenerateMessage generates another AI reply to the user message an existing reply answered.
+
+The existing reply is kept as a sibling of the new one. The response replaces the reply with a placeholder that
+streams the new reply, and removes the messages that followed the old reply out of band.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `HTML` The streaming AI message placeholder and the removals as HTML.
+	* `error` An error if the message is not an AI reply to a user message.
+*/
+func regenerateMessage(context *gin.Context, db *gorm.DB) {
+	userID := utils.CurrentUserID(context)
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		messageError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	replyID, err := strconv.Atoi(context.Param("message_id"))
+	if err != nil {
+		messageError(context, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	release, status, message := claimChat(db, uint(chatID), userID)
+	if status != http.StatusOK {
+		messageError(context, status, message)
+		return
+	}
+
+	prompt, removed, err := database.RegenerateReplyForUser(db, uint(chatID), userID, uint(replyID))
+	release()
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		messageError(context, status, message)
+		log.Println("error", err, "regenerateMessage: move active branch, error block 1")
+		return
+	}
+
+	context.HTML(http.StatusOK, "message_stream", gin.H{
+		"id":     prompt.ID,
+		"chatID": chatID,
+	})
+	for _, id := range removed {
+		// The reply itself is replaced by the placeholder.
+		if id != uint(replyID) {
+			context.HTML(http.StatusOK, "message_removed", gin.H{"id": id})
+		}
+	}
+}
+
+/*
+selectBranch shows another branch of a chat.
+
+The branch through the given message becomes the active branch, and the chat is rendered again as by getChatHistory.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `provider` (ai.Provider) The AI provider.
+
+- Returns:
+	* `HTML` The chat window and input form as HTML.
+	* `error` An error if the message is not part of the chat.
+*/
+func selectBranch(context *gin.Context, db *gorm.DB, provider ai.Provider) {
+	userID := utils.CurrentUserID(context)
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		messageError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	messageID, err := strconv.Atoi(context.Param("message_id"))
+	if err != nil {
+		messageError(context, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	release, status, message := claimChat(db, uint(chatID), userID)
+	if status != http.StatusOK {
+		messageError(context, status, message)
+		return
+	}
+
+	err = database.SelectBranchForUser(db, uint(chatID), userID, uint(messageID))
+	release()
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		messageError(context, status, message)
+		log.Println("error", err, "selectBranch: select branch, error block 1")
+		return
+	}
+
+	getChatHistory(context, db, provider)
+}
+
+/*
+claimChat claims a chat for a change of its active branch, see replyTracker.change.
+
+The change replaces part of the branch, and a reply that is still being generated would be added to a branch that is
+no longer shown, so the change is refused while a reply is generated and no reply starts until the claim is released.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chatID` (uint) The ID of the chat.
+	* `userID` (uint) The ID of the current user.
+
+- Returns:
+	* `release` (func()) Releases the claim; it must be called once the branch has changed. Nil if the claim failed.
+	* `status` (int) 200 if the chat was claimed, 409 if a reply is pending, or the status of the chat lookup error.
+	* `message` (string) The error message shown to the client.
+*/
+func claimChat(db *gorm.DB, chatID, userID uint) (func(), int, string) {
+	if _, err := database.GetChatForUser(db, chatID, userID); err != nil {
+		status, message := chatErrorResponse(err)
+		return nil, status, message
+	}
+	release, claimed := replies.change(chatID)
+	if !claimed {
+		return nil, http.StatusConflict, "Wait for the current reply to finish"
+	}
+	return release, http.StatusOK, ""
+}
+
+/*
+messageError renders an error for a request made from a message.
+
+HTMX is told to add the error to the end of the target, usually the message, instead of replacing it.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message shown to the user.
+*/
+func messageError(context *gin.Context, status int, message string) {
+	context.Header("HX-Reswap", "beforeend")
+	context.HTML(status, "error_template", gin.H{"error": message})
+}
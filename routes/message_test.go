@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplyTracker(t *testing.T) {
+	tracker := newReplyTracker()
+
+	finish, generating := tracker.start(context.Background(), 10, 1)
+	if !generating {
+		t.Fatal("the first claim of a reply was refused")
+	}
+	if _, claimed := tracker.change(10); claimed {
+		t.Error("a chat was claimed for a change while a reply was generated in it")
+	}
+
+	// A second request for the same reply waits for the first one and may not generate.
+	waited := make(chan bool)
+	go func() {
+		_, generating := tracker.start(context.Background(), 10, 1)
+		waited <- generating
+	}()
+
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tracker.wait(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait with a pending reply = %v, want DeadlineExceeded", err)
+	}
+	if _, generating := tracker.start(context.Background(), 10, 2); generating {
+		t.Error("a reply was claimed after wait was called")
+	}
+
+	finish()
+	if <-waited {
+		t.Error("the waiting request was allowed to generate the reply")
+	}
+	if err := tracker.wait(context.Background()); err != nil {
+		t.Errorf("wait after the last reply ended = %v", err)
+	}
+}
+
+func TestReplyTrackerWaitWakesUp(t *testing.T) {
+	tracker := newReplyTracker()
+	finish, _ := tracker.start(context.Background(), 10, 1)
+	time.AfterFunc(10*time.Millisecond, finish)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracker.wait(ctx); err != nil {
+		t.Errorf("wait = %v, want it to return once the reply ends", err)
+	}
+}
+
+func TestReplyTrackerChange(t *testing.T) {
+	tracker := newReplyTracker()
+	release, claimed := tracker.change(10)
+	if !claimed {
+		t.Fatal("an idle chat could not be claimed")
+	}
+	if _, claimed := tracker.change(10); claimed {
+		t.Error("a chat was claimed twice")
+	}
+	if finish, generating := tracker.start(context.Background(), 11, 1); !generating {
+		t.Error("a reply in another chat waited for the claim")
+	} else {
+		finish()
+	}
+
+	// A reply in the claimed chat starts only once the change is done.
+	started := make(chan func())
+	go func() {
+		finish, _ := tracker.start(context.Background(), 10, 2)
+		started <- finish
+	}()
+	select {
+	case <-started:
+		t.Fatal("a reply started while its chat was claimed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	finish := <-started
+	if finish == nil {
+		t.Fatal("the reply was not allowed to start after the claim was released")
+	}
+	if _, claimed := tracker.change(10); claimed {
+		t.Error("a chat was claimed while a reply was generated in it")
+	}
+	finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release, _ = tracker.change(10)
+	defer release()
+	cancel()
+	if _, generating := tracker.start(ctx, 10, 3); generating {
+		t.Error("a cancelled request started a reply in a claimed chat")
+	}
+}
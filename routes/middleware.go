@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loginPath is where unauthenticated browser requests are sent.
+const loginPath = "/user/login"
+
+/*
+RequireAuth returns a middleware that resolves the current user and rejects requests without one.
+
+The user is taken from the session cookie or, for API clients, from an `Authorization: Bearer <token>` header, and is
+stored in the context for utils.CurrentUser.
+Unauthenticated requests to the versioned API and from other API clients get a 401 error envelope, HTMX requests get
+a 401 with an `HX-Redirect` to the login page and other browser requests are redirected to the login page.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(gin.HandlerFunc) The middleware.
+*/
+func RequireAuth(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if user := authenticate(context, db); user != nil {
+			utils.SetCurrentUser(context, user)
+			context.Next()
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(context.Request.URL.Path, apiPrefix) || utils.WantsJSON(context) || context.GetHeader("Authorization") != "":
+			abortAPI(context, http.StatusUnauthorized, "Authentication required")
+		case utils.IsHTMX(context):
+			context.Header("HX-Redirect", loginPath)
+			context.AbortWithStatus(http.StatusUnauthorized)
+		default:
+			context.Redirect(http.StatusSeeOther, loginPath)
+			context.Abort()
+		}
+	}
+}
+
+/*
+authenticate resolves the user making the request.
+
+A bearer token takes precedence over the session. A session pointing at a user that no longer exists is cleared.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	(*models.User) The authenticated user, or nil if the request is not authenticated.
+*/
+func authenticate(context *gin.Context, db *gorm.DB) *models.User {
+	if token, ok := strings.CutPrefix(context.GetHeader("Authorization"), "Bearer "); ok {
+		user, err := database.GetUserByAPIToken(db, strings.TrimSpace(token))
+		if err != nil {
+			return nil
+		}
+		return user
+	}
+
+	session := sessions.Default(context)
+	userID, ok := session.Get("userID").(uint)
+	if !ok {
+		return nil
+	}
+
+	user, err := database.GetUser(db, userID)
+	if err != nil {
+		session.Clear()
+		session.Save()
+		return nil
+	}
+	return user
+}
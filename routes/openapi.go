@@ -0,0 +1,88 @@
+package routes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the OpenAPI document describing every route registered by SetupRouter.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// ginParam matches the `:name` and `*name` parameters of a gin route path.
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+/*
+AddOpenAPIRoutes serves the OpenAPI document at `/api/openapi.json`.
+
+The document is public, so clients can discover the API before they authenticate.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+*/
+func AddOpenAPIRoutes(router *gin.Engine) {
+	router.GET("/api/openapi.json", func(context *gin.Context) {
+		context.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+	})
+}
+
+/*
+OpenAPISpec returns the OpenAPI document describing the routes registered by SetupRouter.
+
+- Returns:
+	([]byte) The document as JSON.
+*/
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+/*
+CheckOpenAPISpec compares the OpenAPI document with the routes of a router.
+
+Gin path parameters are translated to their OpenAPI form, so `/chat/:chat_id` matches `/chat/{chat_id}`.
+
+- Args:
+	* `routes` (gin.RoutesInfo) The routes registered on the router.
+
+- Returns:
+	* `[]string` One line per route that is registered but not documented, or documented but not registered, sorted.
+	* `error` An error if the document cannot be parsed.
+*/
+func CheckOpenAPISpec(routes gin.RoutesInfo) ([]string, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI document: %w", err)
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var problems []string
+	for _, route := range routes {
+		key := route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		if documented[key] {
+			delete(documented, key)
+			continue
+		}
+		problems = append(problems, key+" is registered but not documented")
+	}
+	for key := range documented {
+		problems = append(problems, key+" is documented but not registered")
+	}
+	slices.Sort(problems)
+	return problems, nil
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"testing"
+
+	"gochat/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Session.Secret = "0123456789abcdef0123456789abcdef"
+
+	problems, err := CheckOpenAPISpec(SetupRouter(nil, nil, nil, cfg).Routes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
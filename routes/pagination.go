@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"gochat/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultChatPageSize is the number of chats per page of the chat list.
+	defaultChatPageSize = 30
+	// defaultMessagePageSize is the number of messages per page of a chat.
+	defaultMessagePageSize = 50
+	// maxPageSize is the largest page a client may ask for.
+	maxPageSize = 200
+)
+
+// invalidPageMessage is the error message shown for invalid pagination parameters.
+const invalidPageMessage = "Invalid limit or before parameter"
+
+var (
+	// errInvalidLimit is returned when the `limit` query parameter is not a positive number.
+	errInvalidLimit = errors.New("invalid limit")
+	// errInvalidBefore is returned when the `before` query parameter is not a valid cursor.
+	errInvalidBefore = errors.New("invalid before cursor")
+)
+
+/*
+pageLimit reads the page size from the `limit` query parameter.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `fallback` (int) The page size used when the parameter is missing.
+
+- Returns:
+	(int) The page size, at most maxPageSize, or errInvalidLimit if the parameter is not a positive number.
+*/
+func pageLimit(context *gin.Context, fallback int) (int, error) {
+	value := context.Query("limit")
+	if value == "" {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, errInvalidLimit
+	}
+	return min(limit, maxPageSize), nil
+}
+
+/*
+chatPage reads the page of the chat list a request asks for.
+
+The `before` query parameter holds the cursor returned with the previous page.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `*database.ChatCursor` The cursor, or nil for the first page.
+	* `int` The page size.
+	* `error` errInvalidBefore or errInvalidLimit if a parameter is invalid.
+*/
+func chatPage(context *gin.Context) (*database.ChatCursor, int, error) {
+	limit, err := pageLimit(context, defaultChatPageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	value := context.Query("before")
+	if value == "" {
+		return nil, limit, nil
+	}
+	cursor, err := database.ParseChatCursor(value)
+	if err != nil {
+		return nil, 0, errInvalidBefore
+	}
+	return &cursor, limit, nil
+}
+
+/*
+messagePage reads the page of a chat's messages a request asks for.
+
+The `before` query parameter holds the ID of the oldest message the client already has.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	(database.MessagePage) The page, or errInvalidBefore or errInvalidLimit if a parameter is invalid.
+*/
+func messagePage(context *gin.Context) (database.MessagePage, error) {
+	limit, err := pageLimit(context, defaultMessagePageSize)
+	if err != nil {
+		return database.MessagePage{}, err
+	}
+
+	page := database.MessagePage{Limit: limit}
+	if value := context.Query("before"); value != "" {
+		before, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || before == 0 {
+			return database.MessagePage{}, errInvalidBefore
+		}
+		page.Before = uint(before)
+	}
+	return page, nil
+}
+
+/*
+pageURL builds the URL of another page of a paginated route.
+
+- Args:
+	* `path` (string) The path of the route.
+	* `filter` (url.Values) Further query parameters that select what is listed, or nil for none.
+	* `before` (string) The cursor of the page, or an empty string if there is no further page.
+	* `limit` (int) The page size.
+
+- Returns:
+	(string) The URL, or an empty string if there is no further page.
+*/
+func pageURL(path string, filter url.Values, before string, limit int) string {
+	if before == "" {
+		return ""
+	}
+	query := url.Values{"before": {before}, "limit": {strconv.Itoa(limit)}}
+	for name, values := range filter {
+		query[name] = values
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,453 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gochat/database"
+	"gochat/models"
+	"gochat/prompt"
+	"gochat/routes/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// promptTemplateInput is the form or JSON body that creates or changes a prompt template.
+type promptTemplateInput struct {
+	Name        string `form:"name" json:"name" binding:"required,max=80"`
+	Description string `form:"description" json:"description" binding:"max=200"`
+	Body        string `form:"body" json:"body" binding:"required,max=20000"`
+	Shared      bool   `form:"shared" json:"shared"`
+}
+
+// promptTemplateView is a prompt template as JSON clients and the picker see it.
+type promptTemplateView struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Body        string    `json:"body"`
+	Shared      bool      `json:"shared"`
+	Owner       string    `json:"owner"`
+	Owned       bool      `json:"owned"`
+	Variables   []string  `json:"variables"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+/*
+AddPromptTemplateRoutes adds the prompt template library routes to the Gin router.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+*/
+func AddPromptTemplateRoutes(router *gin.Engine, db *gorm.DB) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/templates", func(context *gin.Context) { getPromptTemplates(context, db) })
+	authorized.POST("/templates", func(context *gin.Context) { createPromptTemplate(context, db) })
+	authorized.GET("/templates/:template_id", func(context *gin.Context) { getPromptTemplate(context, db) })
+	authorized.PUT("/templates/:template_id", func(context *gin.Context) { updatePromptTemplate(context, db) })
+	authorized.DELETE("/templates/:template_id", func(context *gin.Context) { deletePromptTemplate(context, db) })
+	authorized.POST("/templates/:template_id/render", func(context *gin.Context) { renderPromptTemplate(context, db) })
+}
+
+/*
+getPromptTemplates lists the prompt templates of the current user and those shared by other users.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"templates": [...]}, for JSON clients.
+	* `HTML` The `template_picker` partial, otherwise.
+*/
+func getPromptTemplates(context *gin.Context, db *gorm.DB) {
+	respondPromptTemplates(context, db, http.StatusOK)
+}
+
+/*
+createPromptTemplate adds a prompt template to the library of the current user.
+
+The body must be a valid Go text/template; its fields become the variables of the template.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The new template with status 201, for JSON clients.
+	* `HTML` The `template_picker` partial, otherwise.
+*/
+func createPromptTemplate(context *gin.Context, db *gorm.DB) {
+	input, ok := bindPromptTemplate(context)
+	if !ok {
+		return
+	}
+
+	user := utils.CurrentUser(context)
+	template := models.PromptTemplate{
+		UserID:      user.ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Body:        input.Body,
+		Shared:      input.Shared,
+		User:        *user,
+	}
+	if err := database.AddPromptTemplate(db, &template); err != nil {
+		promptTemplateError(context, http.StatusInternalServerError, "Failed to save the template")
+		log.Println("error", err, "createPromptTemplate: add template, error block 1")
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusCreated, newPromptTemplateView(&template, user.ID))
+		return
+	}
+	respondPromptTemplates(context, db, http.StatusOK)
+}
+
+/*
+getPromptTemplate returns a prompt template the current user owns or that is shared.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The template, for JSON clients.
+	* `HTML` The `template_editor` form, otherwise; only the owner may edit a template.
+*/
+func getPromptTemplate(context *gin.Context, db *gorm.DB) {
+	templateID, err := strconv.Atoi(context.Param("template_id"))
+	if err != nil {
+		promptTemplateError(context, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+
+	userID := utils.CurrentUserID(context)
+	template, err := database.GetPromptTemplateForUser(db, uint(templateID), userID)
+	if err != nil {
+		promptTemplateFailure(context, err)
+		return
+	}
+
+	view := newPromptTemplateView(template, userID)
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, view)
+		return
+	}
+	if !view.Owned {
+		promptTemplateFailure(context, database.ErrPromptTemplateForbidden)
+		return
+	}
+	context.HTML(http.StatusOK, "template_editor", view)
+}
+
+/*
+updatePromptTemplate changes a prompt template of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The updated template, for JSON clients.
+	* `HTML` The `template_picker` partial, otherwise.
+*/
+func updatePromptTemplate(context *gin.Context, db *gorm.DB) {
+	templateID, err := strconv.Atoi(context.Param("template_id"))
+	if err != nil {
+		promptTemplateError(context, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+	input, ok := bindPromptTemplate(context)
+	if !ok {
+		return
+	}
+
+	userID := utils.CurrentUserID(context)
+	template, err := database.UpdatePromptTemplateForUser(db, uint(templateID), userID, models.PromptTemplate{
+		Name:        input.Name,
+		Description: input.Description,
+		Body:        input.Body,
+		Shared:      input.Shared,
+	})
+	if err != nil {
+		promptTemplateFailure(context, err)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, newPromptTemplateView(template, userID))
+		return
+	}
+	respondPromptTemplates(context, db, http.StatusOK)
+}
+
+/*
+deletePromptTemplate deletes a prompt template of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `204` No content, for JSON clients.
+	* `HTML` The `template_picker` partial, otherwise.
+*/
+func deletePromptTemplate(context *gin.Context, db *gorm.DB) {
+	templateID, err := strconv.Atoi(context.Param("template_id"))
+	if err != nil {
+		promptTemplateError(context, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+
+	if err := database.DeletePromptTemplateForUser(db, uint(templateID), utils.CurrentUserID(context)); err != nil {
+		promptTemplateFailure(context, err)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.Status(http.StatusNoContent)
+		return
+	}
+	respondPromptTemplates(context, db, http.StatusOK)
+}
+
+/*
+renderPromptTemplate expands a prompt template with the values of its variables.
+
+The values are read from the JSON property `variables` or from the form fields `vars[name]`. The `target` decides
+what happens with the text:
+	* `message` (the default) returns it; HTMX requests get the message textarea filled with it, swapped out of band.
+	* `chat` creates a chat that uses it as system prompt, with the user's other default settings; HTMX requests get
+	  the new chat window and a `chatCreated` event that refreshes the chat list.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` {"text": "..."} and, for the chat target, "chat" with status 201, for JSON clients.
+	* `HTML` The message textarea or the new chat window, otherwise.
+*/
+func renderPromptTemplate(context *gin.Context, db *gorm.DB) {
+	templateID, err := strconv.Atoi(context.Param("template_id"))
+	if err != nil {
+		promptTemplateError(context, http.StatusBadRequest, "Invalid template ID")
+		return
+	}
+
+	var input struct {
+		Variables map[string]string `json:"variables"`
+		Target    string            `json:"target"`
+	}
+	if context.ContentType() == gin.MIMEJSON {
+		if err := context.ShouldBindJSON(&input); err != nil {
+			promptTemplateError(context, http.StatusBadRequest, "Invalid input")
+			return
+		}
+	} else {
+		input.Variables = context.PostFormMap("vars")
+		input.Target = context.PostForm("target")
+	}
+
+	user := utils.CurrentUser(context)
+	template, err := database.GetPromptTemplateForUser(db, uint(templateID), user.ID)
+	if err != nil {
+		promptTemplateFailure(context, err)
+		return
+	}
+
+	text, err := prompt.Render(template.Body, input.Variables)
+	if err != nil {
+		promptTemplateError(context, http.StatusBadRequest, "The template could not be expanded: "+err.Error())
+		return
+	}
+
+	switch input.Target {
+	case "", "message":
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusOK, gin.H{"text": text})
+			return
+		}
+		context.HTML(http.StatusOK, "message_input", gin.H{"message": text, "oob": true})
+	case "chat":
+		settings := user.DefaultSettings
+		settings.SystemPrompt = text
+		if err := validateSettings(settings); err != nil {
+			promptTemplateError(context, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		chat := models.Chat{UserID: user.ID, Settings: settings}
+		if err := database.AddChat(db, &chat); err != nil {
+			promptTemplateError(context, http.StatusInternalServerError, "Failed to create chat")
+			log.Println("error", err, "renderPromptTemplate: add chat, error block 1")
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusCreated, gin.H{"text": text, "chat": newAPIChat(&chat)})
+			return
+		}
+		context.Header("HX-Trigger", "chatCreated")
+		context.Header("HX-Retarget", "#messages")
+		context.Header("HX-Reswap", "innerHTML")
+		context.HTML(http.StatusOK, "chat_window", gin.H{"messages": []gin.H{}, "chatID": chat.ID})
+		context.HTML(http.StatusOK, "input_form", gin.H{"chatID": chat.ID})
+	default:
+		promptTemplateError(context, http.StatusBadRequest, "The target must be message or chat")
+	}
+}
+
+/*
+bindPromptTemplate reads and validates the fields of a prompt template, responding with an error if they are invalid.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `promptTemplateInput` The fields with surrounding whitespace removed from the name and description.
+	* `bool` False if an error response was sent.
+*/
+func bindPromptTemplate(context *gin.Context) (promptTemplateInput, bool) {
+	var input promptTemplateInput
+	if err := context.ShouldBind(&input); err != nil {
+		promptTemplateError(context, http.StatusBadRequest,
+			"Templates need a name of up to 80 characters and a body of up to 20000")
+		return input, false
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.Description = strings.TrimSpace(input.Description)
+	if input.Name == "" || strings.TrimSpace(input.Body) == "" {
+		promptTemplateError(context, http.StatusBadRequest, "Templates need a name and a body")
+		return input, false
+	}
+	if _, err := prompt.Parse(input.Body); err != nil {
+		promptTemplateError(context, http.StatusBadRequest, "The template is invalid: "+err.Error())
+		return input, false
+	}
+	return input, true
+}
+
+/*
+respondPromptTemplates sends the prompt templates the current user can use, as JSON or as the `template_picker`
+partial.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `status` (int) The HTTP status code.
+*/
+func respondPromptTemplates(context *gin.Context, db *gorm.DB, status int) {
+	userID := utils.CurrentUserID(context)
+	templates, err := database.GetPromptTemplatesForUser(db, userID)
+	if err != nil {
+		promptTemplateError(context, http.StatusInternalServerError, "Failed to retrieve templates")
+		log.Println("error", err, "respondPromptTemplates: get templates, error block 1")
+		return
+	}
+
+	views := make([]promptTemplateView, len(templates))
+	for i := range templates {
+		views[i] = newPromptTemplateView(&templates[i], userID)
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(status, gin.H{"templates": views})
+		return
+	}
+	context.HTML(status, "template_picker", gin.H{"templates": views, "new": promptTemplateView{}})
+}
+
+/*
+newPromptTemplateView converts a prompt template into the form JSON clients and the picker see.
+
+- Args:
+	* `template` (*models.PromptTemplate) The template with its owner loaded.
+	* `userID` (uint) The ID of the current user.
+
+- Returns:
+	(promptTemplateView) The view of the template.
+*/
+func newPromptTemplateView(template *models.PromptTemplate, userID uint) promptTemplateView {
+	variables, err := prompt.Parse(template.Body)
+	if err != nil || variables == nil {
+		variables = []string{}
+	}
+	return promptTemplateView{
+		ID:          template.ID,
+		Name:        template.Name,
+		Description: template.Description,
+		Body:        template.Body,
+		Shared:      template.Shared,
+		Owner:       template.User.Username,
+		Owned:       template.UserID == userID,
+		Variables:   variables,
+		CreatedAt:   template.CreatedAt,
+		UpdatedAt:   template.UpdatedAt,
+	}
+}
+
+/*
+promptTemplateErrorResponse maps an error returned by the prompt template functions onto an HTTP status and error
+message.
+
+- Args:
+	* `err` (error) The error returned by the database package.
+
+- Returns:
+	* `status` (int) 404 for a template that is not visible, 403 for a template shared by another user and 500
+	  otherwise.
+	* `message` (string) The error message shown to the client.
+*/
+func promptTemplateErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, database.ErrPromptTemplateNotFound):
+		return http.StatusNotFound, "Template not found"
+	case errors.Is(err, database.ErrPromptTemplateForbidden):
+		return http.StatusForbidden, "Only the owner can change this template"
+	default:
+		log.Println("error", err, "promptTemplateErrorResponse: unexpected error")
+		return http.StatusInternalServerError, "Failed to process the template"
+	}
+}
+
+/*
+promptTemplateError responds with an error as JSON for API clients and otherwise as an error message shown at the
+top of the template picker.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message.
+*/
+func promptTemplateError(context *gin.Context, status int, message string) {
+	if utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	context.Header("HX-Retarget", "#template-picker-error")
+	context.Header("HX-Reswap", "innerHTML")
+	context.HTML(status, "error_template", gin.H{"error": message})
+}
+
+/*
+promptTemplateFailure responds with the error for an error returned by the prompt template functions.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `err` (error) The error returned by the database package.
+*/
+func promptTemplateFailure(context *gin.Context, err error) {
+	status, message := promptTemplateErrorResponse(err)
+	promptTemplateError(context, status, message)
+}
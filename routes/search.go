@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultSearchLimit is the number of hits returned when the request does not ask for a number.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the largest number of hits a request may ask for.
+	maxSearchLimit = 100
+)
+
+/*
+AddSearchRoutes adds search-related routes to the Gin router.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+*/
+func AddSearchRoutes(router *gin.Engine, db *gorm.DB) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/search", func(context *gin.Context) { searchMessages(context, db) })
+}
+
+/*
+searchMessages searches the messages of all chats of the current user.
+
+The words to search for are read from the `q` query parameter and the number of hits from `limit`. JSON clients get
+the ranked hits; HTMX requests get the `search_results` partial, whose hits open the chat scrolled to the message.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The query and its hits, for JSON clients.
+	* `HTML` The hits as list items, otherwise.
+	* `error` An error if the limit is invalid or the search failed.
+*/
+func searchMessages(context *gin.Context, db *gorm.DB) {
+	query := strings.TrimSpace(context.Query("q"))
+
+	limit := defaultSearchLimit
+	if value := context.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			searchError(context, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+
+	hits, err := database.SearchMessagesForUser(db, utils.CurrentUserID(context), query, limit)
+	if err != nil {
+		searchError(context, http.StatusInternalServerError, "Search failed")
+		log.Println("error", err, "searchMessages: search, error block 1")
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, gin.H{"query": query, "hits": hits})
+		return
+	}
+
+	results := make([]gin.H, len(hits))
+	for i, hit := range hits {
+		chat := models.Chat{Title: hit.ChatTitle}
+		chat.ID = hit.ChatID
+		results[i] = gin.H{
+			"chatID":      hit.ChatID,
+			"messageID":   hit.MessageID,
+			"messageType": hit.MessageType,
+			"title":       utils.ChatTitle(&chat),
+			// The snippet is escaped by the database package, apart from the <mark> elements it adds.
+			"snippet": template.HTML(hit.Snippet),
+		}
+	}
+	context.HTML(http.StatusOK, "search_results", gin.H{"query": query, "hits": results})
+}
+
+/*
+searchError responds to a failed search as JSON or with the error template.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message shown to the user.
+*/
+func searchError(context *gin.Context, status int, message string) {
+	if utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	context.HTML(status, "error_template", gin.H{"error": message})
+}
@@ -0,0 +1,319 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSystemPromptLength is the longest system prompt accepted, in characters.
+	maxSystemPromptLength = 20000
+	// maxModelLength is the longest model name accepted, in characters.
+	maxModelLength = 100
+	// maxTokensLimit is the largest reply length in tokens that can be requested.
+	maxTokensLimit = 1000000
+)
+
+/*
+AddSettingsRoutes adds the routes that read and change generation settings to the Gin router.
+
+Every chat has its own settings; the defaults of a user are copied into the chats they create.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+*/
+func AddSettingsRoutes(router *gin.Engine, db *gorm.DB) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/chat/:chat_id/settings", func(context *gin.Context) { getChatSettings(context, db) })
+	authorized.PUT("/chat/:chat_id/settings", func(context *gin.Context) { updateChatSettings(context, db) })
+	authorized.GET("/user/settings", getUserSettings)
+	authorized.PUT("/user/settings", func(context *gin.Context) { updateUserSettings(context, db) })
+}
+
+/*
+getChatSettings returns the generation settings of a chat of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The settings, for JSON clients.
+	* `HTML` The settings form, otherwise.
+*/
+func getChatSettings(context *gin.Context, db *gorm.DB) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		settingsError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	chat, err := database.GetChatForUser(db, uint(chatID), utils.CurrentUserID(context))
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		settingsError(context, status, message)
+		return
+	}
+	respondSettings(context, chat.Settings, chatSettingsPath(chat.ID), false)
+}
+
+/*
+updateChatSettings replaces the generation settings of a chat of the current user.
+
+The settings are accepted as JSON or as form fields; empty fields are cleared, so the provider's defaults apply.
+They take effect with the next reply generated in the chat.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The saved settings, for JSON clients.
+	* `HTML` The settings form with a confirmation, otherwise.
+*/
+func updateChatSettings(context *gin.Context, db *gorm.DB) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		settingsError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	settings, err := bindSettings(context)
+	if err != nil {
+		settingsError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chat, err := database.UpdateChatSettingsForUser(db, uint(chatID), utils.CurrentUserID(context), settings)
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		settingsError(context, status, message)
+		return
+	}
+	respondSettings(context, chat.Settings, chatSettingsPath(chat.ID), true)
+}
+
+/*
+getUserSettings returns the generation settings new chats of the current user start with.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `JSON` The default settings, for JSON clients.
+	* `HTML` The settings form, otherwise.
+*/
+func getUserSettings(context *gin.Context) {
+	respondSettings(context, utils.CurrentUser(context).DefaultSettings, "/user/settings", false)
+}
+
+/*
+updateUserSettings replaces the generation settings new chats of the current user start with.
+
+Existing chats keep their own settings.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The saved defaults, for JSON clients.
+	* `HTML` The settings form with a confirmation, otherwise.
+*/
+func updateUserSettings(context *gin.Context, db *gorm.DB) {
+	settings, err := bindSettings(context)
+	if err != nil {
+		settingsError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := database.UpdateUserDefaultSettings(db, utils.CurrentUserID(context), settings); err != nil {
+		settingsError(context, http.StatusInternalServerError, "Failed to save settings")
+		return
+	}
+	respondSettings(context, settings, "/user/settings", true)
+}
+
+/*
+bindSettings reads generation settings from a JSON body or from form fields and validates them.
+
+In forms an empty field means the setting is not set; in JSON a missing or null property does.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `models.GenerationSettings` The settings, with surrounding whitespace removed from the model name.
+	* `error` An error describing the first invalid setting.
+*/
+func bindSettings(context *gin.Context) (models.GenerationSettings, error) {
+	var settings models.GenerationSettings
+	if context.ContentType() == gin.MIMEJSON {
+		if err := context.ShouldBindJSON(&settings); err != nil {
+			return settings, errors.New("Invalid settings")
+		}
+	} else {
+		settings.SystemPrompt = context.PostForm("system_prompt")
+		settings.Model = context.PostForm("model")
+
+		var err error
+		if settings.Temperature, err = formFloat(context, "temperature", "Temperature"); err != nil {
+			return settings, err
+		}
+		if settings.TopP, err = formFloat(context, "top_p", "Top P"); err != nil {
+			return settings, err
+		}
+		if value := strings.TrimSpace(context.PostForm("max_tokens")); value != "" {
+			maxTokens, err := strconv.Atoi(value)
+			if err != nil {
+				return settings, errors.New("Max tokens must be a whole number")
+			}
+			settings.MaxTokens = &maxTokens
+		}
+	}
+
+	settings.Model = strings.TrimSpace(settings.Model)
+	return settings, validateSettings(settings)
+}
+
+/*
+formFloat parses an optional decimal form field.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `field` (string) The name of the form field.
+	* `label` (string) The name of the setting shown in error messages.
+
+- Returns:
+	* `*float64` The value, or nil if the field is empty.
+	* `error` An error if the field is not a finite number.
+*/
+func formFloat(context *gin.Context, field, label string) (*float64, error) {
+	value := strings.TrimSpace(context.PostForm(field))
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return nil, fmt.Errorf("%s must be a number", label)
+	}
+	return &parsed, nil
+}
+
+/*
+validateSettings checks that generation settings are within the ranges providers accept.
+
+- Args:
+	* `settings` (models.GenerationSettings) The settings.
+
+- Returns:
+	(error) An error describing the first invalid setting, or nil.
+*/
+func validateSettings(settings models.GenerationSettings) error {
+	switch {
+	case utf8.RuneCountInString(settings.SystemPrompt) > maxSystemPromptLength:
+		return fmt.Errorf("The system prompt may have at most %d characters", maxSystemPromptLength)
+	case utf8.RuneCountInString(settings.Model) > maxModelLength:
+		return fmt.Errorf("The model name may have at most %d characters", maxModelLength)
+	// The ranges are written so that NaN fails them too.
+	case settings.Temperature != nil && !(*settings.Temperature >= 0 && *settings.Temperature <= 2):
+		return errors.New("Temperature must be between 0 and 2")
+	case settings.TopP != nil && !(*settings.TopP >= 0 && *settings.TopP <= 1):
+		return errors.New("Top P must be between 0 and 1")
+	case settings.MaxTokens != nil && (*settings.MaxTokens < 1 || *settings.MaxTokens > maxTokensLimit):
+		return fmt.Errorf("Max tokens must be between 1 and %d", maxTokensLimit)
+	}
+	return nil
+}
+
+/*
+respondSettings sends generation settings as JSON or as the `settings_form` partial.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `settings` (models.GenerationSettings) The settings.
+	* `action` (string) The path the form is submitted to.
+	* `saved` (bool) Whether the settings were just saved, which the form confirms.
+*/
+func respondSettings(context *gin.Context, settings models.GenerationSettings, action string, saved bool) {
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, settings)
+		return
+	}
+	context.HTML(http.StatusOK, "settings_form", settingsFormData(settings, action, saved))
+}
+
+/*
+settingsFormData builds the data the `settings_form` template renders, with unset numbers as empty fields.
+
+- Args:
+	* `settings` (models.GenerationSettings) The settings.
+	* `action` (string) The path the form is submitted to.
+	* `saved` (bool) Whether the settings were just saved.
+
+- Returns:
+	(gin.H) The template data.
+*/
+func settingsFormData(settings models.GenerationSettings, action string, saved bool) gin.H {
+	data := gin.H{
+		"action":       action,
+		"saved":        saved,
+		"systemPrompt": settings.SystemPrompt,
+		"model":        settings.Model,
+		"temperature":  "",
+		"topP":         "",
+		"maxTokens":    "",
+	}
+	if settings.Temperature != nil {
+		data["temperature"] = strconv.FormatFloat(*settings.Temperature, 'f', -1, 64)
+	}
+	if settings.TopP != nil {
+		data["topP"] = strconv.FormatFloat(*settings.TopP, 'f', -1, 64)
+	}
+	if settings.MaxTokens != nil {
+		data["maxTokens"] = strconv.Itoa(*settings.MaxTokens)
+	}
+	return data
+}
+
+/*
+settingsError responds with an error as JSON for API clients and as an error message otherwise.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message.
+*/
+func settingsError(context *gin.Context, status int, message string) {
+	if utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	context.HTML(status, "error_template", gin.H{"error": message})
+}
+
+/*
+chatSettingsPath returns the path of the settings of a chat.
+
+- Args:
+	* `chatID` (uint) The ID of the chat.
+
+- Returns:
+	(string) The path.
+*/
+func chatSettingsPath(chatID uint) string {
+	return "/chat/" + strconv.Itoa(int(chatID)) + "/settings"
+}
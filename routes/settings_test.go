@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gochat/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFormFloat checks that settings posted by the web form must be finite numbers.
+func TestFormFloat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	seven := 0.7
+	tests := []struct {
+		value string
+		want  *float64
+		err   bool
+	}{
+		{value: "", want: nil},
+		{value: " 0.7 ", want: &seven},
+		{value: "abc", err: true},
+		{value: "NaN", err: true},
+		{value: "nan", err: true},
+		{value: "Inf", err: true},
+		{value: "-Infinity", err: true},
+	}
+	for _, test := range tests {
+		form := url.Values{"temperature": {test.value}}
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		context.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		got, err := formFloat(context, "temperature", "Temperature")
+		if (err != nil) != test.err {
+			t.Errorf("formFloat(%q) error = %v, want error %t", test.value, err, test.err)
+			continue
+		}
+		if (got == nil) != (test.want == nil) || got != nil && *got != *test.want {
+			t.Errorf("formFloat(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+// TestValidateSettingsRejectsNaN checks that NaN fails the range checks instead of slipping past them.
+func TestValidateSettingsRejectsNaN(t *testing.T) {
+	nan := math.NaN()
+	for _, settings := range []models.GenerationSettings{{Temperature: &nan}, {TopP: &nan}} {
+		if err := validateSettings(settings); err == nil {
+			t.Errorf("validateSettings(%+v) accepted NaN", settings)
+		}
+	}
+}
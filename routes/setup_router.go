@@ -1,6 +1,12 @@
 package routes
 
 import (
+	"time"
+
+	"gochat/ai"
+	"gochat/attachment"
+	"gochat/config"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
@@ -12,25 +18,39 @@ SetupRouter sets up the Gin router with all the necessary routes and middleware.
 
 - Args:
     * `db` (*gorm.DB) The database connection.
+    * `provider` (ai.Provider) The AI provider used to answer messages.
+    * `files` (*attachment.Store) The store of the files attached to messages.
+    * `cfg` (*config.Config) The server configuration, which provides the session and trash settings.
 
 - Returns:
     (*gin.Engine) The configured Gin router.
 */
-func SetupRouter(db *gorm.DB) *gin.Engine {
+func SetupRouter(db *gorm.DB, provider ai.Provider, files *attachment.Store, cfg *config.Config) *gin.Engine {
     router := gin.Default()
 
     // Set up session middleware
-    store := cookie.NewStore([]byte("super-secret-key"))
+    store := cookie.NewStore([]byte(cfg.Session.Secret))
     store.Options(sessions.Options{
+        Path:     "/",
         HttpOnly: true,
-        Secure:   false, // Set to true if using HTTPS
-        MaxAge:   3600,  // 1 hour
+        Secure:   cfg.SecureCookies(),
+        MaxAge:   int(time.Duration(cfg.Session.TTL).Seconds()),
     })
     router.Use(sessions.Sessions("mysession", store))
 
     AddUserRoutes(router, db)
-    AddChatRoutes(router, db)
-    AddMessageRoutes(router, db)
+    AddChatRoutes(router, db, provider)
+    AddSidebarRoutes(router, db)
+    AddMessageRoutes(router, db, provider, files)
+    AddAttachmentRoutes(router, db, files)
+    AddSearchRoutes(router, db)
+    AddExportRoutes(router, db, cfg.Paths.Static)
+    AddImportRoutes(router, db)
+    AddSettingsRoutes(router, db)
+    AddPromptTemplateRoutes(router, db)
+    AddTrashRoutes(router, db, files, time.Duration(cfg.Database.TrashRetention))
+    AddAPIRoutes(router, db, provider, files, time.Duration(cfg.Database.TrashRetention))
+    AddOpenAPIRoutes(router)
 
     return router
 }
\ No newline at end of file
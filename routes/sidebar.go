@@ -0,0 +1,660 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// unfiledSection is the key of the sidebar section that holds the chats outside any folder.
+const unfiledSection = "none"
+
+// invalidFolderMessage is the error message shown for an invalid `folder` parameter.
+const invalidFolderMessage = "Invalid folder parameter"
+
+// errInvalidFolder is returned when the `folder` query parameter is neither a folder ID nor "none".
+var errInvalidFolder = errors.New("invalid folder")
+
+// folderInput is the request body for creating and renaming folders.
+type folderInput struct {
+	Name string `form:"name" json:"name" binding:"required,max=60"`
+}
+
+/*
+AddSidebarRoutes adds the routes that organize the chats in the sidebar into folders, tags and pinned chats.
+
+The HTML responses re-render only the sidebar sections an action changes, swapped in out of band.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+*/
+func AddSidebarRoutes(router *gin.Engine, db *gorm.DB) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/user/sidebar", func(context *gin.Context) { getSidebar(context, db) })
+	authorized.POST("/folders", func(context *gin.Context) { createFolder(context, db) })
+	authorized.PATCH("/folders/:folder_id", func(context *gin.Context) { renameFolder(context, db) })
+	authorized.DELETE("/folders/:folder_id", func(context *gin.Context) { deleteFolder(context, db) })
+	authorized.PUT("/chat/:chat_id/folder", func(context *gin.Context) { moveChat(context, db) })
+	authorized.PUT("/chat/:chat_id/pin", func(context *gin.Context) { pinChat(context, db, true) })
+	authorized.DELETE("/chat/:chat_id/pin", func(context *gin.Context) { pinChat(context, db, false) })
+	authorized.PUT("/chat/:chat_id/tags", func(context *gin.Context) { tagChat(context, db) })
+}
+
+/*
+getSidebar renders the chat sidebar: the tag filter, a section for each folder and a section for the chats outside
+any folder, each with its first page of chats.
+
+The optional `tag` query parameter lists only the chats with that tag.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `HTML` The `chat_sidebar` partial.
+*/
+func getSidebar(context *gin.Context, db *gorm.DB) {
+	userID := utils.CurrentUserID(context)
+	tag := database.NormalizeTag(context.Query("tag"))
+
+	folders, err := database.GetFoldersForUser(db, userID)
+	if err != nil {
+		log.Println("error", err, "getSidebar: folders, error block 1")
+		context.HTML(http.StatusInternalServerError, "error_template", gin.H{"error": "Failed to retrieve chats"})
+		return
+	}
+
+	sections := make([]gin.H, 0, len(folders))
+	for i := range folders {
+		section, err := chatSectionData(db, userID, &folders[i], tag)
+		if err != nil {
+			log.Println("error", err, "getSidebar: folder section, error block 2")
+			context.HTML(http.StatusInternalServerError, "error_template", gin.H{"error": "Failed to retrieve chats"})
+			return
+		}
+		sections = append(sections, section)
+	}
+
+	unfiled, err := chatSectionData(db, userID, nil, tag)
+	if err != nil {
+		log.Println("error", err, "getSidebar: unfiled section, error block 3")
+		context.HTML(http.StatusInternalServerError, "error_template", gin.H{"error": "Failed to retrieve chats"})
+		return
+	}
+	filter, err := tagFilterData(db, userID, tag)
+	if err != nil {
+		log.Println("error", err, "getSidebar: tags, error block 4")
+		context.HTML(http.StatusInternalServerError, "error_template", gin.H{"error": "Failed to retrieve chats"})
+		return
+	}
+
+	context.HTML(http.StatusOK, "chat_sidebar", gin.H{"filter": filter, "folders": sections, "unfiled": unfiled})
+}
+
+/*
+createFolder creates a folder for the current user.
+
+It expects the name as a form field or JSON property named `name`.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The new folder with status 201, for JSON clients.
+	* `HTML` The empty section of the new folder, otherwise.
+*/
+func createFolder(context *gin.Context, db *gorm.DB) {
+	name, ok := bindFolderName(context)
+	if !ok {
+		return
+	}
+
+	folder := models.Folder{UserID: utils.CurrentUserID(context), Name: name}
+	if err := database.AddFolder(db, &folder); err != nil {
+		log.Println("error", err, "createFolder: add, error block 1")
+		sidebarError(context, http.StatusInternalServerError, "Failed to create the folder")
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusCreated, newAPIFolder(&folder))
+		return
+	}
+	respondSection(context, db, &folder, false)
+}
+
+/*
+renameFolder sets the name of a folder of the current user.
+
+It expects the folder ID as a URL parameter and the name as a form field or JSON property named `name`.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The renamed folder, for JSON clients.
+	* `HTML` The section of the folder, otherwise.
+	* `error` 404 if the folder does not exist and 403 if it belongs to another user.
+*/
+func renameFolder(context *gin.Context, db *gorm.DB) {
+	folderID, err := strconv.Atoi(context.Param("folder_id"))
+	if err != nil {
+		sidebarError(context, http.StatusBadRequest, "Invalid folder ID")
+		return
+	}
+	name, ok := bindFolderName(context)
+	if !ok {
+		return
+	}
+
+	folder, err := database.RenameFolderForUser(db, uint(folderID), utils.CurrentUserID(context), name)
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		sidebarError(context, status, message)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, newAPIFolder(folder))
+		return
+	}
+	respondSection(context, db, folder, false)
+}
+
+/*
+deleteFolder deletes a folder of the current user. Its chats are kept and moved out of the folder.
+
+It expects the folder ID as a URL parameter.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `204` No content, for JSON clients.
+	* `HTML` Nothing in place of the folder's section, and the section of the chats outside any folder out of band.
+	* `error` 404 if the folder does not exist and 403 if it belongs to another user.
+*/
+func deleteFolder(context *gin.Context, db *gorm.DB) {
+	folderID, err := strconv.Atoi(context.Param("folder_id"))
+	if err != nil {
+		sidebarError(context, http.StatusBadRequest, "Invalid folder ID")
+		return
+	}
+
+	if err := database.DeleteFolderForUser(db, uint(folderID), utils.CurrentUserID(context)); err != nil {
+		status, message := chatErrorResponse(err)
+		sidebarError(context, status, message)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.Status(http.StatusNoContent)
+		return
+	}
+	context.Status(http.StatusOK)
+	renderSections(context, db, nil)
+}
+
+/*
+moveChat files a chat of the current user into a folder, or takes it out of its folder.
+
+It expects the chat ID as a URL parameter and the folder ID as a form field or JSON property named `folder_id`; an
+empty value, 0 or null takes the chat out of its folder. The sidebar sends it when a chat is dropped on a section.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The moved chat, for JSON clients.
+	* `HTML` The sections the chat left and joined, out of band.
+	* `error` 404 if the chat or folder does not exist and 403 if either belongs to another user.
+*/
+func moveChat(context *gin.Context, db *gorm.DB) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		sidebarError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	var input struct {
+		FolderID *uint `form:"folder_id" json:"folder_id"`
+	}
+	if err := context.ShouldBind(&input); err != nil {
+		sidebarError(context, http.StatusBadRequest, "Invalid folder ID")
+		return
+	}
+	if input.FolderID != nil && *input.FolderID == 0 {
+		input.FolderID = nil
+	}
+
+	chat, previous, err := database.MoveChatForUser(db, uint(chatID), utils.CurrentUserID(context), input.FolderID)
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		sidebarError(context, status, message)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, newAPIChat(chat))
+		return
+	}
+	context.Status(http.StatusOK)
+	renderSections(context, db, previous, chat.FolderID)
+}
+
+/*
+pinChat pins a chat of the current user to the top of its section, or unpins it.
+
+It expects the chat ID as a URL parameter.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `pinned` (bool) Whether the chat is pinned.
+
+- Returns:
+	* `JSON` The chat, for JSON clients.
+	* `HTML` The section of the chat, out of band.
+	* `error` 404 if the chat does not exist and 403 if it belongs to another user.
+*/
+func pinChat(context *gin.Context, db *gorm.DB, pinned bool) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		sidebarError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	chat, err := database.SetChatPinnedForUser(db, uint(chatID), utils.CurrentUserID(context), pinned)
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		sidebarError(context, status, message)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, newAPIChat(chat))
+		return
+	}
+	context.Status(http.StatusOK)
+	renderSections(context, db, chat.FolderID)
+}
+
+/*
+tagChat replaces the tags of a chat of the current user.
+
+It expects the chat ID as a URL parameter and the tags as form fields or a JSON array named `tags`; commas also
+separate tags, so a single text field can hold them all.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+
+- Returns:
+	* `JSON` The chat with its new tags, for JSON clients.
+	* `HTML` The section of the chat and the tag filter, out of band.
+	* `error` 400 if the tags are too long or too many, 404 if the chat does not exist and 403 if it belongs to
+	  another user.
+*/
+func tagChat(context *gin.Context, db *gorm.DB) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		sidebarError(context, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	var input struct {
+		Tags []string `form:"tags" json:"tags"`
+	}
+	if err := context.ShouldBind(&input); err != nil {
+		sidebarError(context, http.StatusBadRequest, "Tags must be a list of strings")
+		return
+	}
+
+	userID := utils.CurrentUserID(context)
+	chat, err := database.SetChatTagsForUser(db, uint(chatID), userID, splitTags(input.Tags))
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		sidebarError(context, status, message)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, newAPIChat(chat))
+		return
+	}
+	context.Status(http.StatusOK)
+	renderSections(context, db, chat.FolderID)
+	filter, err := tagFilterData(db, userID, sidebarTag(context))
+	if err != nil {
+		log.Println("error", err, "tagChat: tags, error block 1")
+		return
+	}
+	filter["oob"] = true
+	context.HTML(http.StatusOK, "tag_filter", filter)
+}
+
+/*
+chatFilter reads the part of the chat list a request asks for.
+
+The `folder` query parameter holds a folder ID, or "none" for the chats outside any folder; without it chats in every
+folder are listed. The `tag` query parameter lists only the chats with that tag.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	(database.ChatFilter) The filter, or errInvalidFolder if the folder parameter is invalid.
+*/
+func chatFilter(context *gin.Context) (database.ChatFilter, error) {
+	filter := database.ChatFilter{Tag: database.NormalizeTag(context.Query("tag"))}
+	switch value := context.Query("folder"); value {
+	case "":
+	case unfiledSection:
+		filter.FolderID = new(uint)
+	default:
+		folderID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || folderID == 0 {
+			return database.ChatFilter{}, errInvalidFolder
+		}
+		id := uint(folderID)
+		filter.FolderID = &id
+	}
+	return filter, nil
+}
+
+/*
+chatFilterQuery builds the query parameters that select a sidebar section, for the URLs of its further pages.
+
+- Args:
+	* `filter` (database.ChatFilter) The filter of the section.
+
+- Returns:
+	(url.Values) The `folder` and `tag` query parameters.
+*/
+func chatFilterQuery(filter database.ChatFilter) url.Values {
+	query := url.Values{}
+	if filter.FolderID != nil {
+		query.Set("folder", sectionKey(*filter.FolderID))
+	}
+	if filter.Tag != "" {
+		query.Set("tag", filter.Tag)
+	}
+	return query
+}
+
+/*
+sectionKey names the sidebar section of a folder, which the IDs of its elements are derived from.
+
+- Args:
+	* `folderID` (uint) The ID of the folder, or 0 for the chats outside any folder.
+
+- Returns:
+	(string) The key of the section.
+*/
+func sectionKey(folderID uint) string {
+	if folderID == 0 {
+		return unfiledSection
+	}
+	return strconv.FormatUint(uint64(folderID), 10)
+}
+
+/*
+sidebarTag reads the tag the sidebar is filtered by, which its forms send along as a `tag` field or query parameter.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	(string) The normalized tag, or an empty string if the sidebar is not filtered.
+*/
+func sidebarTag(context *gin.Context) string {
+	if tag, ok := context.GetPostForm("tag"); ok {
+		return database.NormalizeTag(tag)
+	}
+	return database.NormalizeTag(context.Query("tag"))
+}
+
+/*
+chatSectionData loads the first page of a sidebar section.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the current user.
+	* `folder` (*models.Folder) The folder of the section, or nil for the chats outside any folder.
+	* `tag` (string) The tag the sidebar is filtered by, or an empty string.
+
+- Returns:
+	(gin.H) The data of the `chat_section` template, or an error if the operation failed.
+*/
+func chatSectionData(db *gorm.DB, userID uint, folder *models.Folder, tag string) (gin.H, error) {
+	filter := database.ChatFilter{FolderID: new(uint), Tag: tag}
+	name := "Chats"
+	if folder != nil {
+		filter.FolderID = &folder.ID
+		name = folder.Name
+	}
+
+	chats, next, err := database.GetChatPageForUser(db, userID, filter, nil, defaultChatPageSize)
+	if err != nil {
+		return nil, err
+	}
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.String()
+	}
+
+	return gin.H{
+		"key":      sectionKey(*filter.FolderID),
+		"folderID": *filter.FolderID,
+		"name":     name,
+		"chats":    chatListItems(chats),
+		"first":    true,
+		"moreURL":  pageURL("/user/chats", chatFilterQuery(filter), nextCursor, defaultChatPageSize),
+	}, nil
+}
+
+/*
+tagFilterData loads the tags of the current user for the tag filter of the sidebar.
+
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `userID` (uint) The ID of the current user.
+	* `tag` (string) The tag the sidebar is filtered by, or an empty string.
+
+- Returns:
+	(gin.H) The data of the `tag_filter` template, or an error if the operation failed.
+*/
+func tagFilterData(db *gorm.DB, userID uint, tag string) (gin.H, error) {
+	tags, err := database.GetTagsForUser(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A tag that no chat has any more stays in the filter until it is cleared.
+	if tag != "" && !slices.ContainsFunc(tags, func(count database.TagCount) bool { return count.Name == tag }) {
+		tags = append(tags, database.TagCount{Name: tag})
+	}
+	items := make([]gin.H, len(tags))
+	for i, count := range tags {
+		items[i] = gin.H{"name": count.Name, "chats": count.Chats, "active": count.Name == tag}
+	}
+	return gin.H{"tag": tag, "tags": items}, nil
+}
+
+/*
+respondSection responds with the section of a folder, followed by the cleared sidebar error out of band.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `folder` (*models.Folder) The folder, or nil for the chats outside any folder.
+	* `oob` (bool) Whether the section is swapped in out of band.
+*/
+func respondSection(context *gin.Context, db *gorm.DB, folder *models.Folder, oob bool) {
+	userID := utils.CurrentUserID(context)
+	section, err := chatSectionData(db, userID, folder, sidebarTag(context))
+	if err != nil {
+		log.Println("error", err, "respondSection: section, error block 1")
+		sidebarError(context, http.StatusInternalServerError, "Failed to retrieve chats")
+		return
+	}
+
+	section["oob"] = oob
+	context.HTML(http.StatusOK, "chat_section", section)
+	context.HTML(http.StatusOK, "sidebar_error", gin.H{})
+}
+
+/*
+renderSections renders sidebar sections to be swapped in out of band, together with the cleared sidebar error.
+
+The response status has to be written before. Sections of folders that no longer exist are skipped.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `folderIDs` (...*uint) The folders of the sections; nil stands for the chats outside any folder, and repeated
+	  folders are rendered once.
+*/
+func renderSections(context *gin.Context, db *gorm.DB, folderIDs ...*uint) {
+	userID := utils.CurrentUserID(context)
+	tag := sidebarTag(context)
+
+	var rendered []uint
+	for _, folderID := range folderIDs {
+		var key uint
+		var folder *models.Folder
+		if folderID != nil {
+			key = *folderID
+		}
+		if slices.Contains(rendered, key) {
+			continue
+		}
+		rendered = append(rendered, key)
+
+		if key != 0 {
+			var err error
+			if folder, err = database.GetFolderForUser(db, key, userID); err != nil {
+				continue
+			}
+		}
+		section, err := chatSectionData(db, userID, folder, tag)
+		if err != nil {
+			log.Println("error", err, "renderSections: section, error block 1")
+			continue
+		}
+		section["oob"] = true
+		context.HTML(http.StatusOK, "chat_section", section)
+	}
+	context.HTML(http.StatusOK, "sidebar_error", gin.H{})
+}
+
+/*
+sidebarError responds to a failed sidebar action as JSON, or with the error shown above the sidebar.
+
+HTML responses only swap the error in out of band, so the sections the request targeted stay as they are.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message shown to the user.
+*/
+func sidebarError(context *gin.Context, status int, message string) {
+	if utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	context.Header("HX-Reswap", "none")
+	context.HTML(status, "sidebar_error", gin.H{"error": message})
+}
+
+/*
+bindFolderName reads the name of a folder from the request, responding with an error if it is invalid.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `string` The trimmed name.
+	* `bool` False if an error response was sent.
+*/
+func bindFolderName(context *gin.Context) (string, bool) {
+	var input folderInput
+	if err := context.ShouldBind(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		sidebarError(context, http.StatusBadRequest, "Folder names need 1 to 60 characters")
+		return "", false
+	}
+	return strings.TrimSpace(input.Name), true
+}
+
+/*
+splitTags splits tags entered as comma separated text.
+
+- Args:
+	* `values` ([]string) The submitted values.
+
+- Returns:
+	([]string) The tags, not yet normalized.
+*/
+func splitTags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	return tags
+}
+
+/*
+chatListItem builds the data of the `chat_list_item` template for a chat.
+
+- Args:
+	* `chat` (*models.Chat) The chat, with its tags.
+
+- Returns:
+	(gin.H) The template data.
+*/
+func chatListItem(chat *models.Chat) gin.H {
+	tags := make([]string, len(chat.Tags))
+	for i, tag := range chat.Tags {
+		tags[i] = tag.Name
+	}
+	return gin.H{
+		"id":      chat.ID,
+		"title":   utils.ChatTitle(chat),
+		"pinned":  chat.Pinned,
+		"tags":    tags,
+		"tagList": strings.Join(tags, ", "),
+	}
+}
+
+/*
+chatListItems builds the data of the `chat_list_item` template for chats.
+
+- Args:
+	* `chats` ([]models.Chat) The chats, with their tags.
+
+- Returns:
+	([]gin.H) The template data.
+*/
+func chatListItems(chats []models.Chat) []gin.H {
+	items := make([]gin.H, len(chats))
+	for i := range chats {
+		items[i] = chatListItem(&chats[i])
+	}
+	return items
+}
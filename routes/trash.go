@@ -0,0 +1,211 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gochat/attachment"
+	"gochat/database"
+	"gochat/models"
+	"gochat/routes/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashDateFormat is the format of the dates shown in the trash.
+const trashDateFormat = "Jan 2, 2006"
+
+/*
+AddTrashRoutes adds the routes of the trash, which holds deleted chats until they are restored or purged.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The store the files of purged attachments are removed from.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged, 0 if they are kept until
+	  the trash is emptied.
+*/
+func AddTrashRoutes(router *gin.Engine, db *gorm.DB, files *attachment.Store, retention time.Duration) {
+	authorized := router.Group("", RequireAuth(db))
+	authorized.GET("/trash", func(context *gin.Context) { listTrash(context, db, retention) })
+	authorized.DELETE("/trash", func(context *gin.Context) { emptyTrash(context, db, files, retention) })
+	authorized.POST("/trash/:chat_id/restore", func(context *gin.Context) { restoreChat(context, db, retention) })
+}
+
+/*
+listTrash returns the deleted chats of the current user, most recently deleted first.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+
+- Returns:
+	* `JSON` {"chats": [...]}, for JSON clients.
+	* `HTML` The `trash_list` partial, otherwise.
+*/
+func listTrash(context *gin.Context, db *gorm.DB, retention time.Duration) {
+	respondTrash(context, db, retention, http.StatusOK, "")
+}
+
+/*
+restoreChat moves a deleted chat of the current user out of the trash.
+
+HTMX clients get the updated trash and a `chatRestored` event, which reloads the chat list.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+
+- Returns:
+	* `JSON` The restored chat, for JSON clients.
+	* `HTML` The updated `trash_list` partial, otherwise.
+	* `error` 404 if the chat does not exist, 403 if it belongs to another user and 409 if it is not in the trash.
+*/
+func restoreChat(context *gin.Context, db *gorm.DB, retention time.Duration) {
+	chatID, err := strconv.Atoi(context.Param("chat_id"))
+	if err != nil {
+		trashError(context, db, retention, http.StatusBadRequest, "Invalid chat ID")
+		return
+	}
+
+	chat, err := database.RestoreChatForUser(db, uint(chatID), utils.CurrentUserID(context))
+	if err != nil {
+		status, message := chatErrorResponse(err)
+		trashError(context, db, retention, status, message)
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, newAPIChat(chat))
+		return
+	}
+	context.Header("HX-Trigger", "chatRestored")
+	respondTrash(context, db, retention, http.StatusOK, "")
+}
+
+/*
+emptyTrash permanently deletes the deleted chats of the current user together with their messages and attached files.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The store the files of purged attachments are removed from.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+
+- Returns:
+	* `JSON` {"purged": n}, the number of chats deleted, for JSON clients.
+	* `HTML` The empty `trash_list` partial, otherwise.
+*/
+func emptyTrash(context *gin.Context, db *gorm.DB, files *attachment.Store, retention time.Duration) {
+	purged, err := database.EmptyTrashForUser(db, utils.CurrentUserID(context))
+	if err != nil {
+		log.Println("error", err, "emptyTrash: purge, error block 1")
+		trashError(context, db, retention, http.StatusInternalServerError, "Failed to empty the trash")
+		return
+	}
+	removePurgedFiles(context, db, files)
+
+	if utils.WantsJSON(context) {
+		context.JSON(http.StatusOK, gin.H{"purged": purged})
+		return
+	}
+	respondTrash(context, db, retention, http.StatusOK, "")
+}
+
+/*
+respondTrash responds with the deleted chats of the current user.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+	* `status` (int) The HTTP status code.
+	* `message` (string) An error shown above the HTML list, or an empty string.
+*/
+func respondTrash(context *gin.Context, db *gorm.DB, retention time.Duration, status int, message string) {
+	chats, err := database.GetTrashedChatsForUser(db, utils.CurrentUserID(context))
+	if err != nil {
+		log.Println("error", err, "respondTrash: list, error block 1")
+		if utils.WantsJSON(context) {
+			abortAPI(context, http.StatusInternalServerError, "Failed to retrieve the trash")
+			return
+		}
+		context.HTML(http.StatusInternalServerError, "trash_list", gin.H{"error": "Failed to retrieve the trash"})
+		return
+	}
+
+	if utils.WantsJSON(context) {
+		context.JSON(status, gin.H{"chats": newAPITrashedChats(chats, retention)})
+		return
+	}
+
+	items := make([]gin.H, len(chats))
+	for i := range chats {
+		item := gin.H{
+			"id":        chats[i].ID,
+			"title":     utils.ChatTitle(&chats[i]),
+			"deletedAt": chats[i].DeletedAt.Time.Format(trashDateFormat),
+		}
+		if purgeAt := trashPurgeTime(&chats[i], retention); purgeAt != nil {
+			item["purgeAt"] = purgeAt.Format(trashDateFormat)
+		}
+		items[i] = item
+	}
+	context.HTML(status, "trash_list", gin.H{"chats": items, "error": message})
+}
+
+/*
+trashError responds to a failed trash action as JSON, or with the trash and the error above it.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+	* `status` (int) The HTTP status code.
+	* `message` (string) The error message shown to the user.
+*/
+func trashError(context *gin.Context, db *gorm.DB, retention time.Duration, status int, message string) {
+	if utils.WantsJSON(context) {
+		abortAPI(context, status, message)
+		return
+	}
+	respondTrash(context, db, retention, status, message)
+}
+
+/*
+trashPurgeTime computes when a deleted chat will be purged.
+
+- Args:
+	* `chat` (*models.Chat) The deleted chat.
+	* `retention` (time.Duration) How long deleted chats are kept before they are purged.
+
+- Returns:
+	(*time.Time) The time, or nil if deleted chats are kept until the trash is emptied.
+*/
+func trashPurgeTime(chat *models.Chat, retention time.Duration) *time.Time {
+	if retention <= 0 {
+		return nil
+	}
+	purgeAt := chat.DeletedAt.Time.Add(retention)
+	return &purgeAt
+}
+
+/*
+removePurgedFiles removes the files of the attachments of purged chats right away. Files that cannot be removed are
+left to the purge job, which tries again.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The attachment store.
+*/
+func removePurgedFiles(context *gin.Context, db *gorm.DB, files *attachment.Store) {
+	if _, err := attachment.RemovePurged(context.Request.Context(), db, files); err != nil {
+		log.Println("error", err, "removePurgedFiles: remove files")
+	}
+}
@@ -1,63 +1,305 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+    "log"
+    "net/http"
+    "strconv"
+
+    "gochat/database"
+    "gochat/models"
+    "gochat/routes/utils"
+
+    "github.com/gin-contrib/sessions"
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
 )
 
+// credentials is the login and registration payload, accepted as a form or as JSON.
+type credentials struct {
+    Username string `form:"username" json:"username" binding:"required,min=3,max=32"`
+    Password string `form:"password" json:"password" binding:"required,min=8,max=72"`
+}
+
+/*
+AddUserRoutes adds user-related routes to the Gin router.
+
+The register and login routes are public. The remaining routes require an authenticated user.
+
+- Args:
+    * `router` (*gin.Engine) The Gin router.
+    * `db` (*gorm.DB) The database connection.
+*/
 func AddUserRoutes(router *gin.Engine, db *gorm.DB) {
-    // router.GET("/user/register", renderRegisterForm)
-    // router.POST("/user/register", func(context *gin.Context) { registerUser(context, db) })
-    // router.GET("/user/login", renderLoginForm)
-    // router.POST("/user/login", func(context *gin.Context) { loginUser(context, db) })
-}
-
-// func renderRegisterForm(c *gin.Context) {
-//     c.HTML(http.StatusOK, "register.html", nil)
-// }
-
-// func renderLoginForm(c *gin.Context) {
-//     c.HTML(http.StatusOK, "login.html", nil)
-// }
-
-// func registerUser(context *gin.Context, db *gorm.DB) {
-//     var user models.User
-//     if err := context.ShouldBind(&user); err != nil {
-//         context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-//         return
-//     }
-
-//     if err := database.RegisterUser(db, &user); err != nil {
-//         context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//         return
-//     }
-
-//     context.JSON(http.StatusOK, gin.H{"status": "registered"})
-// }
-
-// func loginUser(context *gin.Context, db *gorm.DB) {
-//     var user models.User
-//     if err := context.ShouldBind(&user); err != nil {
-//         log.Printf("Invalid input: %v", err)
-//         context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-//         return
-//     }
-
-//     log.Printf("Attempting login for user: %s", user.Username)
-//     if err := database.LoginUser(db, &user); err != nil {
-//         log.Printf("Invalid credentials for user %s: %v", user.Username, err)
-//         context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-//         return
-//     }
-
-//     session := sessions.Default(context)
-//     session.Set("userID", user.ID)
-//     session.Save()
-
-//     log.Printf("User logged in: %+v", user)
-
-//     context.JSON(http.StatusOK, gin.H{
-//         "username": user.Username,
-//         "userID":   user.ID,
-//     })
-// }
\ No newline at end of file
+    router.GET("/user/register", renderRegisterForm)
+    router.POST("/user/register", func(context *gin.Context) { registerUser(context, db) })
+    router.GET("/user/login", renderLoginForm)
+    router.POST("/user/login", func(context *gin.Context) { loginUser(context, db) })
+
+    authorized := router.Group("", RequireAuth(db))
+    authorized.POST("/user/logout", logoutUser)
+    authorized.GET("/user/me", getCurrentUser)
+    authorized.GET("/user/tokens", func(context *gin.Context) { getAPITokens(context, db) })
+    authorized.POST("/user/tokens", func(context *gin.Context) { createAPIToken(context, db) })
+    authorized.DELETE("/user/tokens/:token_id", func(context *gin.Context) { deleteAPIToken(context, db) })
+}
+
+/*
+renderRegisterForm renders the registration page.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+*/
+func renderRegisterForm(context *gin.Context) {
+    context.HTML(http.StatusOK, "register", gin.H{"title": "GoChat", "authForm": "register"})
+}
+
+/*
+renderLoginForm renders the login page.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+*/
+func renderLoginForm(context *gin.Context) {
+    context.HTML(http.StatusOK, "login", gin.H{"title": "GoChat", "authForm": "login"})
+}
+
+/*
+registerUser creates a new user account and logs the user in.
+
+It accepts the credentials as a form or as JSON.
+If the account is created, JSON clients get the new user and browser clients are redirected to the main page.
+If there is an error, JSON clients get an error message and browser clients get the form again with the error.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `db` (*gorm.DB) The database connection.
+*/
+func registerUser(context *gin.Context, db *gorm.DB) {
+    var input credentials
+    if err := context.ShouldBind(&input); err != nil {
+        authError(context, http.StatusBadRequest, "register",
+            "Usernames need 3 to 32 characters and passwords at least 8")
+        return
+    }
+
+    user := models.User{Username: input.Username, Password: input.Password}
+    if err := database.RegisterUser(db, &user); err != nil {
+        log.Printf("Failed to register user %s: %v", input.Username, err)
+        if err.Error() == "user already exists" {
+            authError(context, http.StatusConflict, "register", "That username is already taken")
+            return
+        }
+        authError(context, http.StatusInternalServerError, "register", "Failed to register user")
+        return
+    }
+
+    startSession(context, &user)
+}
+
+/*
+loginUser checks the submitted credentials and stores the user ID in the session.
+
+It accepts the credentials as a form or as JSON.
+If the credentials are valid, JSON clients get the user and browser clients are redirected to the main page.
+If they are not, it responds with 401.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `db` (*gorm.DB) The database connection.
+*/
+func loginUser(context *gin.Context, db *gorm.DB) {
+    var input credentials
+    if err := context.ShouldBind(&input); err != nil {
+        authError(context, http.StatusBadRequest, "login", "Enter your username and password")
+        return
+    }
+
+    user := models.User{Username: input.Username, Password: input.Password}
+    if err := database.LoginUser(db, &user); err != nil {
+        log.Printf("Invalid credentials for user %s: %v", input.Username, err)
+        authError(context, http.StatusUnauthorized, "login", "Invalid username or password")
+        return
+    }
+
+    startSession(context, &user)
+}
+
+/*
+logoutUser clears the session.
+
+JSON clients get a status message, HTMX requests are redirected to the login page through `HX-Redirect` and other
+requests get a redirect.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+*/
+func logoutUser(context *gin.Context) {
+    session := sessions.Default(context)
+    session.Clear()
+    session.Options(sessions.Options{Path: "/", MaxAge: -1})
+    if err := session.Save(); err != nil {
+        log.Printf("Failed to clear session: %v", err)
+    }
+
+    respondRedirect(context, loginPath, gin.H{"status": "logged out"})
+}
+
+/*
+getCurrentUser returns the logged in user.
+
+It returns the user as JSON for API clients and as the user badge partial otherwise.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+*/
+func getCurrentUser(context *gin.Context) {
+    user := utils.CurrentUser(context)
+
+    if utils.WantsJSON(context) {
+        context.JSON(http.StatusOK, gin.H{"id": user.ID, "username": user.Username})
+        return
+    }
+    context.HTML(http.StatusOK, "user_badge", gin.H{"id": user.ID, "username": user.Username})
+}
+
+/*
+getAPITokens lists the API tokens of the current user as JSON.
+
+The token values themselves are never returned, only their names and usage.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `db` (*gorm.DB) The database connection.
+*/
+func getAPITokens(context *gin.Context, db *gorm.DB) {
+    tokens, err := database.GetAPITokensForUser(db, utils.CurrentUserID(context))
+    if err != nil {
+        abortAPI(context, http.StatusInternalServerError, "Failed to retrieve tokens")
+        return
+    }
+
+    tokenList := []gin.H{}
+    for _, token := range tokens {
+        tokenList = append(tokenList, gin.H{
+            "id":           token.ID,
+            "name":         token.Name,
+            "created_at":   token.CreatedAt,
+            "last_used_at": token.LastUsedAt,
+        })
+    }
+    context.JSON(http.StatusOK, gin.H{"tokens": tokenList})
+}
+
+/*
+createAPIToken creates an API token for the current user and returns it as JSON.
+
+The plaintext token is only included in this response. Clients send it as `Authorization: Bearer <token>`.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `db` (*gorm.DB) The database connection.
+*/
+func createAPIToken(context *gin.Context, db *gorm.DB) {
+    var input struct {
+        Name string `form:"name" json:"name" binding:"max=64"`
+    }
+    if err := context.ShouldBind(&input); err != nil {
+        abortAPI(context, http.StatusBadRequest, "Invalid input")
+        return
+    }
+
+    plaintext, token, err := database.CreateAPIToken(db, utils.CurrentUserID(context), input.Name)
+    if err != nil {
+        abortAPI(context, http.StatusInternalServerError, "Failed to create token")
+        return
+    }
+
+    context.JSON(http.StatusCreated, gin.H{"id": token.ID, "name": token.Name, "token": plaintext})
+}
+
+/*
+deleteAPIToken revokes one of the current user's API tokens.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `db` (*gorm.DB) The database connection.
+*/
+func deleteAPIToken(context *gin.Context, db *gorm.DB) {
+    tokenID, err := strconv.Atoi(context.Param("token_id"))
+    if err != nil {
+        abortAPI(context, http.StatusBadRequest, "Invalid token ID")
+        return
+    }
+
+    if err := database.DeleteAPIToken(db, utils.CurrentUserID(context), uint(tokenID)); err != nil {
+        abortAPI(context, http.StatusNotFound, "Token not found")
+        return
+    }
+
+    context.Status(http.StatusNoContent)
+}
+
+/*
+startSession stores the user ID in the session and answers a successful login or registration.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `user` (*models.User) The authenticated user.
+*/
+func startSession(context *gin.Context, user *models.User) {
+    session := sessions.Default(context)
+    session.Set("userID", user.ID)
+    if err := session.Save(); err != nil {
+        log.Printf("Failed to save session for user %s: %v", user.Username, err)
+        authError(context, http.StatusInternalServerError, "login", "Failed to start session")
+        return
+    }
+
+    log.Printf("User logged in: %s (%d)", user.Username, user.ID)
+    respondRedirect(context, "/", gin.H{"id": user.ID, "username": user.Username})
+}
+
+/*
+respondRedirect answers JSON clients with a body and sends browsers to another page.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `location` (string) The page browsers are sent to.
+    * `body` (gin.H) The JSON body for API clients.
+*/
+func respondRedirect(context *gin.Context, location string, body gin.H) {
+    switch {
+    case utils.WantsJSON(context):
+        context.JSON(http.StatusOK, body)
+    case utils.IsHTMX(context):
+        context.Header("HX-Redirect", location)
+        context.Status(http.StatusOK)
+    default:
+        context.Redirect(http.StatusSeeOther, location)
+    }
+}
+
+/*
+authError answers a failed login or registration.
+
+JSON clients get the error message. Browser clients get the form again with the message shown.
+
+- Args:
+    * `context` (*gin.Context) The Gin context for the current HTTP request.
+    * `status` (int) The HTTP status code.
+    * `form` (string) The form to render, "login" or "register".
+    * `message` (string) The error message.
+*/
+func authError(context *gin.Context, status int, form string, message string) {
+    if utils.WantsJSON(context) {
+        abortAPI(context, status, message)
+        return
+    }
+
+    data := gin.H{"title": "GoChat", "authForm": form, "error": message}
+    if utils.IsHTMX(context) {
+        context.HTML(status, "auth_form", data)
+        return
+    }
+    context.HTML(status, form, data)
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"gochat/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userContextKey is the Gin context key under which the authentication middleware stores the current user.
+const userContextKey = "user"
+
+/*
+SetCurrentUser stores the authenticated user in the Gin context.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+	* `user` (*models.User) The authenticated user.
+*/
+func SetCurrentUser(context *gin.Context, user *models.User) {
+	context.Set(userContextKey, user)
+}
+
+/*
+CurrentUser returns the user resolved by the authentication middleware.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `*models.User` The current user, or nil if the request is not authenticated.
+*/
+func CurrentUser(context *gin.Context) *models.User {
+	user, _ := context.Get(userContextKey)
+	current, _ := user.(*models.User)
+	return current
+}
+
+/*
+CurrentUserID returns the ID of the user resolved by the authentication middleware.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `uint` The ID of the current user, or 0 if the request is not authenticated.
+*/
+func CurrentUserID(context *gin.Context) uint {
+	if user := CurrentUser(context); user != nil {
+		return user.ID
+	}
+	return 0
+}
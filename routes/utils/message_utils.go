@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"gochat/attachment"
 	"gochat/database"
 	"gochat/models"
 	"log"
@@ -40,18 +43,19 @@ If the message is valid, it returns the parsed message.
 - Args:
 	* `context` (*gin.Context) The Gin context for the current HTTP request.
 	* `chatID` (int) The chat ID associated with the message.
-	
+	* `userID` (uint) The ID of the user sending the message.
+
 - Returns:
 	* `*models.Message` The parsed and validated message.
 	* `error` An error if the message is not a valid JSON payload.
 */
-func ParseAndValidateMessage(context *gin.Context, chatID int) (*models.Message, error) {
+func ParseAndValidateMessage(context *gin.Context, chatID int, userID uint) (*models.Message, error) {
 	var message models.Message
 	if err := context.ShouldBindJSON(&message); err != nil {
 		return nil, err
 	}
 	message.ChatID = uint(chatID)
-	message.UserID = 1 // Default user ID
+	message.UserID = userID
 	message.MessageType = models.UserMessageType
 	return &message, nil
 }
@@ -59,79 +63,200 @@ func ParseAndValidateMessage(context *gin.Context, chatID int) (*models.Message,
 /*
 SaveAIResponse saves the AI response to the database.
 
-It creates a new message with the AI response and associates it with the chat ID.
+It creates a new message with the AI response and associates it with the chat ID and the user message it answers.
 If the message is saved successfully, it returns the message.
 If there is an error, it returns an error.
 
 - Args:
 	* `db` (*gorm.DB) The database connection.
 	* `chatID` (int) The chat ID associated with the AI response.
+	* `userID` (uint) The ID of the user the conversation belongs to.
+	* `promptID` (uint) The ID of the user message the response answers.
 	* `message` (string) The AI response message.
 
 - Returns:
 	* `*models.Message` The AI response message.
 	* `error` An error if the message is not saved successfully.
 */
-func SaveAIResponse(db *gorm.DB, chatID int, userID int, message string) (*models.Message, error) {
+func SaveAIResponse(db *gorm.DB, chatID int, userID, promptID uint, message string) (*models.Message, error) {
 	aiResponse := &models.Message{
 		ChatID:      uint(chatID),
-		UserID:      uint(userID),
+		ParentID:    &promptID,
+		UserID:      userID,
 		Message:     message,
 		MessageType: models.AIMessageType,
 	}
-	err := database.AddMessage(db, uint(chatID), aiResponse)
+	err := database.AddMessageForUser(db, uint(chatID), userID, aiResponse)
 	return aiResponse, err
 }
 
 /*
-GetAIResponse returns a hardcoded AI response message.
+ChatHistoryData prepares the messages of a chat for the `message` template.
+
+Besides the messages it looks up the position of every message among its siblings.
 
-This is a temp function that produces an example of an AI response message that can be used in the chat
-and is only intended for testing and development purposes.
+- Args:
+	* `db` (*gorm.DB) The database connection.
+	* `chat` (*models.Chat) The chat with the messages to show, usually a page of its active branch.
 
 - Returns:
-	* `string` The hardcoded AI response message.
+	* `[]gin.H` The template data of the messages.
+	* `error` An error if the siblings could not be loaded.
 */
-func GetAIResponse() string {
-	return `Example go code:
-` + "```go\n" + `package main
+func ChatHistoryData(db *gorm.DB, chat *models.Chat) ([]gin.H, error) {
+	siblings, err := database.GetPathSiblings(db, chat.ID, chat.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]gin.H, len(chat.Messages))
+	for i, msg := range chat.Messages {
+		messages[i] = MessageData(msg, int(chat.ID), siblings[msg.ID])
+	}
+	return messages, nil
+}
+
+/*
+MessageData builds the data the `message` template renders.
 
-import "fmt"
+Besides the message itself it holds the position of the message among its siblings, which the branch switcher
+shows as "< 2/3 >", the IDs of the previous and next sibling, and the links to the attached files.
 
-func main() {
-	fmt.Println("Hello, world!")
+- Args:
+	* `message` (models.Message) The message.
+	* `chatID` (int) The ID of the chat the message belongs to.
+	* `siblings` ([]uint) The IDs of the message and its siblings in the order they were created.
+
+- Returns:
+	* `gin.H` The template data.
+*/
+func MessageData(message models.Message, chatID int, siblings []uint) gin.H {
+	data := gin.H{
+		"id":           message.ID,
+		"chatID":       chatID,
+		"message":      message.Message,
+		"messageType":  message.MessageType,
+		"branchIndex":  1,
+		"branchCount":  1,
+		"prevBranchID": uint(0),
+		"nextBranchID": uint(0),
+		"attachments":  attachmentData(message.Attachments),
+	}
+
+	for i, id := range siblings {
+		if id != message.ID {
+			continue
+		}
+		data["branchIndex"] = i + 1
+		data["branchCount"] = len(siblings)
+		if i > 0 {
+			data["prevBranchID"] = siblings[i-1]
+		}
+		if i+1 < len(siblings) {
+			data["nextBranchID"] = siblings[i+1]
+		}
+	}
+	return data
 }
-` + "```\n\n" + `This is hardcoded in the backend for now. ` + "`inline-code`" + ` this is an example of inline code.`
+
+/*
+attachmentData builds the data the `message` template renders for the files attached to a message.
+
+- Args:
+	* `attachments` ([]models.Attachment) The attachments.
+
+- Returns:
+	* `[]gin.H` The name, size and download link of every file, and the thumbnail link of images.
+*/
+func attachmentData(attachments []models.Attachment) []gin.H {
+	data := make([]gin.H, len(attachments))
+	for i, file := range attachments {
+		url := fmt.Sprintf("/attachments/%d", file.ID)
+		data[i] = gin.H{
+			"id":       file.ID,
+			"filename": file.Filename,
+			"size":     attachment.FormatSize(file.Size),
+			"url":      url,
+			"isImage":  attachment.IsImage(file.ContentType),
+		}
+		if file.ThumbnailKey != "" {
+			data[i]["thumbnailURL"] = url + "/thumbnail"
+		}
+	}
+	return data
 }
 
 /*
-FetchUpdatedChatHistory retrieves the updated chat history for a given chat ID.
+SiblingIDs returns the IDs of a message and its siblings, for use with MessageData.
 
-It fetches the chat history from the database based on the chat ID.
-If the chat is found, it returns the chat history.
-If there is an error, it returns an error.
+If the siblings cannot be loaded, the message is treated as having none, so the switcher is hidden.
 
 - Args:
 	* `db` (*gorm.DB) The database connection.
-	* `chatID` (int) The chat ID associated with the chat history.
+	* `messageID` (uint) The ID of the message.
 
 - Returns:
-	* `[]gin.H` A list of messages in the chat.
-	* `error` An error if the chat is not found.
+	* `[]uint` The IDs in the order the messages were created.
 */
-func FetchUpdatedChatHistory(db *gorm.DB, chatID int) ([]gin.H, error) {
-	chat, err := database.GetChat(db, uint(chatID))
+func SiblingIDs(db *gorm.DB, messageID uint) []uint {
+	siblings, err := database.GetSiblings(db, messageID)
 	if err != nil {
-		return nil, err
+		log.Println("error", err, "SiblingIDs: get siblings")
+		return nil
 	}
 
-	messages := make([]gin.H, len(chat.Messages))
-	for i, msg := range chat.Messages {
-		messages[i] = gin.H{
-			"id":          msg.ID,
-			"message":     msg.Message,
-			"messageType": msg.MessageType,
+	ids := make([]uint, len(siblings))
+	for i, sibling := range siblings {
+		ids[i] = sibling.ID
+	}
+	return ids
+}
+
+/*
+ChatTitle returns the title shown for a chat.
+
+Chats that have not been titled yet, by the user or automatically, are shown as "Chat" followed by their ID.
+
+- Args:
+	* `chat` (*models.Chat) The chat.
+
+- Returns:
+	* `string` The title to display.
+*/
+func ChatTitle(chat *models.Chat) string {
+	if chat.Title != "" {
+		return chat.Title
+	}
+	return "Chat " + strconv.Itoa(int(chat.ID))
+}
+
+/*
+SplitHistoryAt splits a chat history at a user message.
+
+It returns the messages up to and including the message with the given ID, which is the context an AI reply to that
+message is generated from, and the AI reply that follows the message if one has already been saved.
+
+- Args:
+	* `messages` ([]models.Message) The chat history in chronological order.
+	* `messageID` (uint) The ID of the user message.
+
+- Returns:
+	* `[]models.Message` The history up to and including the message.
+	* `*models.Message` The saved AI reply to the message, or nil if there is none yet.
+	* `error` An error if the message is not part of the history.
+*/
+func SplitHistoryAt(messages []models.Message, messageID uint) ([]models.Message, *models.Message, error) {
+	for i, message := range messages {
+		if message.ID != messageID {
+			continue
 		}
+		if message.MessageType != models.UserMessageType {
+			return nil, nil, errors.New("message is not a user message")
+		}
+		if i+1 < len(messages) && messages[i+1].MessageType == models.AIMessageType {
+			return messages[:i+1], &messages[i+1], nil
+		}
+		return messages[:i+1], nil, nil
 	}
-	return messages, nil
+	return nil, nil, errors.New("message not found")
 }
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+/*
+RenderTemplate executes a named HTML template loaded into the router and returns the output as a string.
+
+This is used where a rendered fragment has to be embedded in another response, such as a server-sent event.
+
+- Args:
+	* `router` (*gin.Engine) The Gin router holding the loaded templates.
+	* `name` (string) The name of the template to execute.
+	* `data` (any) The data passed to the template.
+
+- Returns:
+	* `string` The rendered HTML.
+	* `error` An error if the templates are not loaded or execution failed.
+*/
+func RenderTemplate(router *gin.Engine, name string, data any) (string, error) {
+	if router.HTMLRender == nil {
+		return "", errors.New("HTML templates are not loaded")
+	}
+
+	instance, ok := router.HTMLRender.Instance(name, data).(render.HTML)
+	if !ok || instance.Template == nil {
+		return "", errors.New("HTML renderer does not expose its templates")
+	}
+
+	var output bytes.Buffer
+	if err := instance.Template.ExecuteTemplate(&output, name, data); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
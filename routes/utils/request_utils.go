@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+IsHTMX reports whether the request was issued by HTMX.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `bool` True if the request carries the `HX-Request` header.
+*/
+func IsHTMX(context *gin.Context) bool {
+	return context.GetHeader("HX-Request") == "true"
+}
+
+/*
+WantsJSON reports whether the client expects a JSON response rather than HTML.
+
+HTMX requests always get HTML. Other requests get JSON when they send a JSON body or list `application/json` in their
+Accept header.
+
+- Args:
+	* `context` (*gin.Context) The Gin context for the current HTTP request.
+
+- Returns:
+	* `bool` True if the response should be JSON.
+*/
+func WantsJSON(context *gin.Context) bool {
+	if IsHTMX(context) {
+		return false
+	}
+	return context.ContentType() == gin.MIMEJSON ||
+		strings.Contains(context.GetHeader("Accept"), gin.MIMEJSON)
+}
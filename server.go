@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gochat/attachment"
+	"gochat/config"
+	"gochat/database"
+	"gochat/routes"
+
+	"gorm.io/gorm"
+)
+
+// replyGracePeriod is how long shutdown waits for cancelled AI replies to be saved.
+const replyGracePeriod = 5 * time.Second
+
+// trashPurgeInterval is how often chats whose retention in the trash has run out are purged and the files of purged
+// attachments removed.
+const trashPurgeInterval = time.Hour
+
+/*
+runServer serves HTTP until the process receives SIGINT or SIGTERM and then shuts down gracefully.
+
+On a signal the server stops accepting connections and in-flight requests get the configured shutdown timeout to
+finish. Requests still running after that have their contexts cancelled, which aborts AI provider calls; the partial
+replies are saved before the database connection is closed. While the server runs, chats whose retention in the trash
+has run out are purged in the background, and the files of their attachments removed.
+
+- Args:
+	* `cfg` (*config.Config) The server configuration.
+	* `handler` (http.Handler) The router.
+	* `db` (*gorm.DB) The database connection, closed once the server has stopped.
+	* `files` (*attachment.Store) The attachment store the files of purged attachments are removed from.
+
+- Returns:
+	(error) An error if the server failed to start or stopped unexpectedly.
+*/
+func runServer(cfg *config.Config, handler http.Handler, db *gorm.DB, files *attachment.Store) error {
+	signalContext, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Every request context derives from requestContext, so cancelling it aborts whatever is still running.
+	requestContext, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	server := &http.Server{
+		Addr:        cfg.Server.Addr,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return requestContext },
+	}
+
+	// The purge job is stopped before the database connection is closed.
+	purgeContext, cancelPurge := context.WithCancel(context.Background())
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		runTrashPurge(purgeContext, db, files, time.Duration(cfg.Database.TrashRetention))
+	}()
+	stopPurge := func() {
+		cancelPurge()
+		<-purgeDone
+	}
+
+	serveErrors := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", cfg.Server.Addr)
+		if cfg.TLSEnabled() {
+			serveErrors <- server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			serveErrors <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErrors:
+		stopPurge()
+		database.CloseDB(db)
+		return err
+	case <-signalContext.Done():
+	}
+	stop()
+
+	timeout := time.Duration(cfg.Server.ShutdownTimeout)
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", timeout)
+	shutdownContext, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownContext); err != nil {
+		log.Printf("In-flight requests did not finish in time, cancelling them: %v", err)
+	}
+	cancelRequests()
+
+	graceContext, cancelGrace := context.WithTimeout(context.Background(), replyGracePeriod)
+	defer cancelGrace()
+	if err := routes.WaitForReplies(graceContext); err != nil {
+		log.Printf("Gave up waiting for AI replies to be saved: %v", err)
+	}
+	server.Close()
+	stopPurge()
+
+	if err := database.CloseDB(db); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+
+	if err := <-serveErrors; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("Server stopped")
+	return nil
+}
+
+/*
+runTrashPurge permanently deletes chats that have been in the trash for longer than the retention period and removes
+the files of the attachments of purged chats, once at start and then every trashPurgeInterval, until the context is
+cancelled.
+
+- Args:
+	* `ctx` (context.Context) The context that stops the job.
+	* `db` (*gorm.DB) The database connection.
+	* `files` (*attachment.Store) The attachment store.
+	* `retention` (time.Duration) How long deleted chats are kept; 0 keeps them until the trash is emptied.
+*/
+func runTrashPurge(ctx context.Context, db *gorm.DB, files *attachment.Store, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		if retention > 0 {
+			purged, err := database.PurgeTrash(db, time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Failed to purge the trash: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d chats from the trash", purged)
+			}
+		}
+
+		removed, err := attachment.RemovePurged(ctx, db, files)
+		if err != nil {
+			log.Printf("Failed to remove the files of purged attachments: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Removed %d purged attachments", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
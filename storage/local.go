@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage keeps objects as files below a directory of the local filesystem.
+type LocalStorage struct {
+	dir string
+}
+
+/*
+NewLocalStorage creates a LocalStorage. The directory is created when the first object is stored.
+
+- Args:
+	* `dir` (string) The directory the files are kept in.
+
+- Returns:
+	(*LocalStorage) The local storage, or an error if no directory is given.
+*/
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if dir == "" {
+		return nil, errors.New("local storage needs a directory")
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+/*
+Put writes an object to a file, replacing an existing one.
+
+The content is written to a temporary file first and renamed into place, so readers never see a partial file.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The key of the object.
+	* `content` ([]byte) The content.
+	* `contentType` (string) The MIME type of the content; the local storage does not keep it.
+
+- Returns:
+	(error) An error if the key is invalid or the file could not be written.
+*/
+func (storage *LocalStorage) Put(ctx context.Context, key string, content []byte, contentType string) error {
+	path, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+/*
+Open opens the file of an object for reading.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The key of the object.
+
+- Returns:
+	(io.ReadCloser) The content, ErrNotFound if there is no such object, or another error if it could not be opened.
+*/
+func (storage *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := storage.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+/*
+Delete removes the file of an object.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The key of the object.
+
+- Returns:
+	(error) An error if the key is invalid or the file could not be removed.
+*/
+func (storage *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+/*
+path maps a key onto the path of its file.
+
+- Args:
+	* `key` (string) The key of the object.
+
+- Returns:
+	(string) The path below the storage directory, or an error if the key is invalid.
+*/
+func (storage *LocalStorage) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(storage.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	keys := map[string]bool{
+		"ab/cd/0123.png":   true,
+		"file.txt":         true,
+		"a/..b/c":          true,
+		"":                 false,
+		"..":               false,
+		"../secret":        false,
+		"a/../../secret":   false,
+		"a/./b":            false,
+		"/etc/passwd":      false,
+		"a//b":             false,
+		"a/b/":             false,
+		`a\b`:              false,
+		`..\..\secret.txt`: false,
+	}
+	for key, want := range keys {
+		if got := validKey(key); got != want {
+			t.Errorf("validKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	storage, err := NewLocalStorage(filepath.Join(root, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := storage.Put(ctx, "ab/file.txt", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	file, err := storage.Open(ctx, "ab/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Open = %q, %v, want hello", content, err)
+	}
+
+	for _, key := range []string{"../escaped.txt", "ab//file.txt", `ab\file.txt`} {
+		if err := storage.Put(ctx, key, []byte("x"), "text/plain"); err == nil {
+			t.Errorf("Put(%q) was accepted", key)
+		}
+		if _, err := storage.Open(ctx, key); err == nil {
+			t.Errorf("Open(%q) was accepted", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("a file was written outside the storage directory: %v", err)
+	}
+
+	if err := storage.Delete(ctx, "ab/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Open(ctx, "ab/file.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete = %v, want ErrNotFound", err)
+	}
+}
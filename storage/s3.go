@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region  = "us-east-1"
+	defaultS3Timeout = 2 * time.Minute
+)
+
+// S3Storage keeps objects in a bucket of Amazon S3 or a compatible service such as MinIO.
+// Requests use path-style URLs and are signed with AWS Signature Version 4.
+type S3Storage struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+/*
+NewS3Storage creates an S3Storage.
+
+- Args:
+	* `options` (Options) The storage settings. The endpoint defaults to AWS S3 in the region.
+
+- Returns:
+	(*S3Storage) The S3 storage, or an error if the bucket, credentials or endpoint are missing or invalid.
+*/
+func NewS3Storage(options Options) (*S3Storage, error) {
+	if options.S3Bucket == "" {
+		return nil, errors.New("s3 storage needs a bucket")
+	}
+	if options.S3AccessKey == "" || options.S3SecretKey == "" {
+		return nil, errors.New("s3 storage needs an access key and a secret key")
+	}
+
+	region := options.S3Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	endpoint := strings.TrimRight(options.S3Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", options.S3Endpoint)
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultS3Timeout
+	}
+	return &S3Storage{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    options.S3Bucket,
+		accessKey: options.S3AccessKey,
+		secretKey: options.S3SecretKey,
+		client:    &http.Client{Timeout: timeout},
+		now:       time.Now,
+	}, nil
+}
+
+/*
+Put uploads an object, replacing an existing one.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The key of the object.
+	* `content` ([]byte) The content.
+	* `contentType` (string) The MIME type stored with the object.
+
+- Returns:
+	(error) An error if the key is invalid or the upload failed.
+*/
+func (storage *S3Storage) Put(ctx context.Context, key string, content []byte, contentType string) error {
+	request, err := storage.request(ctx, http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := storage.do(request, content)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+/*
+Open downloads an object.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The key of the object.
+
+- Returns:
+	(io.ReadCloser) The content, ErrNotFound if there is no such object, or another error if the download failed.
+*/
+func (storage *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	request, err := storage.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := storage.do(request, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+/*
+Delete deletes an object.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `key` (string) The key of the object.
+
+- Returns:
+	(error) An error if the key is invalid or the request failed.
+*/
+func (storage *S3Storage) Delete(ctx context.Context, key string) error {
+	request, err := storage.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	response, err := storage.do(request, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+/*
+request builds the request for an object.
+
+- Args:
+	* `ctx` (context.Context) The request context.
+	* `method` (string) The HTTP method.
+	* `key` (string) The key of the object.
+	* `content` ([]byte) The request body, nil for none.
+
+- Returns:
+	(*http.Request) The unsigned request, or an error if the key is invalid.
+*/
+func (storage *S3Storage) request(ctx context.Context, method, key string, content []byte) (*http.Request, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid storage key %q", key)
+	}
+
+	target := storage.endpoint + "/" + escapePath(storage.bucket+"/"+key)
+	return http.NewRequestWithContext(ctx, method, target, bytes.NewReader(content))
+}
+
+/*
+do signs and sends a request.
+
+The caller is responsible for closing the response body. A 404 response is returned as ErrNotFound and other non-2xx
+responses as an error that includes the start of the response body.
+
+- Args:
+	* `request` (*http.Request) The request.
+	* `content` ([]byte) The request body, nil for none.
+
+- Returns:
+	(*http.Response) The response, or an error if the request failed.
+*/
+func (storage *S3Storage) do(request *http.Request, content []byte) (*http.Response, error) {
+	storage.sign(request, content, storage.now())
+
+	response, err := storage.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotFound
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("s3 %s %s returned %s: %s", request.Method, request.URL.Path, response.Status, bytes.TrimSpace(detail))
+	}
+	return response, nil
+}
+
+/*
+sign adds an AWS Signature Version 4 authorization header to a request.
+
+The host header and every `x-amz-*` header are signed, and the payload hash is sent in `x-amz-content-sha256`.
+
+- Args:
+	* `request` (*http.Request) The request; its headers are updated.
+	* `content` ([]byte) The request body, nil for none.
+	* `now` (time.Time) The signing time.
+*/
+func (storage *S3Storage) sign(request *http.Request, content []byte, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	payloadHash := sha256Hex(content)
+	request.Header.Set("X-Amz-Date", timestamp)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + storage.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", timestamp, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+storage.secretKey), date)
+	for _, part := range []string{storage.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		storage.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+/*
+canonicalQuery formats query parameters as required by Signature Version 4: sorted and strictly escaped.
+
+- Args:
+	* `query` (url.Values) The query parameters.
+
+- Returns:
+	(string) The canonical query string.
+*/
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(name, false)+"="+escape(value, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+/*
+escapePath escapes every segment of a path as required by Signature Version 4.
+
+- Args:
+	* `path` (string) The path.
+
+- Returns:
+	(string) The escaped path, with its slashes kept.
+*/
+func escapePath(path string) string {
+	return escape(path, true)
+}
+
+/*
+escape percent-encodes every byte except the unreserved characters of RFC 3986.
+
+- Args:
+	* `value` (string) The value.
+	* `keepSlash` (bool) Whether slashes are kept as they are.
+
+- Returns:
+	(string) The escaped value.
+*/
+func escape(value string, keepSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && keepSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+/*
+sha256Hex hashes data with SHA-256.
+
+- Args:
+	* `data` ([]byte) The data.
+
+- Returns:
+	(string) The hash as lower-case hex.
+*/
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+hmacSHA256 computes an HMAC-SHA256.
+
+- Args:
+	* `key` ([]byte) The key.
+	* `data` (string) The data.
+
+- Returns:
+	([]byte) The MAC.
+*/
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,92 @@
+// Package storage keeps the files attached to messages, on the local filesystem or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a key has no stored object.
+var ErrNotFound = errors.New("object not found")
+
+/*
+Storage keeps the files attached to messages.
+
+Objects are addressed by keys made of path segments separated by slashes. Implementations must be safe for concurrent
+use; deleting a key that has no object is not an error.
+*/
+type Storage interface {
+	Put(ctx context.Context, key string, content []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+/*
+Options holds the settings used to construct a Storage.
+
+- Fields:
+	* `Backend` (string) The storage to use: "local" or "s3".
+	* `Dir` (string) The directory the local storage keeps files in.
+	* `S3Endpoint` (string) The base URL of the S3-compatible service. Defaults to AWS S3 in the region.
+	* `S3Region` (string) The region requests are signed for. Defaults to "us-east-1".
+	* `S3Bucket` (string) The bucket objects are stored in.
+	* `S3AccessKey` (string) The access key ID.
+	* `S3SecretKey` (string) The secret access key.
+	* `Timeout` (time.Duration) The HTTP timeout for a single S3 request.
+*/
+type Options struct {
+	Backend     string
+	Dir         string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	Timeout     time.Duration
+}
+
+/*
+New creates the Storage selected by the given options.
+
+- Args:
+	* `options` (Options) The storage settings.
+
+- Returns:
+	(Storage) The configured storage, or an error if the backend is unknown or its settings are incomplete.
+*/
+func New(options Options) (Storage, error) {
+	switch strings.ToLower(options.Backend) {
+	case "", "local":
+		return NewLocalStorage(options.Dir)
+	case "s3":
+		return NewS3Storage(options)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", options.Backend)
+	}
+}
+
+/*
+validKey reports whether a key is safe to use as an object name: relative, without empty, "." or ".." segments and
+without backslashes.
+
+- Args:
+	* `key` (string) The key.
+
+- Returns:
+	(bool) True if the key is valid.
+*/
+func validKey(key string) bool {
+	if key == "" || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package transcript
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strings"
+
+	"gochat/models"
+)
+
+// page is the standalone HTML document an HTML export is rendered into.
+var page = template.Must(template.New("transcript").Parse(`<!doctype html>
+<html lang="en">
+	<head>
+		<meta charset="utf-8" />
+		<meta name="viewport" content="width=device-width, initial-scale=1" />
+		<title>{{ .Title }}</title>
+		<style>
+			body { max-width: 50rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.5; }
+			.message { border-radius: 0.35rem; padding: 1rem 1.5rem; margin-bottom: 1.5rem; }
+			.user-message { background-color: #e6e6e6; margin-left: 4rem; }
+			.ai-message { border: 1px solid #e6e6e6; margin-right: 4rem; }
+			.role { font-weight: bold; margin: 0 0 0.5rem; }
+			pre { overflow-x: auto; }
+			{{ .Styles }}
+		</style>
+	</head>
+	<body>
+		<h1>{{ .Title }}</h1>
+		<p><em>Exported from gochat on {{ .ExportedAt }}</em></p>
+		{{ range .Messages }}
+		<section class="message {{ .Class }}">
+			<p class="role">{{ .Role }}</p>
+			{{ .Body }}
+		</section>
+		{{ end }}
+	</body>
+</html>
+`))
+
+/*
+HTML renders the active branch of the chat as a standalone HTML page.
+
+Code blocks are marked up the way Prism expects, and the given styles are inlined so the page needs no other files.
+
+- Args:
+	* `styles` (string) The CSS to inline, usually the Prism theme used by the app.
+
+- Returns:
+	([]byte) The HTML page, or an error if it could not be rendered.
+*/
+func (transcript *Transcript) HTML(styles string) ([]byte, error) {
+	type pageMessage struct {
+		Class string
+		Role  string
+		Body  template.HTML
+	}
+
+	messages := make([]pageMessage, len(transcript.Chat.Messages))
+	for i, message := range transcript.Chat.Messages {
+		class := "user-message"
+		if message.MessageType == models.AIMessageType {
+			class = "ai-message"
+		}
+		messages[i] = pageMessage{Class: class, Role: roleName(message.MessageType), Body: markdownToHTML(message.Message)}
+	}
+
+	var output bytes.Buffer
+	err := page.Execute(&output, map[string]any{
+		"Title":      transcript.Title,
+		"ExportedAt": transcript.ExportedAt.UTC().Format("2006-01-02 15:04 MST"),
+		// The styles are read from the app's own static files.
+		"Styles":   template.CSS(styles),
+		"Messages": messages,
+	})
+	return output.Bytes(), err
+}
+
+/*
+markdownToHTML converts the Markdown of a message into HTML.
+
+Only what matters for transcripts is supported: fenced code blocks, which keep their language for Prism, inline code,
+and paragraphs with line breaks. Everything else is shown as text.
+
+- Args:
+	* `text` (string) The Markdown text.
+
+- Returns:
+	(template.HTML) The escaped HTML.
+*/
+func markdownToHTML(text string) template.HTML {
+	var output, paragraph, code strings.Builder
+	fence, language := "", ""
+
+	flushParagraph := func() {
+		if paragraph.Len() > 0 {
+			output.WriteString("<p>" + paragraph.String() + "</p>\n")
+			paragraph.Reset()
+		}
+	}
+	flushCode := func() {
+		class := ""
+		if language != "" {
+			class = ` class="language-` + html.EscapeString(language) + `"`
+		}
+		output.WriteString("<pre" + class + "><code" + class + ">" + html.EscapeString(code.String()) + "</code></pre>\n")
+		code.Reset()
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		marker := fenceMarker(line)
+		switch {
+		case fence != "" && strings.HasPrefix(marker, fence) && strings.TrimSpace(line) == marker:
+			flushCode()
+			fence = ""
+		case fence != "":
+			code.WriteString(line + "\n")
+		case marker != "":
+			flushParagraph()
+			fence = marker
+			language, _, _ = strings.Cut(strings.TrimSpace(strings.TrimSpace(line)[len(marker):]), " ")
+		case strings.TrimSpace(line) == "":
+			flushParagraph()
+		default:
+			if paragraph.Len() > 0 {
+				paragraph.WriteString("<br />\n")
+			}
+			paragraph.WriteString(inlineCode(line))
+		}
+	}
+
+	flushParagraph()
+	if fence != "" {
+		flushCode()
+	}
+	return template.HTML(output.String())
+}
+
+/*
+inlineCode escapes a line of text and wraps the spans between pairs of backticks in code elements.
+
+- Args:
+	* `line` (string) The line of Markdown text.
+
+- Returns:
+	(string) The escaped HTML.
+*/
+func inlineCode(line string) string {
+	parts := strings.Split(line, "`")
+	// An unpaired backtick is kept as text.
+	if len(parts)%2 == 0 {
+		return html.EscapeString(line)
+	}
+
+	var output strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 {
+			output.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		} else {
+			output.WriteString(html.EscapeString(part))
+		}
+	}
+	return output.String()
+}
@@ -0,0 +1,312 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gochat/models"
+)
+
+/*
+ImportedChat is a chat read from an export file, ready to be saved.
+
+- Fields:
+	* `Title` (string) The title of the chat.
+	* `CreatedAt` (time.Time) When the chat was created, or the zero time if unknown.
+	* `UpdatedAt` (time.Time) When the chat was last changed, or the zero time if unknown.
+	* `Messages` ([]ImportedMessage) The messages, ordered so every parent comes before its children.
+	* `ActiveKey` (string) The key of the last message of the branch to show, or an empty string for the latest message.
+	* `Skipped` (int) The number of messages left out, such as system prompts, tool calls and empty messages.
+*/
+type ImportedChat struct {
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Messages  []ImportedMessage
+	ActiveKey string
+	Skipped   int
+}
+
+/*
+ImportedMessage is a message read from an export file.
+
+- Fields:
+	* `Key` (string) The ID of the message in the file.
+	* `ParentKey` (string) The key of the message it follows, or an empty string if it starts the chat.
+	* `MessageType` (models.MessageType) Whether the user or the AI wrote the message.
+	* `Message` (string) The text.
+	* `CreatedAt` (time.Time) When the message was written, or the zero time if unknown.
+*/
+type ImportedMessage struct {
+	Key         string
+	ParentKey   string
+	MessageType models.MessageType
+	Message     string
+	CreatedAt   time.Time
+}
+
+/*
+ParseImport reads the chats from an export file.
+
+Two formats are recognised: the `conversations.json` file of a ChatGPT data export, which is a list of conversations,
+and the JSON export of a gochat chat, which is a single Document.
+
+- Args:
+	* `data` ([]byte) The contents of the file.
+
+- Returns:
+	([]ImportedChat) The chats, or an error if the file is in neither format.
+*/
+func ParseImport(data []byte) ([]ImportedChat, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return parseChatGPT(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		chat, err := parseDocument(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return []ImportedChat{chat}, nil
+	default:
+		return nil, errors.New("the file is not a ChatGPT conversations.json or gochat JSON export")
+	}
+}
+
+/*
+parseDocument reads a gochat JSON export.
+
+- Args:
+	* `data` ([]byte) The JSON document.
+
+- Returns:
+	(ImportedChat) The chat, or an error if the document is invalid.
+*/
+func parseDocument(data []byte) (ImportedChat, error) {
+	var document Document
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return ImportedChat{}, fmt.Errorf("invalid gochat export: %w", err)
+	}
+	if document.Version < 1 || document.Version > DocumentVersion {
+		return ImportedChat{}, fmt.Errorf("unsupported gochat export version %d", document.Version)
+	}
+
+	chat := ImportedChat{Title: document.Title, CreatedAt: document.CreatedAt, UpdatedAt: document.UpdatedAt}
+	known := make(map[uint]bool, len(document.Messages))
+	for _, message := range document.Messages {
+		if message.MessageType != models.UserMessageType && message.MessageType != models.AIMessageType {
+			return ImportedChat{}, fmt.Errorf("message %d has unknown type %q", message.ID, message.MessageType)
+		}
+		// Exports list messages in the order they were created, so a parent always comes first.
+		parentKey := ""
+		if message.ParentID != nil {
+			if !known[*message.ParentID] {
+				return ImportedChat{}, fmt.Errorf("message %d follows unknown message %d", message.ID, *message.ParentID)
+			}
+			parentKey = strconv.FormatUint(uint64(*message.ParentID), 10)
+		}
+		known[message.ID] = true
+
+		chat.Messages = append(chat.Messages, ImportedMessage{
+			Key:         strconv.FormatUint(uint64(message.ID), 10),
+			ParentKey:   parentKey,
+			MessageType: message.MessageType,
+			Message:     message.Message,
+			CreatedAt:   message.CreatedAt,
+		})
+	}
+	if document.ActiveMessageID != nil && known[*document.ActiveMessageID] {
+		chat.ActiveKey = strconv.FormatUint(uint64(*document.ActiveMessageID), 10)
+	}
+	return chat, nil
+}
+
+// chatGPTConversation is a conversation in a ChatGPT conversations.json file.
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+	CurrentNode string                 `json:"current_node"`
+}
+
+// chatGPTNode is a node of the message tree of a ChatGPT conversation.
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   *string         `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+// chatGPTMessage is the message held by a node; the root node and some system nodes have none.
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+}
+
+/*
+parseChatGPT reads the conversations.json file of a ChatGPT data export.
+
+User and assistant messages are kept. System prompts, tool messages and messages without text are skipped, and
+their children are attached to the nearest message that is kept, so branches survive.
+
+- Args:
+	* `data` ([]byte) The JSON list of conversations.
+
+- Returns:
+	([]ImportedChat) The chats, or an error if the file is invalid.
+*/
+func parseChatGPT(data []byte) ([]ImportedChat, error) {
+	var conversations []chatGPTConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return nil, fmt.Errorf("invalid ChatGPT export: %w", err)
+	}
+
+	chats := make([]ImportedChat, 0, len(conversations))
+	for i, conversation := range conversations {
+		if conversation.Mapping == nil {
+			return nil, fmt.Errorf("conversation %d has no messages mapping, is this a ChatGPT conversations.json?", i+1)
+		}
+
+		chat := ImportedChat{
+			Title:     conversation.Title,
+			CreatedAt: unixTime(conversation.CreateTime),
+			UpdatedAt: unixTime(conversation.UpdateTime),
+		}
+
+		// kept maps every visited node to itself if it is kept, or to the nearest kept ancestor otherwise.
+		kept := make(map[string]string, len(conversation.Mapping))
+		var visit func(key, parentKey string)
+		visit = func(key, parentKey string) {
+			if _, seen := kept[key]; seen {
+				return
+			}
+			node := conversation.Mapping[key]
+			if message, ok := chatGPTImport(node, key, parentKey); ok {
+				chat.Messages = append(chat.Messages, message)
+				parentKey = key
+			} else if node.Message != nil {
+				chat.Skipped++
+			}
+			kept[key] = parentKey
+
+			for _, child := range node.Children {
+				if _, exists := conversation.Mapping[child]; exists {
+					visit(child, parentKey)
+				}
+			}
+		}
+
+		for _, root := range chatGPTRoots(conversation.Mapping) {
+			visit(root, "")
+		}
+		chat.ActiveKey = kept[conversation.CurrentNode]
+		chats = append(chats, chat)
+	}
+	return chats, nil
+}
+
+/*
+chatGPTRoots returns the keys of the nodes without a known parent, in a stable order.
+
+- Args:
+	* `mapping` (map[string]chatGPTNode) The nodes of a conversation.
+
+- Returns:
+	([]string) The keys of the root nodes.
+*/
+func chatGPTRoots(mapping map[string]chatGPTNode) []string {
+	var roots []string
+	for key, node := range mapping {
+		if node.Parent == nil {
+			roots = append(roots, key)
+		} else if _, exists := mapping[*node.Parent]; !exists {
+			roots = append(roots, key)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+/*
+chatGPTImport converts a ChatGPT node into a message, if it holds one worth keeping.
+
+- Args:
+	* `node` (chatGPTNode) The node.
+	* `key` (string) The key of the node.
+	* `parentKey` (string) The key of the nearest kept ancestor.
+
+- Returns:
+	* `ImportedMessage` The message.
+	* `bool` False if the node is not a user or assistant message with text.
+*/
+func chatGPTImport(node chatGPTNode, key, parentKey string) (ImportedMessage, bool) {
+	if node.Message == nil {
+		return ImportedMessage{}, false
+	}
+
+	var messageType models.MessageType
+	switch node.Message.Author.Role {
+	case "user":
+		messageType = models.UserMessageType
+	case "assistant":
+		messageType = models.AIMessageType
+	default:
+		return ImportedMessage{}, false
+	}
+
+	var parts []string
+	for _, raw := range node.Message.Content.Parts {
+		// Parts that are not text, such as images, are left out.
+		var part string
+		if json.Unmarshal(raw, &part) == nil && strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	text := strings.Join(parts, "\n\n")
+	if text == "" && node.Message.Content.Text != "" {
+		text = node.Message.Content.Text
+	}
+	if strings.TrimSpace(text) == "" {
+		return ImportedMessage{}, false
+	}
+
+	message := ImportedMessage{Key: key, ParentKey: parentKey, MessageType: messageType, Message: text}
+	if node.Message.CreateTime != nil {
+		message.CreatedAt = unixTime(*node.Message.CreateTime)
+	}
+	return message, true
+}
+
+/*
+unixTime converts a Unix timestamp with fractional seconds, as used by ChatGPT exports, into a time.
+
+- Args:
+	* `seconds` (float64) Seconds since the Unix epoch, or 0 if unknown.
+
+- Returns:
+	(time.Time) The time, or the zero time for 0.
+*/
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)).UTC()
+}
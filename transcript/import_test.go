@@ -0,0 +1,118 @@
+package transcript
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gochat/models"
+)
+
+// TestParseChatGPT reads a branched conversation in which a system prompt and a tool call are left out.
+func TestParseChatGPT(t *testing.T) {
+	data, err := os.ReadFile("testdata/conversations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chats, err := ParseImport(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 1 {
+		t.Fatalf("got %d chats, want 1", len(chats))
+	}
+	chat := chats[0]
+
+	if chat.Title != "Greetings" || !chat.CreatedAt.Equal(time.Unix(1700000000, 5e8)) {
+		t.Errorf("chat = %q created %v, want Greetings created at 1700000000.5", chat.Title, chat.CreatedAt)
+	}
+	// The children of the skipped nodes follow their nearest kept ancestor: u1 the root, u2 the reply a2.
+	want := []ImportedMessage{
+		{Key: "u1", ParentKey: "", MessageType: models.UserMessageType, Message: "Hello"},
+		{Key: "a1", ParentKey: "u1", MessageType: models.AIMessageType, Message: "Hi there"},
+		{Key: "a2", ParentKey: "u1", MessageType: models.AIMessageType, Message: "Hello again"},
+		{Key: "u2", ParentKey: "a2", MessageType: models.UserMessageType, Message: "Thanks"},
+	}
+	if len(chat.Messages) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(chat.Messages), len(want), chat.Messages)
+	}
+	for i, message := range chat.Messages {
+		message.CreatedAt = time.Time{}
+		if message != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, message, want[i])
+		}
+	}
+	if chat.Skipped != 2 {
+		t.Errorf("skipped %d messages, want the system prompt and the tool call", chat.Skipped)
+	}
+	// current_node picks the first reply, not the branch that continues.
+	if chat.ActiveKey != "a1" {
+		t.Errorf("active key = %q, want a1", chat.ActiveKey)
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	chats, err := ParseImport([]byte(`{
+		"version": 1,
+		"title": "Exported",
+		"active_message_id": 2,
+		"messages": [
+			{"id": 1, "parent_id": null, "message_type": "USER", "message": "Hello"},
+			{"id": 2, "parent_id": 1, "message_type": "AI", "message": "Hi"},
+			{"id": 3, "parent_id": 1, "message_type": "AI", "message": "Hey"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := chats[0]
+	if chat.Title != "Exported" || chat.ActiveKey != "2" || len(chat.Messages) != 3 {
+		t.Fatalf("chat = %+v, want Exported with three messages and message 2 active", chat)
+	}
+	if chat.Messages[2].ParentKey != "1" || chat.Messages[2].MessageType != models.AIMessageType {
+		t.Errorf("message 3 = %+v, want an AI reply to message 1", chat.Messages[2])
+	}
+}
+
+func TestParseDocumentRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "unknown parent",
+			document: `{"version": 1, "messages": [{"id": 2, "parent_id": 1, "message_type": "USER", "message": "Hello"}]}`,
+			want:     "follows unknown message 1",
+		},
+		{
+			name: "parent listed after the child",
+			document: `{"version": 1, "messages": [
+				{"id": 2, "parent_id": 1, "message_type": "AI", "message": "Hi"},
+				{"id": 1, "parent_id": null, "message_type": "USER", "message": "Hello"}
+			]}`,
+			want: "follows unknown message 1",
+		},
+		{
+			name:     "unknown type",
+			document: `{"version": 1, "messages": [{"id": 1, "message_type": "SYSTEM", "message": "Hello"}]}`,
+			want:     "unknown type",
+		},
+		{
+			name:     "unsupported version",
+			document: `{"version": 2, "messages": []}`,
+			want:     "unsupported gochat export version 2",
+		},
+		{
+			name:     "unknown field",
+			document: `{"version": 1, "messages": [], "mapping": {}}`,
+			want:     "invalid gochat export",
+		},
+	}
+	for _, test := range tests {
+		if _, err := ParseImport([]byte(test.document)); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
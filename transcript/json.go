@@ -0,0 +1,66 @@
+package transcript
+
+import (
+	"encoding/json"
+	"time"
+
+	"gochat/models"
+)
+
+// DocumentVersion is the version of the JSON export format.
+const DocumentVersion = 1
+
+/*
+Document is the lossless JSON export of a chat.
+
+Messages holds every message of every branch in the order they were created; ParentID links them into a tree and
+ActiveMessageID marks the end of the branch that was shown.
+*/
+type Document struct {
+	Version         int               `json:"version"`
+	ExportedAt      time.Time         `json:"exported_at"`
+	Title           string            `json:"title"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	ActiveMessageID *uint             `json:"active_message_id"`
+	Messages        []DocumentMessage `json:"messages"`
+}
+
+// DocumentMessage is a message in a Document.
+type DocumentMessage struct {
+	ID          uint               `json:"id"`
+	ParentID    *uint              `json:"parent_id"`
+	MessageType models.MessageType `json:"message_type"`
+	Message     string             `json:"message"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+/*
+JSON renders the chat as a Document.
+
+- Returns:
+	([]byte) The indented JSON document, or an error if it could not be encoded.
+*/
+func (transcript *Transcript) JSON() ([]byte, error) {
+	document := Document{
+		Version:         DocumentVersion,
+		ExportedAt:      transcript.ExportedAt.UTC(),
+		Title:           transcript.Chat.Title,
+		CreatedAt:       transcript.Chat.CreatedAt,
+		UpdatedAt:       transcript.Chat.UpdatedAt,
+		ActiveMessageID: transcript.Chat.ActiveMessageID,
+		Messages:        make([]DocumentMessage, len(transcript.Messages)),
+	}
+	for i, message := range transcript.Messages {
+		document.Messages[i] = DocumentMessage{
+			ID:          message.ID,
+			ParentID:    message.ParentID,
+			MessageType: message.MessageType,
+			Message:     message.Message,
+			CreatedAt:   message.CreatedAt,
+			UpdatedAt:   message.UpdatedAt,
+		}
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
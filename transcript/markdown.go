@@ -0,0 +1,79 @@
+package transcript
+
+import (
+	"strings"
+)
+
+/*
+Markdown renders the active branch of the chat as a Markdown transcript.
+
+Every message gets a role heading followed by its text as written, so code fences are kept. A fence a message leaves
+open is closed, so it cannot swallow the messages after it.
+
+- Returns:
+	([]byte) The transcript.
+*/
+func (transcript *Transcript) Markdown() []byte {
+	var output strings.Builder
+	output.WriteString("# " + transcript.Title + "\n\n")
+	output.WriteString("_Exported from gochat on " + transcript.ExportedAt.UTC().Format("2006-01-02 15:04 MST") + "_\n")
+
+	for _, message := range transcript.Chat.Messages {
+		output.WriteString("\n## " + roleName(message.MessageType) + "\n\n")
+		text := strings.TrimRight(message.Message, "\n")
+		output.WriteString(text + "\n")
+		if fence := openFence(text); fence != "" {
+			output.WriteString(fence + "\n")
+		}
+	}
+	return []byte(output.String())
+}
+
+/*
+openFence finds a code fence that is still open at the end of a Markdown text.
+
+- Args:
+	* `text` (string) The Markdown text.
+
+- Returns:
+	(string) The marker that closes the fence, such as "```", or an empty string if every fence is closed.
+*/
+func openFence(text string) string {
+	open := ""
+	for _, line := range strings.Split(text, "\n") {
+		marker := fenceMarker(line)
+		switch {
+		case marker == "":
+		case open == "":
+			open = marker
+		case strings.HasPrefix(marker, open) && strings.TrimSpace(line) == marker:
+			open = ""
+		}
+	}
+	return open
+}
+
+/*
+fenceMarker returns the fence that starts a line, if any.
+
+- Args:
+	* `line` (string) A line of Markdown.
+
+- Returns:
+	(string) The run of at least three backticks or tildes that starts the line, or an empty string.
+*/
+func fenceMarker(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return ""
+	}
+
+	end := 0
+	for end < len(trimmed) && trimmed[end] == trimmed[0] {
+		end++
+	}
+	if end < 3 {
+		return ""
+	}
+	return trimmed[:end]
+}
@@ -0,0 +1,142 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gochat/models"
+)
+
+/*
+Format describes a file format a chat can be exported to.
+
+- Fields:
+	* `Name` (string) The name used in the `format` query parameter.
+	* `Extension` (string) The file extension, without the dot.
+	* `ContentType` (string) The MIME type of the file.
+*/
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+}
+
+var (
+	// MarkdownFormat is a readable transcript with a heading per message.
+	MarkdownFormat = Format{Name: "md", Extension: "md", ContentType: "text/markdown; charset=utf-8"}
+	// JSONFormat is a lossless document holding every message of every branch.
+	JSONFormat = Format{Name: "json", Extension: "json", ContentType: "application/json; charset=utf-8"}
+	// HTMLFormat is a standalone page with the code styles inlined.
+	HTMLFormat = Format{Name: "html", Extension: "html", ContentType: "text/html; charset=utf-8"}
+)
+
+/*
+ParseFormat looks up an export format by name.
+
+- Args:
+	* `name` (string) The format name: "md", "json" or "html". "markdown" is accepted for "md".
+
+- Returns:
+	(Format) The format, or an error if the name is unknown.
+*/
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "md", "markdown":
+		return MarkdownFormat, nil
+	case "json":
+		return JSONFormat, nil
+	case "html":
+		return HTMLFormat, nil
+	default:
+		return Format{}, fmt.Errorf("unknown export format %q", name)
+	}
+}
+
+/*
+Transcript is a chat prepared for export.
+
+- Fields:
+	* `Title` (string) The title shown for the chat.
+	* `Chat` (*models.Chat) The chat, with the messages of its active branch.
+	* `Messages` ([]models.Message) Every message of the chat across all branches, for the lossless JSON document.
+	* `ExportedAt` (time.Time) When the export was made.
+*/
+type Transcript struct {
+	Title      string
+	Chat       *models.Chat
+	Messages   []models.Message
+	ExportedAt time.Time
+}
+
+/*
+Render writes the transcript in the given format.
+
+- Args:
+	* `format` (Format) The export format.
+	* `styles` (string) The CSS inlined into HTML exports.
+
+- Returns:
+	([]byte) The exported file, or an error if it could not be rendered.
+*/
+func (transcript *Transcript) Render(format Format, styles string) ([]byte, error) {
+	switch format {
+	case MarkdownFormat:
+		return transcript.Markdown(), nil
+	case JSONFormat:
+		return transcript.JSON()
+	case HTMLFormat:
+		return transcript.HTML(styles)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format.Name)
+	}
+}
+
+/*
+Filename returns a file name for the transcript derived from the chat title.
+
+- Args:
+	* `format` (Format) The export format.
+
+- Returns:
+	(string) The file name, for example "reversing-a-slice.md".
+*/
+func (transcript *Transcript) Filename(format Format) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(transcript.Title) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			slug.WriteRune(r)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteByte('-')
+			dash = true
+		}
+		if slug.Len() >= 50 {
+			break
+		}
+	}
+
+	name := strings.Trim(slug.String(), "-")
+	if name == "" {
+		name = fmt.Sprintf("chat-%d", transcript.Chat.ID)
+	}
+	return name + "." + format.Extension
+}
+
+/*
+roleName returns the heading used for a message in exports.
+
+- Args:
+	* `messageType` (models.MessageType) The message type.
+
+- Returns:
+	(string) "Assistant" for AI messages and "User" for everything else.
+*/
+func roleName(messageType models.MessageType) string {
+	if messageType == models.AIMessageType {
+		return "Assistant"
+	}
+	return "User"
+}